@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 审计日志的操作类型
+const (
+	AuditActionTagCreate         = "tag.create"
+	AuditActionTagRestore        = "tag.restore"
+	AuditActionTagUpdate         = "tag.update"
+	AuditActionTagRename         = "tag.rename"
+	AuditActionTagDelete         = "tag.delete"
+	AuditActionTagImport         = "tag.import"
+	AuditActionTagMerge          = "tag.merge"
+	AuditActionTagAddSynonym     = "tag.add_synonym"
+	AuditActionTagRemoveSynonym  = "tag.remove_synonym"
+	AuditActionTagSetMetadata    = "tag.set_metadata"
+	AuditActionTagDeleteMetadata = "tag.delete_metadata"
+	AuditActionTagLinkEntities   = "tag.link_entities"
+	AuditActionTagUnlinkEntities = "tag.unlink_entities"
+	AuditActionEntityLink        = "entity.link"
+	AuditActionEntityReplaceTags = "entity.replace_tags"
+	AuditActionEntityClearTags   = "entity.clear_tags"
+	AuditActionEntityUpdateLink  = "entity.update_link"
+	AuditActionEntityReorderTags = "entity.reorder_tags"
+	AuditActionEntityImportTags  = "entity.import_tags"
+	AuditActionEntityRestoreLink = "entity.restore_link"
+)
+
+// 审计日志记录的对象类型
+const (
+	AuditEntityTypeTag    = "tag"
+	AuditEntityTypeEntity = "entity"
+)
+
+// AuditLog 审计日志
+type AuditLog struct {
+	Action     string
+	EntityType string
+	EntityID   int
+	// Metadata 操作相关的数据，更新操作记录 before 和 after，序列化为 JSON 保存
+	Metadata interface{}
+}
+
+// actorContextKey 在 context 中保存操作人 ID 的键
+type actorContextKey struct{}
+
+// WithActorID 返回携带操作人 ID 的 context
+func WithActorID(ctx context.Context, actorID string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actorID)
+}
+
+// ActorIDFromContext 从 context 中读取操作人 ID，由认证中间件从 JWT 中解析后写入，未认证时为空字符串
+func ActorIDFromContext(ctx context.Context) string {
+	actorID, _ := ctx.Value(actorContextKey{}).(string)
+	return actorID
+}
+
+// auditLogArgs 返回写入 audit_log_tbl 的参数
+func auditLogArgs(ctx context.Context, entry *AuditLog) ([]interface{}, error) {
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		TenantIDFromContext(ctx), ActorIDFromContext(ctx), entry.Action, entry.EntityType, entry.EntityID, string(metadata),
+	}, nil
+}
+
+// insertAuditLogQuery 写入审计日志的语句
+const insertAuditLogQuery = "insert into audit_log_tbl (tenant_id, actor_id, action, entity_type, entity_id, metadata) values (?, ?, ?, ?, ?, ?)"
+
+// InsertAuditLog 写入一条审计日志
+func InsertAuditLog(ctx context.Context, entry *AuditLog) error {
+	args, err := auditLogArgs(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	_, execErr := dbExec(ctx, insertAuditLogQuery, args...)
+	return execErr
+}
+
+// InsertAuditLogTx 在事务中写入一条审计日志，与业务数据一起提交或回滚
+func InsertAuditLogTx(ctx context.Context, tx *sqlx.Tx, entry *AuditLog) error {
+	args, err := auditLogArgs(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	_, execErr := txExec(ctx, tx, insertAuditLogQuery, args...)
+	return execErr
+}
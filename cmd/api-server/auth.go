@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// gin context 中保存认证信息的键
+const (
+	ContextKeyActorID = "actor_id"
+	ContextKeyRoles   = "roles"
+)
+
+// AuthClaims JWT 中使用的字段，sub 为操作人 ID，roles 为操作人拥有的角色，tenant_id 为 token 可以访问的租户
+type AuthClaims struct {
+	Roles    []string `json:"roles"`
+	TenantID string   `json:"tenant_id"`
+	jwt.RegisteredClaims
+}
+
+// JWTMiddleware 校验 Authorization 请求头中的 Bearer token，token 使用 secret 以 HMAC 签名，
+// tenant_id 与 X-Tenant-Id 不同时返回 403，需要放在 TenantMiddleware 之后。
+// 校验通过后把 sub 和 roles 保存到 gin context 中，同时写入请求的 context，sub 用于记录审计日志
+func JWTMiddleware(secret string) gin.HandlerFunc {
+	return jwtMiddleware(secret, false)
+}
+
+// OptionalJWTMiddleware 与 JWTMiddleware 相同，但没有 Authorization 请求头时作为匿名请求继续处理，
+// 用于同一个接口中既有公开的查询又有需要角色的修改的场景，由处理函数通过 HasRole 检查角色
+func OptionalJWTMiddleware(secret string) gin.HandlerFunc {
+	return jwtMiddleware(secret, true)
+}
+
+// newTokenParser 返回校验 Authorization 值的函数，值需要是 Bearer token，token 使用 secret 以 HMAC 签名。
+// 缺少 token 或 token 无效时返回 401 APIError。HTTP 和 gRPC 接口共用
+func newTokenParser(secret string) func(authorization string) (*AuthClaims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+	keyFunc := func(*jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}
+
+	return func(authorization string) (*AuthClaims, error) {
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			return nil, newAPIError(http.StatusUnauthorized, "missing token")
+		}
+
+		var claims AuthClaims
+		_, err := parser.ParseWithClaims(strings.TrimPrefix(authorization, "Bearer "), &claims, keyFunc)
+		if err != nil || claims.Subject == "" {
+			return nil, newAPIError(http.StatusUnauthorized, "invalid token")
+		}
+		return &claims, nil
+	}
+}
+
+// checkTokenTenant token 的 tenant_id 与 context 中的租户不同时返回 403 APIError，没有 tenant_id 的 token 同样拒绝。
+// 需要在 TenantMiddleware 之后调用，HTTP 和 gRPC 接口共用
+func checkTokenTenant(ctx context.Context, claims *AuthClaims) error {
+	if claims.TenantID == "" || claims.TenantID != TenantIDFromContext(ctx) {
+		return newAPIError(http.StatusForbidden, "token not valid for tenant")
+	}
+	return nil
+}
+
+// WithAuthClaims 返回携带操作人 ID 和角色的 context，sub 用于记录审计日志，角色用于 HasRole
+func WithAuthClaims(ctx context.Context, claims *AuthClaims) context.Context {
+	return context.WithValue(WithActorID(ctx, claims.Subject), rolesContextKey{}, claims.Roles)
+}
+
+// jwtMiddleware optional 为 true 时没有 Authorization 请求头不返回 401
+func jwtMiddleware(secret string, optional bool) gin.HandlerFunc {
+	parseToken := newTokenParser(secret)
+
+	return func(c *gin.Context) {
+		authorization := c.GetHeader("Authorization")
+		if optional && authorization == "" {
+			c.Next()
+			return
+		}
+		claims, err := parseToken(authorization)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+		if err := checkTokenTenant(c.Request.Context(), claims); err != nil {
+			respondServerError(c, err)
+			return
+		}
+
+		c.Set(ContextKeyActorID, claims.Subject)
+		c.Set(ContextKeyRoles, claims.Roles)
+		c.Request = c.Request.WithContext(WithAuthClaims(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// 接口需要的角色
+const (
+	// RoleTagWrite 可以创建、修改、导入标签以及关联标签到实体
+	RoleTagWrite = "tag:write"
+	// RoleTagDelete 可以删除标签
+	RoleTagDelete = "tag:delete"
+	// RoleAdmin 可以执行重建索引等运维操作
+	RoleAdmin = "admin"
+)
+
+// RequireRole 要求操作人至少拥有 roles 中的一个角色，否则返回 403，需要放在 JWTMiddleware 之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if hasAnyRole(c.GetStringSlice(ContextKeyRoles), roles...) {
+			c.Next()
+			return
+		}
+
+		respondError(c, http.StatusForbidden, "insufficient role")
+	}
+}
+
+// rolesContextKey 请求的 context 中保存操作人角色的键
+type rolesContextKey struct{}
+
+// HasRole 判断 context 中的操作人是否拥有 role，未认证时返回 false
+func HasRole(ctx context.Context, role string) bool {
+	actorRoles, _ := ctx.Value(rolesContextKey{}).([]string)
+	return hasAnyRole(actorRoles, role)
+}
+
+// hasAnyRole 判断 actorRoles 中是否至少有 roles 中的一个角色
+func hasAnyRole(actorRoles []string, roles ...string) bool {
+	for _, role := range roles {
+		for _, actorRole := range actorRoles {
+			if actorRole == role {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 服务配置，通过环境变量读取
+type Config struct {
+	// DBMaxOpenConns 最大打开的连接数
+	DBMaxOpenConns int
+	// DBMaxIdleConns 最大空闲连接数
+	DBMaxIdleConns int
+	// DBConnMaxLifetime 连接的最长存活时间
+	DBConnMaxLifetime time.Duration
+	// QueryTimeout 单次调用 MySQL 或 ES 的超时时间
+	QueryTimeout time.Duration
+	// TagHistoryLimit 每个标签最多保留的改名记录数
+	TagHistoryLimit int
+	// IdempotencyTTL 幂等记录的有效期
+	IdempotencyTTL time.Duration
+	// MigrationsDir 数据库迁移文件所在的目录
+	MigrationsDir string
+	// EntityCountCacheTTL 标签关联实体数量的缓存时间，为 0 时不缓存
+	EntityCountCacheTTL time.Duration
+	// RelatedTagsCacheTTL 相关标签的缓存时间，为 0 时不缓存
+	RelatedTagsCacheTTL time.Duration
+	// TagSuggestionsCacheTTL 实体推荐标签的缓存时间，为 0 时不缓存
+	TagSuggestionsCacheTTL time.Duration
+	// TagCacheSize 按 ID 查询标签的进程内缓存最多保存的标签数量，为 0 时不缓存
+	TagCacheSize int
+	// TagCacheTTL 标签在进程内缓存中的有效期，其它实例修改标签后最多经过这段时间才能读到，为 0 时不缓存
+	TagCacheTTL time.Duration
+	// JWTSecret 校验 JWT 签名的密钥
+	JWTSecret string
+	// DefaultEntityType 请求中没有传入 entity_type 时使用的实体类型，为空时必须传入 entity_type
+	DefaultEntityType string
+	// MaxTagsPerEntity 每个实体最多关联的标签数量，为 0 时不限制
+	MaxTagsPerEntity int
+	// LinkRestoreWindow 取消关联后可以恢复的时间，超过后由清理任务彻底删除
+	LinkRestoreWindow time.Duration
+	// ESAddresses ES 节点的地址，排在前面的地址优先使用，不可用时使用后面的地址
+	ESAddresses []string
+	// ESIndex 写入标签文档的索引或别名
+	ESIndex string
+	// ESSearchIndex 搜索标签时读取的索引或别名，重建索引时指向旧索引，完成后切换到新索引
+	ESSearchIndex string
+	// ESSearchMatch 搜索标签时默认的匹配方式，prefix 或 infix
+	ESSearchMatch string
+	// SearchMinKeywordLength 搜索标签的关键字最少的字符数，更短的关键字不查询 ES，为 0 时不限制
+	SearchMinKeywordLength int
+	// ESIndexMaxAttempts 写入标签文档失败时最多尝试的次数，包括第一次请求
+	ESIndexMaxAttempts int
+	// ESIndexRetryBackoff 第一次重试前等待的时间，之后每次翻倍
+	ESIndexRetryBackoff time.Duration
+	// OTLPEndpoint 链路数据的上报地址，为空时不上报
+	OTLPEndpoint string
+	// MaxBodyBytes 普通接口的请求体大小上限，批量和导入接口使用各自的上限
+	MaxBodyBytes int64
+	// MaxJSONDepth JSON 请求体的最大嵌套层数，为 0 时不检查
+	MaxJSONDepth int
+	// CORS 跨域请求的配置，默认不允许任何来源
+	CORS CORSConfig
+	// PprofAddr pprof 服务监听的地址，例如 127.0.0.1:6060，为空时不启动
+	PprofAddr string
+	// GRPCAddr gRPC 服务监听的地址，例如 :9801，为空时不启动
+	GRPCAddr string
+	// RedisAddr 缓存搜索结果的 Redis 地址，例如 127.0.0.1:6379，为空时不缓存
+	RedisAddr     string
+	RedisPassword string
+	// SearchCacheTTL 搜索结果的缓存时间，为 0 时不缓存
+	SearchCacheTTL time.Duration
+}
+
+// LoadConfig 从环境变量读取配置，未设置的项使用默认值
+func LoadConfig() (*Config, error) {
+	var err error
+	conf := &Config{}
+
+	if conf.DBMaxOpenConns, err = getEnvInt("DB_MAX_OPEN_CONNS", 50); err != nil {
+		return nil, err
+	}
+	if conf.DBMaxIdleConns, err = getEnvInt("DB_MAX_IDLE_CONNS", 10); err != nil {
+		return nil, err
+	}
+	if conf.DBConnMaxLifetime, err = getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if conf.QueryTimeout, err = getEnvDuration("QUERY_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if conf.TagHistoryLimit, err = getEnvInt("TAG_HISTORY_LIMIT", 20); err != nil {
+		return nil, err
+	}
+	if conf.IdempotencyTTL, err = getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	conf.MigrationsDir = getEnvString("MIGRATIONS_DIR", "migrations")
+	if conf.EntityCountCacheTTL, err = getEnvDuration("ENTITY_COUNT_CACHE_TTL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if conf.RelatedTagsCacheTTL, err = getEnvDuration("RELATED_TAGS_CACHE_TTL", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if conf.TagSuggestionsCacheTTL, err = getEnvDuration("TAG_SUGGESTIONS_CACHE_TTL", time.Minute); err != nil {
+		return nil, err
+	}
+	if conf.TagCacheSize, err = getEnvInt("TAG_CACHE_SIZE", 10000); err != nil {
+		return nil, err
+	}
+	if conf.TagCacheSize < 0 {
+		return nil, fmt.Errorf("invalid TAG_CACHE_SIZE: %d", conf.TagCacheSize)
+	}
+	if conf.TagCacheTTL, err = getEnvDuration("TAG_CACHE_TTL", time.Minute); err != nil {
+		return nil, err
+	}
+
+	conf.DefaultEntityType = getEnvString("DEFAULT_ENTITY_TYPE", "")
+	if conf.DefaultEntityType != "" && !entityTypePattern.MatchString(conf.DefaultEntityType) {
+		return nil, fmt.Errorf("invalid DEFAULT_ENTITY_TYPE: %s", conf.DefaultEntityType)
+	}
+	if conf.MaxTagsPerEntity, err = getEnvInt("MAX_TAGS_PER_ENTITY", 30); err != nil {
+		return nil, err
+	}
+	if conf.MaxTagsPerEntity < 0 {
+		return nil, fmt.Errorf("invalid MAX_TAGS_PER_ENTITY: %d", conf.MaxTagsPerEntity)
+	}
+	if conf.LinkRestoreWindow, err = getEnvDuration("LINK_RESTORE_WINDOW", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if conf.LinkRestoreWindow < 0 {
+		return nil, fmt.Errorf("invalid LINK_RESTORE_WINDOW: %s", conf.LinkRestoreWindow)
+	}
+
+	conf.ESAddresses = getEnvList("ES_ADDRESSES", []string{"http://localhost:9200"})
+	if len(conf.ESAddresses) == 0 {
+		return nil, fmt.Errorf("invalid ES_ADDRESSES: %s", os.Getenv("ES_ADDRESSES"))
+	}
+	conf.ESIndex = getEnvString("ES_INDEX", "test")
+	conf.ESSearchIndex = getEnvString("ES_SEARCH_INDEX", conf.ESIndex)
+	conf.ESSearchMatch = getEnvString("ES_SEARCH_MATCH", ESSearchMatchPrefix)
+	if !isValidESSearchMatch(conf.ESSearchMatch) {
+		return nil, fmt.Errorf("invalid ES_SEARCH_MATCH: %s", conf.ESSearchMatch)
+	}
+	if conf.SearchMinKeywordLength, err = getEnvInt("SEARCH_MIN_KEYWORD_LENGTH", 2); err != nil {
+		return nil, err
+	}
+	if conf.SearchMinKeywordLength < 0 {
+		return nil, fmt.Errorf("invalid SEARCH_MIN_KEYWORD_LENGTH: %d", conf.SearchMinKeywordLength)
+	}
+	if conf.ESIndexMaxAttempts, err = getEnvInt("ES_INDEX_MAX_ATTEMPTS", 3); err != nil {
+		return nil, err
+	}
+	if conf.ESIndexMaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid ES_INDEX_MAX_ATTEMPTS: %d", conf.ESIndexMaxAttempts)
+	}
+	if conf.ESIndexRetryBackoff, err = getEnvDuration("ES_INDEX_RETRY_BACKOFF", 100*time.Millisecond); err != nil {
+		return nil, err
+	}
+
+	conf.OTLPEndpoint = getEnvString("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+
+	maxBodyBytes, err := getEnvInt("MAX_BODY_BYTES", int(DefaultMaxBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	if maxBodyBytes <= 0 {
+		return nil, fmt.Errorf("invalid MAX_BODY_BYTES: %d", maxBodyBytes)
+	}
+	conf.MaxBodyBytes = int64(maxBodyBytes)
+	if conf.MaxJSONDepth, err = getEnvInt("MAX_JSON_DEPTH", DefaultMaxJSONDepth); err != nil {
+		return nil, err
+	}
+	if conf.MaxJSONDepth < 0 {
+		return nil, fmt.Errorf("invalid MAX_JSON_DEPTH: %d", conf.MaxJSONDepth)
+	}
+
+	conf.CORS.AllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", nil)
+	conf.CORS.AllowedMethods = getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"})
+	conf.CORS.AllowedHeaders = getEnvList("CORS_ALLOWED_HEADERS", []string{"Content-Type", "Authorization", "X-Tenant-Id", "Idempotency-Key"})
+	if conf.CORS.AllowCredentials, err = getEnvBool("CORS_ALLOW_CREDENTIALS", false); err != nil {
+		return nil, err
+	}
+	// 允许任意来源携带凭证时，任何网站都可以使用用户的凭证调用接口
+	if conf.CORS.AllowCredentials {
+		for _, origin := range conf.CORS.AllowedOrigins {
+			if origin == "*" {
+				return nil, fmt.Errorf("CORS_ALLOW_CREDENTIALS cannot be used with CORS_ALLOWED_ORIGINS=*")
+			}
+		}
+	}
+
+	conf.PprofAddr = getEnvString("PPROF_ADDR", "")
+	conf.GRPCAddr = getEnvString("GRPC_ADDR", "")
+
+	conf.RedisAddr = getEnvString("REDIS_ADDR", "")
+	conf.RedisPassword = getEnvString("REDIS_PASSWORD", "")
+	if conf.SearchCacheTTL, err = getEnvDuration("SEARCH_CACHE_TTL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if conf.SearchCacheTTL < 0 {
+		return nil, fmt.Errorf("invalid SEARCH_CACHE_TTL: %s", conf.SearchCacheTTL)
+	}
+
+	// 没有密钥时无法校验 token，不允许启动
+	conf.JWTSecret = getEnvString("JWT_SECRET", "")
+	if conf.JWTSecret == "" {
+		return nil, fmt.Errorf("JWT_SECRET is required")
+	}
+
+	return conf, nil
+}
+
+// getEnvInt 读取整数类型的环境变量
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, err)
+	}
+	return n, nil
+}
+
+// getEnvDuration 读取时长类型的环境变量，格式同 time.ParseDuration，例如 30s、5m
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, err)
+	}
+	return d, nil
+}
+
+// getEnvBool 读取布尔类型的环境变量，格式同 strconv.ParseBool，例如 true、false、1、0
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", key, err)
+	}
+	return b, nil
+}
+
+// getEnvList 读取以逗号分隔的列表类型的环境变量，忽略每项两端的空白和空项
+func getEnvList(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvString 读取字符串类型的环境变量
+func getEnvString(key string, defaultValue string) string {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsPreflightMaxAge 浏览器缓存预检结果的时间，单位为秒
+const corsPreflightMaxAge = 600
+
+// CORSConfig 跨域请求的配置
+type CORSConfig struct {
+	// AllowedOrigins 允许跨域访问的来源，例如 https://app.example.com，* 表示允许任意来源，为空时不允许任何来源
+	AllowedOrigins []string
+	// AllowedMethods 预检请求中允许的方法
+	AllowedMethods []string
+	// AllowedHeaders 预检请求中允许的请求头
+	AllowedHeaders []string
+	// AllowCredentials 是否允许携带 Cookie 和 Authorization 等凭证
+	AllowCredentials bool
+}
+
+// CORSMiddleware 处理跨域请求。来源在 AllowedOrigins 中时返回 Access-Control-Allow-Origin 等响应头，
+// 否则不返回，由浏览器拦截响应。预检请求 (带有 Access-Control-Request-Method 的 OPTIONS) 在这里直接返回，
+// 不经过租户和认证中间件，来源不允许时返回 403。需要通过 engine.Use 注册，没有对应路由的 OPTIONS 请求才会经过
+func CORSMiddleware(conf CORSConfig) gin.HandlerFunc {
+	allowAny := false
+	allowedOrigins := make(map[string]bool, len(conf.AllowedOrigins))
+	for _, origin := range conf.AllowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowedOrigins[origin] = true
+	}
+	allowMethods := strings.Join(conf.AllowedMethods, ", ")
+	allowHeaders := strings.Join(conf.AllowedHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		// 响应头与 Origin 有关，共享缓存需要按 Origin 区分
+		c.Writer.Header().Add("Vary", "Origin")
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !allowAny && !allowedOrigins[origin] {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// 允许任意来源时也返回请求的来源而不是 *，携带凭证的请求不接受 *
+		c.Header("Access-Control-Allow-Origin", origin)
+		if conf.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Methods", allowMethods)
+		c.Header("Access-Control-Allow-Headers", allowHeaders)
+		c.Header("Access-Control-Max-Age", strconv.Itoa(corsPreflightMaxAge))
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// mysqlErrDupEntry 违反唯一键约束时 MySQL 返回的错误码
+const mysqlErrDupEntry = 1062
+
+// isDuplicateKeyErr 判断是否为违反唯一键约束的错误
+func isDuplicateKeyErr(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDupEntry
+}
+
+// dbGet 查询单行记录，单次调用的超时时间由 config.QueryTimeout 控制
+func dbGet(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return mysqlDB.GetContext(ctx, dest, query, args...)
+}
+
+// dbSelect 查询多行记录，单次调用的超时时间由 config.QueryTimeout 控制
+func dbSelect(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return mysqlDB.SelectContext(ctx, dest, query, args...)
+}
+
+// dbExec 执行写入语句，单次调用的超时时间由 config.QueryTimeout 控制
+func dbExec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return mysqlDB.ExecContext(ctx, query, args...)
+}
+
+// withTx 在事务中执行 fn，fn 返回错误时回滚，否则提交
+func withTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := mysqlDB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// txGet 在事务中查询单行记录，单次调用的超时时间由 config.QueryTimeout 控制
+func txGet(ctx context.Context, tx *sqlx.Tx, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return tx.GetContext(ctx, dest, query, args...)
+}
+
+// txSelect 在事务中查询多行记录，单次调用的超时时间由 config.QueryTimeout 控制
+func txSelect(ctx context.Context, tx *sqlx.Tx, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return tx.SelectContext(ctx, dest, query, args...)
+}
+
+// txExec 在事务中执行写入语句，单次调用的超时时间由 config.QueryTimeout 控制
+func txExec(ctx context.Context, tx *sqlx.Tx, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	return tx.ExecContext(ctx, query, args...)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// entityCountCacheSweepSize 缓存条目超过该数量时，写入前先清理过期的条目
+const entityCountCacheSweepSize = 10000
+
+// entityCountCacheEntry 缓存的关联实体数量
+type entityCountCacheEntry struct {
+	Count    int
+	CachedAt time.Time
+}
+
+// entityCountCache 标签关联实体数量的进程内缓存，热门标签在有效期内不需要重复 count
+var entityCountCache = struct {
+	sync.Mutex
+	entries map[string]*entityCountCacheEntry
+}{entries: make(map[string]*entityCountCacheEntry)}
+
+// entityCountCacheKey 返回缓存的键，需要区分租户和实体类型
+func entityCountCacheKey(ctx context.Context, tagID int, entityType string) string {
+	return fmt.Sprintf("%s:%d:%s", TenantIDFromContext(ctx), tagID, entityType)
+}
+
+// InvalidateTagEntityCounts 删除当前租户 tagIDs 的所有实体数量缓存，包括按实体类型统计的缓存。
+// 用于合并标签这类一次改变大量关联的操作，普通的关联和取消关联仍然依赖缓存过期
+func InvalidateTagEntityCounts(ctx context.Context, tagIDs ...int) {
+	prefixes := make([]string, 0, len(tagIDs))
+	for _, tagID := range tagIDs {
+		prefixes = append(prefixes, entityCountCacheKey(ctx, tagID, ""))
+	}
+
+	entityCountCache.Lock()
+	defer entityCountCache.Unlock()
+	for key := range entityCountCache.entries {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(entityCountCache.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// CountTagEntities 查询标签关联的实体数量，entityType 不为空时只统计该类型的实体，
+// config.EntityCountCacheTTL 内会返回缓存的结果
+func CountTagEntities(ctx context.Context, tagID int, entityType string) (*entityCountCacheEntry, error) {
+	key := entityCountCacheKey(ctx, tagID, entityType)
+	now := time.Now()
+
+	if config.EntityCountCacheTTL > 0 {
+		entityCountCache.Lock()
+		entry, ok := entityCountCache.entries[key]
+		if ok && now.Sub(entry.CachedAt) >= config.EntityCountCacheTTL {
+			delete(entityCountCache.entries, key)
+			ok = false
+		}
+		entityCountCache.Unlock()
+
+		if ok {
+			return entry, nil
+		}
+	}
+
+	query := "select count(*) from entity_tag_tbl where tenant_id = ? and tag_id = ? and deleted_at is null"
+	args := []interface{}{TenantIDFromContext(ctx), tagID}
+	if entityType != "" {
+		query += " and entity_type = ?"
+		args = append(args, entityType)
+	}
+
+	var count int
+	queryErr := dbGet(ctx, &count, query, args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	entry := &entityCountCacheEntry{Count: count, CachedAt: now}
+	if config.EntityCountCacheTTL > 0 {
+		entityCountCache.Lock()
+		if len(entityCountCache.entries) >= entityCountCacheSweepSize {
+			for k, e := range entityCountCache.entries {
+				if now.Sub(e.CachedAt) >= config.EntityCountCacheTTL {
+					delete(entityCountCache.entries, k)
+				}
+			}
+		}
+		entityCountCache.entries[key] = entry
+		entityCountCache.Unlock()
+	}
+
+	return entry, nil
+}
+
+// OnTagEntitiesCount 查询标签关联的实体数量，cache_age_seconds 为结果缓存的时长，实时查询时为 0
+// @Summary 查询标签关联的实体数量
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param entity_type query string false "只统计该类型的实体"
+// @Success 200 {object} APIResponse{data=object{tag_id=int,count=int,cache_age_seconds=int}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/{id}/entities/count [get]
+func OnTagEntitiesCount(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	entityType := c.Query("entity_type")
+	if entityType != "" && !entityTypePattern.MatchString(entityType) {
+		respondError(c, http.StatusBadRequest, "invalid entity_type")
+		return
+	}
+
+	// 标签不存在时返回 404，和没有关联任何实体区分开
+	if _, queryErr := GetTagByID(c.Request.Context(), tagID); queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			respondError(c, http.StatusNotFound, "tag not found")
+			return
+		}
+		respondServerError(c, queryErr)
+		return
+	}
+
+	entry, err := CountTagEntities(c.Request.Context(), tagID, entityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"tag_id":            tagID,
+		"count":             entry.Count,
+		"cache_age_seconds": int(time.Since(entry.CachedAt).Seconds()),
+	})
+}
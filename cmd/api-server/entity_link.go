@@ -0,0 +1,996 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxBatchLinkTags 批量关联时每次请求最多可以传入的标签数量
+const maxBatchLinkTags = 100
+
+// entityTypePattern 实体类型只能包含字母、数字、下划线、点和中划线，长度与 entity_type 字段一致
+var entityTypePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+// validateEntityType 校验实体类型，为空时返回 config.DefaultEntityType，没有配置 DEFAULT_ENTITY_TYPE 时返回 400，
+// 避免不同业务的实体在未传入类型时混在同一个类型下
+func validateEntityType(entityType string) (string, error) {
+	if entityType == "" {
+		if config.DefaultEntityType == "" {
+			return "", newAPIError(http.StatusBadRequest, "entity_type is required")
+		}
+		return config.DefaultEntityType, nil
+	}
+	if !entityTypePattern.MatchString(entityType) {
+		return "", newAPIError(http.StatusBadRequest, "invalid entity_type")
+	}
+	return entityType, nil
+}
+
+// 批量关联时每个标签的处理结果
+const (
+	LinkStatusLinked        = "linked"
+	LinkStatusAlreadyLinked = "already_linked"
+	LinkStatusTagNotFound   = "tag_not_found"
+)
+
+// LinkEntityBatchReqBody 批量关联标签到实体的请求体
+type LinkEntityBatchReqBody struct {
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	EntityID   int    `json:"entity_id"`
+	TagIDs     []int  `json:"tag_ids"`
+	// Atomic 为 true 时只要有一个标签不存在就不关联任何标签
+	Atomic bool `json:"atomic"`
+}
+
+// LinkEntityResult 单个标签的关联结果
+type LinkEntityResult struct {
+	TagID  int    `json:"tag_id"`
+	Status string `json:"status"`
+	LinkID int    `json:"link_id,omitempty"`
+}
+
+// selectEntityLinks 在事务中查询实体与指定标签之间已经存在的关联，返回 tag_id 到关联记录的映射
+func selectEntityLinks(ctx context.Context, tx *sqlx.Tx, entityType string, entityID int, tagIDs []int) (map[int]*EntityTag, error) {
+	query, args, err := sqlx.In(
+		"select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and tag_id in (?) and deleted_at is null",
+		TenantIDFromContext(ctx), entityType, entityID, tagIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entityTags := []*EntityTag{}
+	if err := txSelect(ctx, tx, &entityTags, query, args...); err != nil {
+		return nil, err
+	}
+
+	links := make(map[int]*EntityTag, len(entityTags))
+	for _, entityTag := range entityTags {
+		links[entityTag.TagID] = entityTag
+	}
+	return links, nil
+}
+
+// selectExistTagIDs 查询 tagIDs 里存在且未删除的标签，tx 不为 nil 时在事务中查询
+func selectExistTagIDs(ctx context.Context, tx *sqlx.Tx, tagIDs []int) (map[int]bool, error) {
+	query, args, err := sqlx.In(
+		"select id from tag_tbl where tenant_id = ? and id in (?) and deleted_at is null",
+		TenantIDFromContext(ctx), tagIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	existTagIDs := []int{}
+	if tx != nil {
+		err = txSelect(ctx, tx, &existTagIDs, query, args...)
+	} else {
+		err = dbSelect(ctx, &existTagIDs, query, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[int]bool, len(existTagIDs))
+	for _, tagID := range existTagIDs {
+		exists[tagID] = true
+	}
+	return exists, nil
+}
+
+// newTagNotFoundError 返回 404 错误，detail 中列出不存在的标签
+func newTagNotFoundError(tagIDs []int, exists map[int]bool) *APIError {
+	notFoundTagIDs := []int{}
+	for _, tagID := range tagIDs {
+		if !exists[tagID] {
+			notFoundTagIDs = append(notFoundTagIDs, tagID)
+		}
+	}
+
+	apiErr := newAPIError(http.StatusNotFound, "tag not found")
+	apiErr.Detail = gin.H{"tag_ids": notFoundTagIDs}
+	return apiErr
+}
+
+// newEntityTagLimitError 返回 422 错误，detail 中包含实体当前关联的标签数量和上限
+func newEntityTagLimitError(count, limit int) *APIError {
+	apiErr := newAPIError(http.StatusUnprocessableEntity, "entity tag limit exceeded")
+	apiErr.Detail = gin.H{"count": count, "limit": limit}
+	return apiErr
+}
+
+// checkEntityTagLimitTx 在事务中统计实体已经关联的标签数量，再关联 adding 个标签会超过 config.MaxTagsPerEntity 时返回 422。
+// 关联到已删除标签的关联不计入。调用前需要通过 lockEntityTx 锁住实体，否则并发关联时可能同时通过检查
+func checkEntityTagLimitTx(ctx context.Context, tx *sqlx.Tx, entityType string, entityID int, adding int) error {
+	if config.MaxTagsPerEntity <= 0 || adding == 0 {
+		return nil
+	}
+
+	var count int
+	queryErr := txGet(
+		ctx, tx, &count,
+		"select count(*) from entity_tag_tbl et join tag_tbl t on t.id = et.tag_id and t.deleted_at is null where et.tenant_id = ? and et.entity_type = ? and et.entity_id = ? and et.deleted_at is null",
+		TenantIDFromContext(ctx), entityType, entityID,
+	)
+	if queryErr != nil {
+		return queryErr
+	}
+	if count+adding > config.MaxTagsPerEntity {
+		return newEntityTagLimitError(count, config.MaxTagsPerEntity)
+	}
+	return nil
+}
+
+// nextEntityTagPositionTx 在事务中返回实体下一个关联的 position，即当前最大值加 1。
+// 调用前需要通过 lockEntityTx 锁住实体，否则并发关联可能得到相同的 position
+func nextEntityTagPositionTx(ctx context.Context, tx *sqlx.Tx, entityType string, entityID int) (int, error) {
+	var position int
+	queryErr := txGet(
+		ctx, tx, &position,
+		"select coalesce(max(position), 0) + 1 from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and deleted_at is null",
+		TenantIDFromContext(ctx), entityType, entityID,
+	)
+	return position, queryErr
+}
+
+// insertEntityLinks 在事务中使用一条多行 insert 写入关联，已经存在的关联会被忽略，
+// 新关联按 tagIDs 的顺序排在实体已有标签的后面，来源为 api，操作人为 JWT 中的操作人。
+// 取消后还没有被清理的关联会被恢复，保留原来的附加信息和 created_at。
+// tagIDs 需要是实体还没有关联的标签，会全部记录为新建关联的变更。调用前需要通过 lockEntityTx 锁住实体
+func insertEntityLinks(ctx context.Context, tx *sqlx.Tx, entityType string, entityID int, tagIDs []int) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+
+	position, err := nextEntityTagPositionTx(ctx, tx, entityType, entityID)
+	if err != nil {
+		return err
+	}
+
+	placements := make([]entityTagPlacement, 0, len(tagIDs))
+	placeholders := make([]string, 0, len(tagIDs))
+	insertArgs := make([]interface{}, 0, len(tagIDs)*6)
+	for index, tagID := range tagIDs {
+		placements = append(placements, entityTagPlacement{EntityID: entityID, TagID: tagID, Position: position + index})
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?)")
+		insertArgs = append(insertArgs, TenantIDFromContext(ctx), entityType, entityID, tagID, position+index, ActorIDFromContext(ctx))
+	}
+
+	if _, err := restoreDeletedLinksTx(ctx, tx, entityType, placements); err != nil {
+		return err
+	}
+
+	_, execErr := txExec(
+		ctx, tx,
+		"insert ignore into entity_tag_tbl (tenant_id, entity_type, entity_id, tag_id, position, added_by) values "+strings.Join(placeholders, ", "),
+		insertArgs...,
+	)
+	if execErr != nil {
+		return execErr
+	}
+
+	return recordEntityTagChangesTx(ctx, tx, EntityTagChangeLink, entityType, entityTagRefs(entityID, tagIDs))
+}
+
+// renumberEntityTagsTx 在事务中按 tagIDs 的顺序把实体关联的 position 重新编号为 1 到 len(tagIDs)，
+// 不在 tagIDs 中的关联（例如关联到已删除标签的关联）排在最后。调用前需要通过 lockEntityTx 锁住实体
+func renumberEntityTagsTx(ctx context.Context, tx *sqlx.Tx, entityType string, entityID int, tagIDs []int) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In(
+		"update entity_tag_tbl set position = if(field(tag_id, ?) = 0, ?, field(tag_id, ?)) where tenant_id = ? and entity_type = ? and entity_id = ? and deleted_at is null",
+		tagIDs, len(tagIDs)+1, tagIDs, TenantIDFromContext(ctx), entityType, entityID,
+	)
+	if err != nil {
+		return err
+	}
+	_, execErr := txExec(ctx, tx, query, args...)
+	return execErr
+}
+
+// LinkEntityTags 在一个事务中把多个标签关联到实体，已经存在的关联会被跳过，
+// 返回的结果与 tagIDs 的顺序一致。atomic 为 true 时有标签不存在会返回 404 错误且不写入任何关联，
+// 错误的 detail 中包含不存在的 tag_ids。新关联的标签会使实体超过 MAX_TAGS_PER_ENTITY 时返回 422，不写入任何关联
+func LinkEntityTags(ctx context.Context, entityType string, entityID int, tagIDs []int, atomic bool) ([]*LinkEntityResult, error) {
+	results := make([]*LinkEntityResult, 0, len(tagIDs))
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		// 锁住实体，统计已关联的标签数量时不会有并发写入
+		if err := lockEntityTx(ctx, tx, entityType, entityID); err != nil {
+			return err
+		}
+
+		// 一次查询出所有存在的标签
+		exists, err := selectExistTagIDs(ctx, tx, tagIDs)
+		if err != nil {
+			return err
+		}
+
+		// atomic 模式下有标签不存在时不写入任何关联
+		if atomic && len(exists) < len(tagIDs) {
+			return newTagNotFoundError(tagIDs, exists)
+		}
+
+		links, err := selectEntityLinks(ctx, tx, entityType, entityID, tagIDs)
+		if err != nil {
+			return err
+		}
+
+		// 需要新建关联的标签
+		missing := []int{}
+		for _, tagID := range tagIDs {
+			if exists[tagID] && links[tagID] == nil {
+				missing = append(missing, tagID)
+			}
+		}
+
+		if err := checkEntityTagLimitTx(ctx, tx, entityType, entityID, len(missing)); err != nil {
+			return err
+		}
+
+		if err := insertEntityLinks(ctx, tx, entityType, entityID, missing); err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			auditErr := InsertAuditLogTx(ctx, tx, &AuditLog{
+				Action:     AuditActionEntityLink,
+				EntityType: AuditEntityTypeEntity,
+				EntityID:   entityID,
+				Metadata:   gin.H{"entity_type": entityType, "tag_ids": missing},
+			})
+			if auditErr != nil {
+				return auditErr
+			}
+		}
+
+		newLinks, err := selectEntityLinks(ctx, tx, entityType, entityID, tagIDs)
+		if err != nil {
+			return err
+		}
+
+		for _, tagID := range tagIDs {
+			result := &LinkEntityResult{TagID: tagID}
+			switch {
+			case !exists[tagID]:
+				result.Status = LinkStatusTagNotFound
+			case links[tagID] != nil:
+				result.Status = LinkStatusAlreadyLinked
+				result.LinkID = links[tagID].LinkID
+			default:
+				result.Status = LinkStatusLinked
+				if link := newLinks[tagID]; link != nil {
+					result.LinkID = link.LinkID
+				}
+			}
+			results = append(results, result)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	return results, nil
+}
+
+// normalizeTagIDs 校验传入的标签 ID 并去掉重复的 ID，保持传入的顺序
+func normalizeTagIDs(tagIDs []int) ([]int, error) {
+	if len(tagIDs) > maxBatchLinkTags {
+		return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("too many tag_ids, max %d", maxBatchLinkTags))
+	}
+
+	normalized := make([]int, 0, len(tagIDs))
+	seen := make(map[int]bool, len(tagIDs))
+	for _, tagID := range tagIDs {
+		if tagID <= 0 {
+			return nil, newAPIError(http.StatusBadRequest, "invalid tag_ids")
+		}
+		if seen[tagID] {
+			continue
+		}
+		seen[tagID] = true
+		normalized = append(normalized, tagID)
+	}
+	return normalized, nil
+}
+
+// OnLinkEntityBatch 批量关联标签到实体
+// @Summary 批量关联标签到实体
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body LinkEntityBatchReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,results=[]LinkEntityResult}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Failure 422 {object} APIResponse{error=APIError{detail=object{count=int,limit=int}}}
+// @Router /api/tag/link_entity/batch [post]
+func OnLinkEntityBatch(c *gin.Context) {
+	var reqBody LinkEntityBatchReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	if reqBody.EntityID == 0 || len(reqBody.TagIDs) == 0 {
+		respondError(c, http.StatusBadRequest, "request params error")
+		return
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tagIDs, err := normalizeTagIDs(reqBody.TagIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	results, err := LinkEntityTags(c.Request.Context(), entityType, reqBody.EntityID, tagIDs, reqBody.Atomic)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"entity_type": entityType,
+		"results":     results,
+	})
+}
+
+// lockEntityTx 在事务中锁住实体，事务提交前修改同一实体标签的其它请求会等待
+func lockEntityTx(ctx context.Context, tx *sqlx.Tx, entityType string, entityID int) error {
+	return lockEntitiesTx(ctx, tx, entityType, []int{entityID})
+}
+
+// lockEntitiesTx 在事务中使用一条多行 insert 锁住多个实体，按 ID 顺序加锁，避免两个批量请求互相等待
+func lockEntitiesTx(ctx context.Context, tx *sqlx.Tx, entityType string, entityIDs []int) error {
+	sorted := append([]int{}, entityIDs...)
+	sort.Ints(sorted)
+
+	placeholders := make([]string, 0, len(sorted))
+	args := make([]interface{}, 0, len(sorted)*3)
+	for _, entityID := range sorted {
+		placeholders = append(placeholders, "(?, ?, ?)")
+		args = append(args, TenantIDFromContext(ctx), entityType, entityID)
+	}
+
+	_, execErr := txExec(
+		ctx, tx,
+		"insert into entity_lock_tbl (tenant_id, entity_type, entity_id) values "+strings.Join(placeholders, ", ")+" on duplicate key update entity_id = entity_id",
+		args...,
+	)
+	return execErr
+}
+
+// ReplaceEntityTags 在一个事务中把实体关联的标签替换为 tagIDs，取消不在列表中的关联并写入新的关联，
+// 返回被取消关联的 ID。同一实体的并发替换通过 entity_lock_tbl 的行锁串行执行，有标签不存在时返回 404 错误且不做任何修改，
+// tagIDs 的数量超过 MAX_TAGS_PER_ENTITY 时返回 422。替换后标签的顺序与 tagIDs 一致
+func ReplaceEntityTags(ctx context.Context, entityType string, entityID int, tagIDs []int) ([]int, error) {
+	tenantID := TenantIDFromContext(ctx)
+	removedLinkIDs := []int{}
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		// 锁住实体，事务提交前其它替换请求会在这里等待
+		if err := lockEntityTx(ctx, tx, entityType, entityID); err != nil {
+			return err
+		}
+
+		// 替换后实体只关联 tagIDs，在修改之前先检查整个列表的数量
+		if config.MaxTagsPerEntity > 0 && len(tagIDs) > config.MaxTagsPerEntity {
+			var count int
+			queryErr := txGet(
+				ctx, tx, &count,
+				"select count(*) from entity_tag_tbl et join tag_tbl t on t.id = et.tag_id and t.deleted_at is null where et.tenant_id = ? and et.entity_type = ? and et.entity_id = ? and et.deleted_at is null",
+				tenantID, entityType, entityID,
+			)
+			if queryErr != nil {
+				return queryErr
+			}
+			return newEntityTagLimitError(count, config.MaxTagsPerEntity)
+		}
+
+		if len(tagIDs) > 0 {
+			exists, err := selectExistTagIDs(ctx, tx, tagIDs)
+			if err != nil {
+				return err
+			}
+			if len(exists) < len(tagIDs) {
+				return newTagNotFoundError(tagIDs, exists)
+			}
+		}
+
+		linkedTagIDs := []int{}
+		selectErr := txSelect(
+			ctx, tx, &linkedTagIDs,
+			"select tag_id from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and deleted_at is null",
+			tenantID, entityType, entityID,
+		)
+		if selectErr != nil {
+			return selectErr
+		}
+
+		// 计算需要删除和新增的关联
+		wanted := make(map[int]bool, len(tagIDs))
+		for _, tagID := range tagIDs {
+			wanted[tagID] = true
+		}
+		linked := make(map[int]bool, len(linkedTagIDs))
+		removed := []int{}
+		for _, tagID := range linkedTagIDs {
+			linked[tagID] = true
+			if !wanted[tagID] {
+				removed = append(removed, tagID)
+			}
+		}
+		added := []int{}
+		for _, tagID := range tagIDs {
+			if !linked[tagID] {
+				added = append(added, tagID)
+			}
+		}
+
+		if len(removed) > 0 {
+			var err error
+			if removedLinkIDs, err = softDeleteEntityLinksTx(ctx, tx, entityType, entityTagRefs(entityID, removed)); err != nil {
+				return err
+			}
+			if err := recordEntityTagChangesTx(ctx, tx, EntityTagChangeUnlink, entityType, entityTagRefs(entityID, removed)); err != nil {
+				return err
+			}
+		}
+
+		if err := insertEntityLinks(ctx, tx, entityType, entityID, added); err != nil {
+			return err
+		}
+		if err := renumberEntityTagsTx(ctx, tx, entityType, entityID, tagIDs); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionEntityReplaceTags,
+			EntityType: AuditEntityTypeEntity,
+			EntityID:   entityID,
+			Metadata:   gin.H{"entity_type": entityType, "before": linkedTagIDs, "after": tagIDs},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return removedLinkIDs, nil
+}
+
+// ReplaceEntityTagsReqBody 替换实体标签的请求体
+type ReplaceEntityTagsReqBody struct {
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	// TagIDs 实体最终关联的全部标签，为空时清空实体的标签
+	TagIDs []int `json:"tag_ids"`
+}
+
+// OnPutEntityTags 把实体关联的标签替换为传入的标签列表，返回替换后的标签列表和被取消关联的 ID
+// @Summary 替换实体关联的标签
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "实体 ID"
+// @Param body body ReplaceEntityTagsReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,tags=[]LinkedTag,removed_link_ids=[]int}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Failure 422 {object} APIResponse{error=APIError{detail=object{count=int,limit=int}}}
+// @Router /api/entity/{id}/tags [put]
+func OnPutEntityTags(c *gin.Context) {
+	entityID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody ReplaceEntityTagsReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tagIDs, err := normalizeTagIDs(reqBody.TagIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	removedLinkIDs, err := ReplaceEntityTags(c.Request.Context(), entityType, entityID, tagIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tags, err := GetEntityTags(c.Request.Context(), entityType, entityID, EntityTagsOptions{})
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"entity_type":      entityType,
+		"tags":             tags,
+		"removed_link_ids": removedLinkIDs,
+	})
+}
+
+// ReorderEntityTags 在一个事务中按 tagIDs 的顺序重新设置实体关联标签的 position。tagIDs 必须与实体当前关联的
+// 未删除标签完全一致，否则返回 409，detail 中的 missing 为没有传入的标签，unexpected 为实体没有关联的标签。
+// 与关联接口使用同一个实体锁，重新排序期间新建的关联会等待排序完成后排在最后
+func ReorderEntityTags(ctx context.Context, entityType string, entityID int, tagIDs []int) error {
+	return withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := lockEntityTx(ctx, tx, entityType, entityID); err != nil {
+			return err
+		}
+
+		linkedTagIDs := []int{}
+		selectErr := txSelect(
+			ctx, tx, &linkedTagIDs,
+			`select et.tag_id from entity_tag_tbl et
+			join tag_tbl t on t.id = et.tag_id and t.deleted_at is null
+			where et.tenant_id = ? and et.entity_type = ? and et.entity_id = ? and et.deleted_at is null
+			order by et.position, et.id`,
+			TenantIDFromContext(ctx), entityType, entityID,
+		)
+		if selectErr != nil {
+			return selectErr
+		}
+
+		wanted := make(map[int]bool, len(tagIDs))
+		for _, tagID := range tagIDs {
+			wanted[tagID] = true
+		}
+		linked := make(map[int]bool, len(linkedTagIDs))
+		missing := []int{}
+		for _, tagID := range linkedTagIDs {
+			linked[tagID] = true
+			if !wanted[tagID] {
+				missing = append(missing, tagID)
+			}
+		}
+		unexpected := []int{}
+		for _, tagID := range tagIDs {
+			if !linked[tagID] {
+				unexpected = append(unexpected, tagID)
+			}
+		}
+		if len(missing) > 0 || len(unexpected) > 0 {
+			apiErr := newAPIError(http.StatusConflict, "tag_ids do not match the entity's tags")
+			apiErr.Detail = gin.H{"missing": missing, "unexpected": unexpected}
+			return apiErr
+		}
+
+		if err := renumberEntityTagsTx(ctx, tx, entityType, entityID, tagIDs); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionEntityReorderTags,
+			EntityType: AuditEntityTypeEntity,
+			EntityID:   entityID,
+			Metadata:   gin.H{"entity_type": entityType, "before": linkedTagIDs, "after": tagIDs},
+		})
+	})
+}
+
+// ReorderEntityTagsReqBody 调整实体标签顺序的请求体
+type ReorderEntityTagsReqBody struct {
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	// TagIDs 按新顺序排列的实体关联的全部标签
+	TagIDs []int `json:"tag_ids"`
+}
+
+// OnPutEntityTagsOrder 调整实体关联标签的顺序，返回排序后的标签列表
+// @Summary 调整实体标签的顺序
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "实体 ID"
+// @Param body body ReorderEntityTagsReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,tags=[]LinkedTag}}
+// @Failure 400 {object} APIResponse
+// @Failure 409 {object} APIResponse{error=APIError{detail=object{missing=[]int,unexpected=[]int}}}
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/entity/{id}/tags/order [put]
+func OnPutEntityTagsOrder(c *gin.Context) {
+	entityID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody ReorderEntityTagsReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	// 顺序列表中不允许重复的标签，不能像关联接口一样直接去重
+	if len(reqBody.TagIDs) > maxBatchLinkTags {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("too many tag_ids, max %d", maxBatchLinkTags))
+		return
+	}
+	seen := make(map[int]bool, len(reqBody.TagIDs))
+	for _, tagID := range reqBody.TagIDs {
+		if tagID <= 0 || seen[tagID] {
+			respondError(c, http.StatusBadRequest, "invalid tag_ids")
+			return
+		}
+		seen[tagID] = true
+	}
+
+	if err := ReorderEntityTags(c.Request.Context(), entityType, entityID, reqBody.TagIDs); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tags, err := GetEntityTags(c.Request.Context(), entityType, entityID, EntityTagsOptions{})
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"entity_type": entityType,
+		"tags":        tags,
+	})
+}
+
+// ClearEntityTags 在一个事务中取消实体关联的所有标签，返回被取消关联的 tag_id 和关联 ID，实体没有关联标签时返回空列表。
+// 与 ReplaceEntityTags 使用同一个实体锁
+func ClearEntityTags(ctx context.Context, entityType string, entityID int) ([]int, []int, error) {
+	tenantID := TenantIDFromContext(ctx)
+	removed, removedLinkIDs := []int{}, []int{}
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := lockEntityTx(ctx, tx, entityType, entityID); err != nil {
+			return err
+		}
+
+		selectErr := txSelect(
+			ctx, tx, &removed,
+			"select tag_id from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and deleted_at is null order by id",
+			tenantID, entityType, entityID,
+		)
+		if selectErr != nil {
+			return selectErr
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+
+		var err error
+		if removedLinkIDs, err = softDeleteEntityLinksTx(ctx, tx, entityType, entityTagRefs(entityID, removed)); err != nil {
+			return err
+		}
+		if err := recordEntityTagChangesTx(ctx, tx, EntityTagChangeUnlink, entityType, entityTagRefs(entityID, removed)); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionEntityClearTags,
+			EntityType: AuditEntityTypeEntity,
+			EntityID:   entityID,
+			Metadata:   gin.H{"entity_type": entityType, "before": removed},
+		})
+	})
+	if txErr != nil {
+		return nil, nil, txErr
+	}
+	return removed, removedLinkIDs, nil
+}
+
+// OnDeleteEntityTags 取消实体关联的所有标签，返回取消的数量、被取消关联的 tag_ids 和 link_ids，
+// 在 LINK_RESTORE_WINDOW 内可以通过 link_ids 恢复
+// @Summary 清空实体关联的标签
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "实体 ID"
+// @Param entity_type query string false "实体类型，不传时使用 DEFAULT_ENTITY_TYPE"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,count=int,tag_ids=[]int,link_ids=[]int}}
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/entity/{id}/tags [delete]
+func OnDeleteEntityTags(c *gin.Context) {
+	entityID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	entityType, err := validateEntityType(c.Query("entity_type"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	removed, removedLinkIDs, err := ClearEntityTags(c.Request.Context(), entityType, entityID)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"entity_type": entityType,
+		"count":       len(removed),
+		"tag_ids":     removed,
+		"link_ids":    removedLinkIDs,
+	})
+}
+
+// TagEntity 标签关联的实体
+type TagEntity struct {
+	LinkID     int    `db:"id" json:"link_id"`
+	EntityType string `db:"entity_type" json:"entity_type"`
+	EntityID   int    `db:"entity_id" json:"entity_id"`
+	// Metadata 关联的附加信息，没有时省略
+	Metadata  LinkMetadata `db:"metadata" json:"metadata,omitempty"`
+	Source    string       `db:"source" json:"source"`
+	AddedBy   string       `db:"added_by" json:"added_by"`
+	Weight    float64      `db:"weight" json:"weight"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time    `db:"updated_at" json:"updated_at"`
+}
+
+// OnTagEntities 按关联 ID 顺序分页列出标签关联的实体，传入 metadata_key 和 metadata_value 时
+// 只返回附加信息中该键的值等于 metadata_value 的关联，值按字符串比较，传入 source 时只返回该来源的关联
+// @Summary 查询标签关联的实体列表
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param entity_type query string false "只返回该类型的实体"
+// @Param metadata_key query string false "按关联附加信息过滤的键"
+// @Param metadata_value query string false "metadata_key 对应的值"
+// @Param source query string false "只返回该来源的关联，例如 manual"
+// @Param after_link_id query int false "上一页返回的 next_after_link_id"
+// @Param limit query int false "每页数量，默认 20，最大 100"
+// @Success 200 {object} APIResponse{data=object{entities=[]TagEntity,next_after_link_id=int}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/{id}/entities [get]
+func OnTagEntities(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	afterLinkID, ok := parseIntQuery(c, "after_link_id", 0)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(c, 20, 100)
+	if !ok {
+		return
+	}
+
+	// 标签不存在时返回 404，和没有关联任何实体区分开
+	if _, queryErr := GetTagByID(c.Request.Context(), tagID); queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			respondError(c, http.StatusNotFound, "tag not found")
+			return
+		}
+		respondServerError(c, queryErr)
+		return
+	}
+
+	query := "select id, entity_type, entity_id, metadata, source, added_by, weight, created_at, updated_at from entity_tag_tbl where tenant_id = ? and tag_id = ? and deleted_at is null"
+	args := []interface{}{TenantIDFromContext(c.Request.Context()), tagID}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		if !entityTypePattern.MatchString(entityType) {
+			respondError(c, http.StatusBadRequest, "invalid entity_type")
+			return
+		}
+		query += " and entity_type = ?"
+		args = append(args, entityType)
+	}
+	if metadataKey := c.Query("metadata_key"); metadataKey != "" {
+		if !linkMetadataKeyPattern.MatchString(metadataKey) {
+			respondError(c, http.StatusBadRequest, "invalid metadata_key")
+			return
+		}
+		query += " and json_unquote(json_extract(metadata, ?)) = ?"
+		args = append(args, "$."+metadataKey, c.Query("metadata_value"))
+	}
+	if source := c.Query("source"); source != "" {
+		if !linkSourcePattern.MatchString(source) {
+			respondError(c, http.StatusBadRequest, "invalid source")
+			return
+		}
+		query += " and source = ?"
+		args = append(args, source)
+	}
+	query += " and id > ? order by id limit ?"
+	args = append(args, afterLinkID, limit)
+
+	entities := []*TagEntity{}
+	selectErr := dbSelect(c.Request.Context(), &entities, query, args...)
+	if selectErr != nil {
+		respondServerError(c, selectErr)
+		return
+	}
+
+	// 没有更多数据时 next_after_link_id 为 0
+	nextAfterLinkID := 0
+	if len(entities) == limit {
+		nextAfterLinkID = entities[len(entities)-1].LinkID
+	}
+
+	respondOK(c, gin.H{
+		"entities":           entities,
+		"next_after_link_id": nextAfterLinkID,
+	})
+}
+
+// recentTagEntitiesCacheControl 最近关联的实体的 Cache-Control，标签详情页访问频繁，允许浏览器缓存 5 秒
+const recentTagEntitiesCacheControl = "private, max-age=5"
+
+// OnRecentTagEntities 按关联时间从新到旧分页列出标签最近关联的实体，用于标签详情页展示最新打上该标签的内容。
+// 关联时间相同时按关联 ID 从大到小排列，翻页时传入上一页返回的 next_before_created_at 和 next_before_link_id
+// @Summary 查询标签最近关联的实体
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param entity_type query string false "只返回该类型的实体"
+// @Param before_created_at query string false "上一页返回的 next_before_created_at"
+// @Param before_link_id query int false "上一页返回的 next_before_link_id，需要和 before_created_at 一起传入"
+// @Param limit query int false "每页数量，默认 20，最大 100"
+// @Success 200 {object} APIResponse{data=object{entities=[]TagEntity,next_before_created_at=string,next_before_link_id=int}}
+// @Header 200 {string} Cache-Control "private, max-age=5"
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/{id}/entities/recent [get]
+func OnRecentTagEntities(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	beforeLinkID, ok := parseIntQuery(c, "before_link_id", 0)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(c, 20, 100)
+	if !ok {
+		return
+	}
+
+	query := "select id, entity_type, entity_id, metadata, source, added_by, weight, created_at, updated_at from entity_tag_tbl where tenant_id = ? and tag_id = ? and deleted_at is null"
+	args := []interface{}{TenantIDFromContext(c.Request.Context()), tagID}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		if !entityTypePattern.MatchString(entityType) {
+			respondError(c, http.StatusBadRequest, "invalid entity_type")
+			return
+		}
+		query += " and entity_type = ?"
+		args = append(args, entityType)
+	}
+
+	// 游标由关联时间和关联 ID 组成，两者需要同时传入
+	beforeCreatedAt := c.Query("before_created_at")
+	if (beforeCreatedAt == "") != (beforeLinkID == 0) {
+		respondError(c, http.StatusBadRequest, "before_created_at and before_link_id must be used together")
+		return
+	}
+	if beforeCreatedAt != "" {
+		before, err := time.Parse(time.RFC3339, beforeCreatedAt)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid before_created_at")
+			return
+		}
+		query += " and (created_at < ? or (created_at = ? and id < ?))"
+		args = append(args, before.UTC(), before.UTC(), beforeLinkID)
+	}
+	query += " order by created_at desc, id desc limit ?"
+	args = append(args, limit)
+
+	// 标签不存在时返回 404，和没有关联任何实体区分开
+	if _, queryErr := GetTagByID(c.Request.Context(), tagID); queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			respondError(c, http.StatusNotFound, "tag not found")
+			return
+		}
+		respondServerError(c, queryErr)
+		return
+	}
+
+	entities := []*TagEntity{}
+	if selectErr := dbSelect(c.Request.Context(), &entities, query, args...); selectErr != nil {
+		respondServerError(c, selectErr)
+		return
+	}
+
+	// 没有更多数据时 next_before_created_at 为空字符串，next_before_link_id 为 0
+	nextBeforeCreatedAt, nextBeforeLinkID := "", 0
+	if len(entities) == limit {
+		last := entities[len(entities)-1]
+		nextBeforeCreatedAt, nextBeforeLinkID = last.CreatedAt.UTC().Format(time.RFC3339), last.LinkID
+	}
+
+	c.Header("Cache-Control", recentTagEntitiesCacheControl)
+	c.Writer.Header().Add("Vary", "X-Tenant-Id")
+	respondOK(c, gin.H{
+		"entities":               entities,
+		"next_before_created_at": nextBeforeCreatedAt,
+		"next_before_link_id":    nextBeforeLinkID,
+	})
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// linkPurgeBatchSize 清理取消的关联时每条 delete 最多删除的行数，避免一次删除过多的行长时间持有锁
+const linkPurgeBatchSize = 1000
+
+// entityTagPlacement 需要写入的关联以及它的 position
+type entityTagPlacement struct {
+	EntityID int
+	TagID    int
+	Position int
+}
+
+// linkPairsCondition 返回按 (entity_id, tag_id) 匹配 refs 中关联的 where 条件和参数
+func linkPairsCondition(refs []entityTagRef) (string, []interface{}) {
+	placeholders := make([]string, 0, len(refs))
+	args := make([]interface{}, 0, len(refs)*2)
+	for _, ref := range refs {
+		placeholders = append(placeholders, "(?, ?)")
+		args = append(args, ref.EntityID, ref.TagID)
+	}
+	return "(entity_id, tag_id) in (" + strings.Join(placeholders, ", ") + ")", args
+}
+
+// softDeleteEntityLinksTx 在事务中取消 refs 中未取消的关联，只设置 deleted_at，在 LINK_RESTORE_WINDOW 内可以恢复，
+// 返回被取消关联的 ID，按 ID 顺序排列。实体与标签没有关联的 ref 会被忽略
+func softDeleteEntityLinksTx(ctx context.Context, tx *sqlx.Tx, entityType string, refs []entityTagRef) ([]int, error) {
+	linkIDs := []int{}
+	if len(refs) == 0 {
+		return linkIDs, nil
+	}
+
+	cond, condArgs := linkPairsCondition(refs)
+	args := append([]interface{}{TenantIDFromContext(ctx), entityType}, condArgs...)
+	selectErr := txSelect(
+		ctx, tx, &linkIDs,
+		"select id from entity_tag_tbl where tenant_id = ? and entity_type = ? and "+cond+" and deleted_at is null order by id for update",
+		args...,
+	)
+	if selectErr != nil || len(linkIDs) == 0 {
+		return linkIDs, selectErr
+	}
+
+	query, args, err := sqlx.In("update entity_tag_tbl set deleted_at = now() where id in (?)", linkIDs)
+	if err != nil {
+		return nil, err
+	}
+	if _, execErr := txExec(ctx, tx, query, args...); execErr != nil {
+		return nil, execErr
+	}
+	return linkIDs, nil
+}
+
+// restoreDeletedLinksTx 在事务中恢复 placements 中已经取消的关联，并把 position 设置为 placement 中的值，
+// 关联保留原来的 ID、created_at、附加信息、来源和权重，返回恢复的数量。恢复后的关联已经存在，
+// 随后写入同一批关联的 insert ignore 会跳过它们。调用前需要锁住这些实体
+func restoreDeletedLinksTx(ctx context.Context, tx *sqlx.Tx, entityType string, placements []entityTagPlacement) (int, error) {
+	if len(placements) == 0 {
+		return 0, nil
+	}
+
+	cases := make([]string, 0, len(placements))
+	refs := make([]entityTagRef, 0, len(placements))
+	args := make([]interface{}, 0, len(placements)*5+2)
+	for _, placement := range placements {
+		cases = append(cases, "when entity_id = ? and tag_id = ? then ?")
+		refs = append(refs, entityTagRef{EntityID: placement.EntityID, TagID: placement.TagID})
+		args = append(args, placement.EntityID, placement.TagID, placement.Position)
+	}
+	cond, condArgs := linkPairsCondition(refs)
+	args = append(args, TenantIDFromContext(ctx), entityType)
+	args = append(args, condArgs...)
+
+	execResult, execErr := txExec(
+		ctx, tx,
+		"update entity_tag_tbl set position = case "+strings.Join(cases, " ")+" else position end, deleted_at = null"+
+			" where tenant_id = ? and entity_type = ? and "+cond+" and deleted_at is not null",
+		args...,
+	)
+	if execErr != nil {
+		return 0, execErr
+	}
+	restored, err := execResult.RowsAffected()
+	return int(restored), err
+}
+
+// RestoreEntityLink 恢复在 LINK_RESTORE_WINDOW 内取消的关联，关联保留原来的 position、created_at 和附加信息。
+// 关联不存在或已经被清理时返回 404，没有取消时返回 409，超过可以恢复的时间返回 410，
+// 恢复后实体会超过 MAX_TAGS_PER_ENTITY 时返回 422
+func RestoreEntityLink(ctx context.Context, linkID int) (*EntityTag, error) {
+	tenantID := TenantIDFromContext(ctx)
+	var link EntityTag
+	queryErr := dbGet(ctx, &link, "select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and id = ?", tenantID, linkID)
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			return nil, newAPIError(http.StatusNotFound, "link not found")
+		}
+		return nil, queryErr
+	}
+
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := lockEntityTx(ctx, tx, link.EntityType, link.EntityID); err != nil {
+			return err
+		}
+
+		// 锁住实体后重新读取，查询之后关联可能被并发恢复或清理
+		queryErr := txGet(ctx, tx, &link, "select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and id = ? for update", tenantID, linkID)
+		if queryErr != nil {
+			if queryErr == sql.ErrNoRows {
+				return newAPIError(http.StatusNotFound, "link not found")
+			}
+			return queryErr
+		}
+		if link.DeletedAt == nil {
+			return newAPIError(http.StatusConflict, "link is not deleted")
+		}
+
+		if err := checkEntityTagLimitTx(ctx, tx, link.EntityType, link.EntityID, 1); err != nil {
+			return err
+		}
+
+		// 使用数据库的时间判断是否超过可以恢复的时间，与写入 deleted_at 的 now() 一致
+		execResult, execErr := txExec(
+			ctx, tx,
+			"update entity_tag_tbl set deleted_at = null where id = ? and deleted_at > date_sub(now(), interval ? second)",
+			linkID, int64(config.LinkRestoreWindow/time.Second),
+		)
+		if execErr != nil {
+			return execErr
+		}
+		affected, err := execResult.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return newAPIError(http.StatusGone, "restore window expired")
+		}
+		link.DeletedAt = nil
+
+		if err := recordEntityTagChangesTx(ctx, tx, EntityTagChangeLink, link.EntityType, entityTagRefs(link.EntityID, []int{link.TagID})); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionEntityRestoreLink,
+			EntityType: AuditEntityTypeEntity,
+			EntityID:   link.EntityID,
+			Metadata:   gin.H{"entity_type": link.EntityType, "tag_id": link.TagID, "link_id": linkID},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+	return &link, nil
+}
+
+// OnRestoreEntityLink 恢复取消的关联，用于撤销误操作的取消关联，link_id 由取消关联的接口返回
+// @Summary 恢复取消的关联
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param link_id path int true "关联 ID"
+// @Success 200 {object} APIResponse{data=object{link_id=int,entity_type=string,entity_id=int,tag_id=int,metadata=object,source=string,added_by=string,weight=number,created_at=string,updated_at=string}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 409 {object} APIResponse
+// @Failure 410 {object} APIResponse
+// @Failure 422 {object} APIResponse{error=APIError{detail=object{count=int,limit=int}}}
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/entity_tag/{link_id}/restore [post]
+func OnRestoreEntityLink(c *gin.Context) {
+	linkID, ok := parseIDParam(c, "link_id")
+	if !ok {
+		return
+	}
+
+	link, err := RestoreEntityLink(c.Request.Context(), linkID)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"link_id":     link.LinkID,
+		"entity_type": link.EntityType,
+		"entity_id":   link.EntityID,
+		"tag_id":      link.TagID,
+		"metadata":    link.Metadata,
+		"source":      link.Source,
+		"added_by":    link.AddedBy,
+		"weight":      link.Weight,
+		"created_at":  link.CreatedAt,
+		"updated_at":  link.UpdatedAt,
+	})
+}
+
+// PurgeDeletedLinks 彻底删除取消超过 LINK_RESTORE_WINDOW 的关联，tenantID 为空时清理所有租户，返回删除的数量。
+// 每次最多删除 linkPurgeBatchSize 行，直到没有需要删除的关联
+func PurgeDeletedLinks(ctx context.Context, tenantID string) (int, error) {
+	query := "delete from entity_tag_tbl where deleted_at < date_sub(now(), interval ? second)"
+	args := []interface{}{int64(config.LinkRestoreWindow / time.Second)}
+	if tenantID != "" {
+		query += " and tenant_id = ?"
+		args = append(args, tenantID)
+	}
+	args = append(args, linkPurgeBatchSize)
+
+	purged := 0
+	for {
+		execResult, execErr := dbExec(ctx, query+" limit ?", args...)
+		if execErr != nil {
+			return purged, execErr
+		}
+		affected, err := execResult.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(affected)
+
+		if affected < linkPurgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// StartLinkPurgeWorker 每隔 interval 清理一次所有租户中超过可以恢复的时间的关联
+func StartLinkPurgeWorker(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purged, err := PurgeDeletedLinks(context.Background(), "")
+			if err != nil {
+				log.Printf("PurgeDeletedLinksErr: %s", err)
+			}
+			if purged > 0 {
+				log.Printf("PurgeDeletedLinksOk: %d", purged)
+			}
+		}
+	}()
+}
+
+// OnPurgeDeletedLinks 立即彻底删除当前租户中取消超过 LINK_RESTORE_WINDOW 的关联，与后台清理任务的逻辑相同
+// @Summary 清理取消的关联
+// @Tags admin
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Success 200 {object} APIResponse{data=object{purged=int}}
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/admin/entity_tags/purge [post]
+func OnPurgeDeletedLinks(c *gin.Context) {
+	purged, err := PurgeDeletedLinks(c.Request.Context(), TenantIDFromContext(c.Request.Context()))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{"purged": purged})
+}
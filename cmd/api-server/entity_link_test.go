@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidateEntityType(t *testing.T) {
+	prevConfig := config
+	t.Cleanup(func() { config = prevConfig })
+
+	cases := []struct {
+		name              string
+		defaultEntityType string
+		entityType        string
+		want              string
+		wantCode          int
+	}{
+		{"explicit type", "", "article", "article", 0},
+		{"explicit type overrides default", "default", "video", "video", 0},
+		{"empty uses configured default", "default", "", "default", 0},
+		{"empty without default", "", "", "", http.StatusBadRequest},
+		{"invalid type", "default", "a b", "", http.StatusBadRequest},
+		{"too long", "default", strings.Repeat("a", 33), "", http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config = &Config{DefaultEntityType: tc.defaultEntityType}
+
+			got, err := validateEntityType(tc.entityType)
+			if tc.wantCode != 0 {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.Code != tc.wantCode {
+					t.Fatalf("err = %v, want APIError with code %d", err, tc.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateEntityType: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("entity type = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckEntityTagLimitIgnoresDeletedTags(t *testing.T) {
+	cases := []struct {
+		name string
+		// count 不包括关联到已删除标签的关联
+		count    int
+		wantCode int
+	}{
+		{"deleted tag frees a slot", 1, 0},
+		{"at limit", 2, http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := setupMockDB(t)
+			config.MaxTagsPerEntity = 2
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta("select count(*) from entity_tag_tbl et join tag_tbl t on t.id = et.tag_id and t.deleted_at is null where")).
+				WithArgs("t1", "article", 100).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tc.count))
+			mock.ExpectRollback()
+
+			ctx := WithTenantID(context.Background(), "t1")
+			tx, err := mysqlDB.BeginTxx(ctx, nil)
+			if err != nil {
+				t.Fatalf("begin: %s", err)
+			}
+
+			err = checkEntityTagLimitTx(ctx, tx, "article", 100, 1)
+			if tc.wantCode == 0 && err != nil {
+				t.Errorf("checkEntityTagLimitTx: %s", err)
+			}
+			if tc.wantCode != 0 {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.Code != tc.wantCode {
+					t.Errorf("err = %v, want APIError with code %d", err, tc.wantCode)
+				}
+			}
+
+			if err := tx.Rollback(); err != nil {
+				t.Errorf("rollback: %s", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %s", err)
+			}
+		})
+	}
+}
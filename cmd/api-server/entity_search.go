@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxSearchByTags 按标签查找实体时最多可以传入的标签数量
+const maxSearchByTags = 20
+
+// 按标签查找实体的匹配方式
+const (
+	// SearchByTagsModeAll 实体需要关联所有标签
+	SearchByTagsModeAll = "all"
+	// SearchByTagsModeAny 实体关联任意一个标签即可，按匹配的标签数量排序
+	SearchByTagsModeAny = "any"
+)
+
+// all 模式下的排序方式
+const (
+	// SearchByTagsOrderEntityID 按 entity_id 从小到大排列，默认值
+	SearchByTagsOrderEntityID = "entity_id"
+	// SearchByTagsOrderWeight 按匹配关联的权重之和从高到低排列
+	SearchByTagsOrderWeight = "weight"
+)
+
+// entityMatchScoreExpr 实体的得分，即匹配的关联的权重之和。保留 6 位小数，
+// 使作为游标传回的得分与重新计算的结果可以精确比较
+const entityMatchScoreExpr = "round(sum(weight), 6)"
+
+// SearchEntitiesByTagsReqBody 按标签查找实体的请求体
+type SearchEntitiesByTagsReqBody struct {
+	TagIDs []int `json:"tag_ids"`
+	// ExcludeTagIDs 排除关联了其中任意一个标签的实体，可以与 all、any 模式组合，不能与 tag_ids 重叠
+	ExcludeTagIDs []int `json:"exclude_tag_ids"`
+	// Mode 匹配方式，all 或 any，不传时为 all
+	Mode string `json:"mode"`
+	// MinMatches any 模式下实体至少需要关联的标签数量，默认 1
+	MinMatches int `json:"min_matches"`
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType    string `json:"entity_type"`
+	AfterEntityID int    `json:"after_entity_id"`
+	// AfterMatchCount any 模式下翻页时传入上一页返回的 next_after_match_count，和 after_score、after_entity_id 一起组成游标
+	AfterMatchCount int `json:"after_match_count"`
+	// AfterScore any 模式和 all 模式按 weight 排序时，翻页时传入上一页返回的 next_after_score
+	AfterScore *float64 `json:"after_score"`
+	// Order all 模式下的排序方式，entity_id 或 weight，不传时为 entity_id
+	Order string `json:"order"`
+	// Limit 每页数量，默认 20，最大 100
+	Limit int `json:"limit"`
+}
+
+// excludeTagsCondition 返回排除关联了 excludeTagIDs 中任意标签的实体的 where 条件，excludeTagIDs 为空时返回空字符串。
+// 使用关联子查询 not exists，对扫描到的每一行按唯一键 (tenant_id, entity_type, entity_id, tag_id) 查找，
+// 开销只与候选的行数和排除的标签数量有关。不使用 entity_id not in (select ...)，
+// 后者需要物化被排除标签的所有关联，标签有上百万个关联时无法接受
+func excludeTagsCondition(excludeTagIDs []int) (string, []interface{}) {
+	if len(excludeTagIDs) == 0 {
+		return "", nil
+	}
+	return " and not exists (select 1 from entity_tag_tbl excluded" +
+		" where excluded.tenant_id = entity_tag_tbl.tenant_id and excluded.entity_type = entity_tag_tbl.entity_type" +
+		" and excluded.entity_id = entity_tag_tbl.entity_id and excluded.tag_id in (?) and excluded.deleted_at is null)", []interface{}{excludeTagIDs}
+}
+
+// SearchEntitiesByAllTags 查找同时关联了 tagIDs 中所有标签、且没有关联 excludeTagIDs 中任何标签的实体，
+// 按 entity_id 顺序返回 afterEntityID 之后的 limit 个。
+// 条件中 tenant_id、entity_type 为等值，可以按唯一键 (tenant_id, entity_type, entity_id, tag_id) 的顺序扫描，
+// group by 不需要临时表和排序，翻页也不需要 offset
+func SearchEntitiesByAllTags(ctx context.Context, entityType string, tagIDs, excludeTagIDs []int, afterEntityID, limit int) ([]int, error) {
+	excludeCond, excludeArgs := excludeTagsCondition(excludeTagIDs)
+	args := []interface{}{TenantIDFromContext(ctx), entityType, afterEntityID, tagIDs}
+	args = append(args, excludeArgs...)
+	args = append(args, len(tagIDs), limit)
+	query, args, err := sqlx.In(
+		"select entity_id from entity_tag_tbl"+
+			" where tenant_id = ? and entity_type = ? and entity_id > ? and tag_id in (?) and deleted_at is null"+excludeCond+
+			" group by entity_id having count(distinct tag_id) = ?"+
+			" order by entity_id limit ?",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entityIDs := []int{}
+	if err := dbSelect(ctx, &entityIDs, query, args...); err != nil {
+		return nil, err
+	}
+	return entityIDs, nil
+}
+
+// SearchEntitiesByAllTagsWeighted 查找同时关联了 tagIDs 中所有标签、且没有关联 excludeTagIDs 中任何标签的实体，
+// 按得分从高到低、entity_id 从小到大排序。
+// 游标为上一页最后一个实体的 (afterScore, afterEntityID)，afterScore 为 nil 时从第一页开始。
+// 需要先分组计算所有实体的得分再排序，比按 entity_id 排列的 SearchEntitiesByAllTags 开销大
+func SearchEntitiesByAllTagsWeighted(ctx context.Context, entityType string, tagIDs, excludeTagIDs []int, afterScore *float64, afterEntityID, limit int) ([]*EntityMatch, error) {
+	excludeCond, excludeArgs := excludeTagsCondition(excludeTagIDs)
+	query := "select entity_id, count(distinct tag_id) as match_count, " + entityMatchScoreExpr + " as score from entity_tag_tbl" +
+		" where tenant_id = ? and entity_type = ? and tag_id in (?) and deleted_at is null" + excludeCond +
+		" group by entity_id having match_count = ?"
+	args := append([]interface{}{TenantIDFromContext(ctx), entityType, tagIDs}, excludeArgs...)
+	args = append(args, len(tagIDs))
+	if afterScore != nil {
+		query += " and (score < ? or (score = ? and entity_id > ?))"
+		args = append(args, *afterScore, *afterScore, afterEntityID)
+	}
+	query += " order by score desc, entity_id limit ?"
+	args = append(args, limit)
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*EntityMatch{}
+	if err := dbSelect(ctx, &matches, query, args...); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// EntityMatch 匹配的实体、关联的标签数量以及得分，得分为匹配的关联的权重之和
+type EntityMatch struct {
+	EntityID   int     `db:"entity_id" json:"entity_id"`
+	MatchCount int     `db:"match_count" json:"match_count"`
+	Score      float64 `db:"score" json:"score"`
+}
+
+// SearchEntitiesByAnyTags 查找至少关联了 tagIDs 中 minMatches 个标签、且没有关联 excludeTagIDs 中任何标签的实体，按匹配数量从多到少排序，
+// 数量相同时按得分从高到低、entity_id 从小到大排序。游标为上一页最后一个实体的 (afterMatchCount, afterScore, afterEntityID)，
+// afterMatchCount 为 0 时从第一页开始，翻页期间有新的关联时不会因为 offset 偏移而跳过或重复未变化的实体
+func SearchEntitiesByAnyTags(ctx context.Context, entityType string, tagIDs, excludeTagIDs []int, minMatches, afterMatchCount int, afterScore float64, afterEntityID, limit int) ([]*EntityMatch, error) {
+	excludeCond, excludeArgs := excludeTagsCondition(excludeTagIDs)
+	query := "select entity_id, count(distinct tag_id) as match_count, " + entityMatchScoreExpr + " as score from entity_tag_tbl" +
+		" where tenant_id = ? and entity_type = ? and tag_id in (?) and deleted_at is null" + excludeCond +
+		" group by entity_id having match_count >= ?"
+	args := append([]interface{}{TenantIDFromContext(ctx), entityType, tagIDs}, excludeArgs...)
+	args = append(args, minMatches)
+	if afterMatchCount > 0 {
+		query += " and (match_count < ? or (match_count = ? and (score < ? or (score = ? and entity_id > ?))))"
+		args = append(args, afterMatchCount, afterMatchCount, afterScore, afterScore, afterEntityID)
+	}
+	query += " order by match_count desc, score desc, entity_id limit ?"
+	args = append(args, limit)
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*EntityMatch{}
+	if err := dbSelect(ctx, &matches, query, args...); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// OnSearchEntitiesByTags 按标签查找实体，all 模式返回同时关联所有标签的实体，order 为 weight 时按得分排序，
+// any 模式返回关联任意标签的实体以及匹配的数量，数量相同时按得分排序。得分为匹配的关联的权重之和
+// @Summary 按标签查找实体
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body SearchEntitiesByTagsReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,entity_ids=[]int,entities=[]EntityMatch,next_after_match_count=int,next_after_score=number,next_after_entity_id=int}} "all 模式返回 entity_ids，按 weight 排序时同时返回 entities 和 next_after_score；any 模式返回 entities、next_after_match_count 和 next_after_score"
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/entities/search_by_tags [post]
+func OnSearchEntitiesByTags(c *gin.Context) {
+	var reqBody SearchEntitiesByTagsReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	if len(reqBody.TagIDs) == 0 {
+		respondError(c, http.StatusBadRequest, "tag_ids is required")
+		return
+	}
+	if len(reqBody.TagIDs) > maxSearchByTags {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("too many tag_ids, max %d", maxSearchByTags))
+		return
+	}
+	if len(reqBody.ExcludeTagIDs) > maxSearchByTags {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("too many exclude_tag_ids, max %d", maxSearchByTags))
+		return
+	}
+
+	if reqBody.Mode == "" {
+		reqBody.Mode = SearchByTagsModeAll
+	}
+	if reqBody.Mode != SearchByTagsModeAll && reqBody.Mode != SearchByTagsModeAny {
+		respondError(c, http.StatusBadRequest, "invalid mode")
+		return
+	}
+
+	if reqBody.AfterEntityID < 0 {
+		respondError(c, http.StatusBadRequest, "invalid after_entity_id")
+		return
+	}
+	if reqBody.AfterMatchCount < 0 {
+		respondError(c, http.StatusBadRequest, "invalid after_match_count")
+		return
+	}
+	if reqBody.Mode == SearchByTagsModeAny && reqBody.AfterMatchCount > 0 && reqBody.AfterScore == nil {
+		respondError(c, http.StatusBadRequest, "after_score is required with after_match_count")
+		return
+	}
+
+	if reqBody.Order == "" {
+		reqBody.Order = SearchByTagsOrderEntityID
+	}
+	if reqBody.Order != SearchByTagsOrderEntityID && reqBody.Order != SearchByTagsOrderWeight {
+		respondError(c, http.StatusBadRequest, "invalid order")
+		return
+	}
+
+	limit := reqBody.Limit
+	if limit == 0 {
+		limit = 20
+	}
+	if limit < 0 || limit > 100 {
+		respondError(c, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tagIDs, err := normalizeTagIDs(reqBody.TagIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	excludeTagIDs := []int{}
+	if len(reqBody.ExcludeTagIDs) > 0 {
+		if excludeTagIDs, err = normalizeTagIDs(reqBody.ExcludeTagIDs); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid exclude_tag_ids")
+			return
+		}
+	}
+
+	// 同时包含和排除同一个标签时结果一定为空，视为请求错误
+	included := make(map[int]bool, len(tagIDs))
+	for _, tagID := range tagIDs {
+		included[tagID] = true
+	}
+	overlapTagIDs := []int{}
+	for _, tagID := range excludeTagIDs {
+		if included[tagID] {
+			overlapTagIDs = append(overlapTagIDs, tagID)
+		}
+	}
+	if len(overlapTagIDs) > 0 {
+		apiErr := newAPIError(http.StatusBadRequest, "tag_ids and exclude_tag_ids overlap")
+		apiErr.Detail = gin.H{"tag_ids": overlapTagIDs}
+		respondServerError(c, apiErr)
+		return
+	}
+
+	// 标签都需要存在，否则结果一定为空，直接返回 404 更明确。
+	// 排除的标签同样需要存在，避免传错 ID 时没有排除任何实体却看起来成功
+	allTagIDs := append(append([]int{}, tagIDs...), excludeTagIDs...)
+	exists, err := selectExistTagIDs(c.Request.Context(), nil, allTagIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+	if len(exists) < len(allTagIDs) {
+		respondServerError(c, newTagNotFoundError(allTagIDs, exists))
+		return
+	}
+
+	if reqBody.Mode == SearchByTagsModeAny {
+		minMatches := reqBody.MinMatches
+		if minMatches == 0 {
+			minMatches = 1
+		}
+		if minMatches < 0 || minMatches > len(tagIDs) {
+			respondError(c, http.StatusBadRequest, "invalid min_matches")
+			return
+		}
+
+		afterScore := 0.0
+		if reqBody.AfterScore != nil {
+			afterScore = *reqBody.AfterScore
+		}
+		matches, err := SearchEntitiesByAnyTags(c.Request.Context(), entityType, tagIDs, excludeTagIDs, minMatches, reqBody.AfterMatchCount, afterScore, reqBody.AfterEntityID, limit)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+
+		// 没有更多数据时游标都为 0
+		nextAfterMatchCount, nextAfterScore, nextAfterEntityID := 0, 0.0, 0
+		if len(matches) == limit {
+			nextAfterMatchCount = matches[len(matches)-1].MatchCount
+			nextAfterScore = matches[len(matches)-1].Score
+			nextAfterEntityID = matches[len(matches)-1].EntityID
+		}
+
+		respondOK(c, gin.H{
+			"entity_type":            entityType,
+			"entities":               matches,
+			"next_after_match_count": nextAfterMatchCount,
+			"next_after_score":       nextAfterScore,
+			"next_after_entity_id":   nextAfterEntityID,
+		})
+		return
+	}
+
+	if reqBody.Order == SearchByTagsOrderWeight {
+		matches, err := SearchEntitiesByAllTagsWeighted(c.Request.Context(), entityType, tagIDs, excludeTagIDs, reqBody.AfterScore, reqBody.AfterEntityID, limit)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+
+		entityIDs := make([]int, 0, len(matches))
+		for _, match := range matches {
+			entityIDs = append(entityIDs, match.EntityID)
+		}
+
+		// 没有更多数据时 next_after_score 为 null，next_after_entity_id 为 0
+		var nextAfterScore *float64
+		nextAfterEntityID := 0
+		if len(matches) == limit {
+			nextAfterScore = &matches[len(matches)-1].Score
+			nextAfterEntityID = matches[len(matches)-1].EntityID
+		}
+
+		respondOK(c, gin.H{
+			"entity_type":          entityType,
+			"entity_ids":           entityIDs,
+			"entities":             matches,
+			"next_after_score":     nextAfterScore,
+			"next_after_entity_id": nextAfterEntityID,
+		})
+		return
+	}
+
+	entityIDs, err := SearchEntitiesByAllTags(c.Request.Context(), entityType, tagIDs, excludeTagIDs, reqBody.AfterEntityID, limit)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	// 没有更多数据时 next_after_entity_id 为 0
+	nextAfterEntityID := 0
+	if len(entityIDs) == limit {
+		nextAfterEntityID = entityIDs[len(entityIDs)-1]
+	}
+
+	respondOK(c, gin.H{
+		"entity_type":          entityType,
+		"entity_ids":           entityIDs,
+		"next_after_entity_id": nextAfterEntityID,
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// EntityTagAudit 实体关联的一条审计记录，每次新建或取消关联时为每一对实体和标签各写入一条
+type EntityTagAudit struct {
+	AuditID    int64     `db:"id" json:"audit_id"`
+	EntityType string    `db:"entity_type" json:"entity_type"`
+	EntityID   int       `db:"entity_id" json:"entity_id"`
+	TagID      int       `db:"tag_id" json:"tag_id"`
+	Action     string    `db:"action" json:"action"`
+	ActorID    string    `db:"actor_id" json:"actor_id"`
+	Source     string    `db:"source" json:"source"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// recordEntityTagAuditTx 在事务中为 refs 中的每个关联写入一条审计记录，操作人为 JWT 中的操作人，
+// 来源从关联记录中读取。取消的关联只设置了 deleted_at，仍然可以读到来源
+func recordEntityTagAuditTx(ctx context.Context, tx *sqlx.Tx, action, entityType string, refs []entityTagRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	cond, condArgs := linkPairsCondition(refs)
+	args := append([]interface{}{action, ActorIDFromContext(ctx), TenantIDFromContext(ctx), entityType}, condArgs...)
+	_, execErr := txExec(
+		ctx, tx,
+		"insert into entity_tag_audit_tbl (tenant_id, entity_type, entity_id, tag_id, action, actor_id, source) "+
+			"select tenant_id, entity_type, entity_id, tag_id, ?, ?, source from entity_tag_tbl where tenant_id = ? and entity_type = ? and "+cond+" order by id",
+		args...,
+	)
+	return execErr
+}
+
+// respondEntityTagAudit 按 ID 从新到旧分页返回满足 cond 的审计记录，支持 before_id、since、until 和 limit 查询参数
+func respondEntityTagAudit(c *gin.Context, cond string, condArgs ...interface{}) {
+	beforeID, ok := parseIntQuery(c, "before_id", 0)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(c, 100, 1000)
+	if !ok {
+		return
+	}
+
+	query := "select id, entity_type, entity_id, tag_id, action, actor_id, source, created_at from entity_tag_audit_tbl where tenant_id = ? and " + cond
+	args := append([]interface{}{TenantIDFromContext(c.Request.Context())}, condArgs...)
+	if beforeID > 0 {
+		query += " and id < ?"
+		args = append(args, beforeID)
+	}
+	for _, param := range []struct{ name, cond string }{{"since", " and created_at >= ?"}, {"until", " and created_at < ?"}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid "+param.name)
+			return
+		}
+		query += param.cond
+		args = append(args, t.UTC())
+	}
+	query += " order by id desc limit ?"
+	args = append(args, limit)
+
+	audit := []*EntityTagAudit{}
+	if selectErr := dbSelect(c.Request.Context(), &audit, query, args...); selectErr != nil {
+		respondServerError(c, selectErr)
+		return
+	}
+
+	// 没有更多记录时 next_before_id 为 0
+	var nextBeforeID int64
+	if len(audit) == limit {
+		nextBeforeID = audit[len(audit)-1].AuditID
+	}
+
+	respondOK(c, gin.H{
+		"audit":          audit,
+		"next_before_id": nextBeforeID,
+		"has_more":       len(audit) == limit,
+	})
+}
+
+// OnEntityTagAudit 按时间从新到旧查询实体关联和取消关联标签的审计记录，用于追查谁在什么时候修改了实体的标签
+// @Summary 实体关联的审计记录
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "实体 ID"
+// @Param entity_type query string false "实体类型，不传时使用 DEFAULT_ENTITY_TYPE"
+// @Param since query string false "只返回该时间之后的记录，RFC3339 格式，例如 2021-01-02T15:04:05Z"
+// @Param until query string false "只返回该时间之前的记录，RFC3339 格式"
+// @Param before_id query int false "上一页返回的 next_before_id"
+// @Param limit query int false "每页数量，默认 100，最大 1000"
+// @Success 200 {object} APIResponse{data=object{audit=[]EntityTagAudit,next_before_id=int,has_more=bool}}
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/entity/{id}/tag_audit [get]
+func OnEntityTagAudit(c *gin.Context) {
+	entityID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	entityType, err := validateEntityType(c.Query("entity_type"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondEntityTagAudit(c, "entity_type = ? and entity_id = ?", entityType, entityID)
+}
+
+// OnTagLinkAudit 按时间从新到旧查询标签被关联和取消关联的审计记录，包括标签已经删除之前的记录
+// @Summary 标签关联的审计记录
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param since query string false "只返回该时间之后的记录，RFC3339 格式，例如 2021-01-02T15:04:05Z"
+// @Param until query string false "只返回该时间之前的记录，RFC3339 格式"
+// @Param before_id query int false "上一页返回的 next_before_id"
+// @Param limit query int false "每页数量，默认 100，最大 1000"
+// @Success 200 {object} APIResponse{data=object{audit=[]EntityTagAudit,next_before_id=int,has_more=bool}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/{id}/link_audit [get]
+func OnTagLinkAudit(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var exists int
+	queryErr := dbGet(c.Request.Context(), &exists, "select 1 from tag_tbl where tenant_id = ? and id = ?", TenantIDFromContext(c.Request.Context()), tagID)
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			respondError(c, http.StatusNotFound, "tag not found")
+			return
+		}
+		respondServerError(c, queryErr)
+		return
+	}
+
+	respondEntityTagAudit(c, "tag_id = ?", tagID)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// 实体关联变更的类型
+const (
+	// EntityTagChangeLink 新建了实体与标签的关联
+	EntityTagChangeLink = "link"
+	// EntityTagChangeUnlink 删除了实体与标签的关联
+	EntityTagChangeUnlink = "unlink"
+)
+
+// entityTagChangesSettleSeconds 变更写入后经过多少秒才会返回。自增 ID 在写入时分配，事务提交的顺序可能与 ID 不同，
+// 等待并发的事务提交后再返回，避免调用方按 next_after_id 继续读取时跳过较晚提交的较小 ID
+const entityTagChangesSettleSeconds = 5
+
+// EntityTagChange 实体关联的一次变更
+type EntityTagChange struct {
+	ChangeID   int64     `db:"id" json:"change_id"`
+	EntityType string    `db:"entity_type" json:"entity_type"`
+	EntityID   int       `db:"entity_id" json:"entity_id"`
+	TagID      int       `db:"tag_id" json:"tag_id"`
+	ChangeType string    `db:"change_type" json:"change_type"`
+	ChangedAt  time.Time `db:"created_at" json:"changed_at"`
+}
+
+// entityTagRef 一条关联的实体和标签
+type entityTagRef struct {
+	EntityID int
+	TagID    int
+}
+
+// entityTagRefs 返回实体与 tagIDs 中每个标签的关联
+func entityTagRefs(entityID int, tagIDs []int) []entityTagRef {
+	refs := make([]entityTagRef, 0, len(tagIDs))
+	for _, tagID := range tagIDs {
+		refs = append(refs, entityTagRef{EntityID: entityID, TagID: tagID})
+	}
+	return refs
+}
+
+// tagEntityRefs 返回标签与 entityIDs 中每个实体的关联
+func tagEntityRefs(tagID int, entityIDs []int) []entityTagRef {
+	refs := make([]entityTagRef, 0, len(entityIDs))
+	for _, entityID := range entityIDs {
+		refs = append(refs, entityTagRef{EntityID: entityID, TagID: tagID})
+	}
+	return refs
+}
+
+// recordEntityTagChangesTx 在事务中使用一条多行 insert 记录关联的变更，并为每个关联写入审计记录，与关联的修改一起提交或回滚。
+// 需要在关联写入或取消之后调用，审计记录的来源从关联记录中读取
+func recordEntityTagChangesTx(ctx context.Context, tx *sqlx.Tx, changeType, entityType string, refs []entityTagRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, 0, len(refs))
+	args := make([]interface{}, 0, len(refs)*5)
+	for _, ref := range refs {
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?)")
+		args = append(args, TenantIDFromContext(ctx), entityType, ref.EntityID, ref.TagID, changeType)
+	}
+
+	_, execErr := txExec(
+		ctx, tx,
+		"insert into entity_tag_change_tbl (tenant_id, entity_type, entity_id, tag_id, change_type) values "+strings.Join(placeholders, ", "),
+		args...,
+	)
+	if execErr != nil {
+		return execErr
+	}
+
+	return recordEntityTagAuditTx(ctx, tx, changeType, entityType, refs)
+}
+
+// OnEntityTagChanges 按变更顺序列出实体关联的新建和删除，用于增量同步实体的索引。
+// 第一次请求通过 since 指定开始时间，之后把 next_after_id 作为 after_id 传回继续读取，
+// 没有新的变更时 next_after_id 不变，可以用于轮询
+// @Summary 实体关联的变更
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param since query string false "只返回该时间之后的变更，RFC3339 格式，例如 2021-01-02T15:04:05Z"
+// @Param after_id query int false "上一页返回的 next_after_id"
+// @Param entity_type query string false "只返回该实体类型的变更"
+// @Param limit query int false "每页数量，默认 100，最大 1000"
+// @Success 200 {object} APIResponse{data=object{changes=[]EntityTagChange,next_after_id=int,has_more=bool}}
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/entity_tags/changes [get]
+func OnEntityTagChanges(c *gin.Context) {
+	afterID, ok := parseIntQuery(c, "after_id", 0)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(c, 100, 1000)
+	if !ok {
+		return
+	}
+
+	query := "select id, entity_type, entity_id, tag_id, change_type, created_at from entity_tag_change_tbl where tenant_id = ? and id > ?"
+	args := []interface{}{TenantIDFromContext(c.Request.Context()), afterID}
+	if since := c.Query("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid since")
+			return
+		}
+		// 包含 since 当时的变更，调用方可能收到重复的变更，但不会遗漏
+		query += " and created_at >= ?"
+		args = append(args, sinceTime.UTC())
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		if !entityTypePattern.MatchString(entityType) {
+			respondError(c, http.StatusBadRequest, "invalid entity_type")
+			return
+		}
+		query += " and entity_type = ?"
+		args = append(args, entityType)
+	}
+	query += " and created_at < now(3) - interval ? second order by id limit ?"
+	args = append(args, entityTagChangesSettleSeconds, limit)
+
+	changes := []*EntityTagChange{}
+	if selectErr := dbSelect(c.Request.Context(), &changes, query, args...); selectErr != nil {
+		respondServerError(c, selectErr)
+		return
+	}
+
+	// 没有新的变更时返回传入的 after_id，调用方下次继续从这里读取
+	nextAfterID := int64(afterID)
+	if len(changes) > 0 {
+		nextAfterID = changes[len(changes)-1].ChangeID
+	}
+
+	respondOK(c, gin.H{
+		"changes":       changes,
+		"next_after_id": nextAfterID,
+		"has_more":      len(changes) == limit,
+	})
+}
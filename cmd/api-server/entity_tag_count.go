@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxEntityTagCountBatch 批量查询实体标签数量时每次请求最多可以传入的实体数量
+const maxEntityTagCountBatch = 500
+
+// entityTagCountCacheControl 实体标签数量的 Cache-Control，数量只用于展示角标，允许浏览器缓存 30 秒。
+// 结果与租户有关，只允许浏览器缓存，并通过 Vary 区分不同租户的请求
+const entityTagCountCacheControl = "private, max-age=30"
+
+// setEntityTagCountCacheHeaders 设置实体标签数量接口的缓存响应头
+func setEntityTagCountCacheHeaders(c *gin.Context) {
+	c.Header("Cache-Control", entityTagCountCacheControl)
+	// 使用 Add 保留 CORSMiddleware 设置的 Vary: Origin
+	c.Writer.Header().Add("Vary", "X-Tenant-Id")
+}
+
+// CountEntityTags 使用一条 group by 查询统计 entityIDs 中每个实体关联的未删除标签数量，
+// 与查询实体关联的标签列表返回的数量一致，没有关联任何标签的实体为 0
+func CountEntityTags(ctx context.Context, entityType string, entityIDs []int) (map[int]int, error) {
+	counts := make(map[int]int, len(entityIDs))
+	if len(entityIDs) == 0 {
+		return counts, nil
+	}
+
+	query, args, err := sqlx.In(
+		`select et.entity_id, count(*) as count from entity_tag_tbl et
+		join tag_tbl t on t.id = et.tag_id and t.deleted_at is null
+		where et.tenant_id = ? and et.entity_type = ? and et.entity_id in (?) and et.deleted_at is null
+		group by et.entity_id`,
+		TenantIDFromContext(ctx), entityType, entityIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*entityTagCount{}
+	if err := dbSelect(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	for _, entityID := range entityIDs {
+		counts[entityID] = 0
+	}
+	for _, row := range rows {
+		counts[row.EntityID] = row.Count
+	}
+	return counts, nil
+}
+
+// OnEntityTagCount 查询实体关联的标签数量，用于展示角标，不需要查询标签详情
+// @Summary 查询实体关联的标签数量
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param entity_id query int true "实体 ID"
+// @Param entity_type query string false "实体类型，不传时使用 DEFAULT_ENTITY_TYPE"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,entity_id=int,count=int}}
+// @Header 200 {string} Cache-Control "private, max-age=30"
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/entity_count [get]
+func OnEntityTagCount(c *gin.Context) {
+	entityID, ok := parseIntQuery(c, "entity_id", 0)
+	if !ok {
+		return
+	}
+	if entityID == 0 {
+		respondError(c, http.StatusBadRequest, "invalid entity_id")
+		return
+	}
+
+	entityType, err := validateEntityType(c.Query("entity_type"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	counts, err := CountEntityTags(c.Request.Context(), entityType, []int{entityID})
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	setEntityTagCountCacheHeaders(c)
+	respondOK(c, gin.H{
+		"entity_type": entityType,
+		"entity_id":   entityID,
+		"count":       counts[entityID],
+	})
+}
+
+// EntityTagCountBatchReqBody 批量查询实体标签数量的请求体
+type EntityTagCountBatchReqBody struct {
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	EntityIDs  []int  `json:"entity_ids"`
+}
+
+// OnEntityTagCountBatch 批量查询实体关联的标签数量，返回实体 ID 到数量的映射
+// @Summary 批量查询实体关联的标签数量
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body EntityTagCountBatchReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=map[string]int}
+// @Header 200 {string} Cache-Control "private, max-age=30"
+// @Failure 400 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/entity_count_batch [post]
+func OnEntityTagCountBatch(c *gin.Context) {
+	var reqBody EntityTagCountBatchReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	if len(reqBody.EntityIDs) > maxEntityTagCountBatch {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("too many entity_ids, max %d", maxEntityTagCountBatch))
+		return
+	}
+	for _, entityID := range reqBody.EntityIDs {
+		if entityID <= 0 {
+			respondError(c, http.StatusBadRequest, "invalid entity_ids")
+			return
+		}
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	counts, err := CountEntityTags(c.Request.Context(), entityType, reqBody.EntityIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	setEntityTagCountCacheHeaders(c)
+	respondOK(c, counts)
+}
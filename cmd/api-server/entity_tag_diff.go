@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EntityTagsDiff 两个实体关联的标签的差异
+type EntityTagsDiff struct {
+	// OnlyInA 只有实体 a 关联的标签，按 a 的展示顺序
+	OnlyInA []*Tag `json:"only_in_a"`
+	// OnlyInB 只有实体 b 关联的标签，按 b 的展示顺序
+	OnlyInB []*Tag `json:"only_in_b"`
+	// Common 两个实体都关联的标签，按 a 的展示顺序
+	Common []*Tag `json:"common"`
+}
+
+// DiffEntityTags 比较同一类型的两个实体关联的未删除标签，通过 GetEntitiesTags 一次查询两个实体的关联，
+// 再一次查询标签，a 和 b 相同时所有标签都在 Common 中
+func DiffEntityTags(ctx context.Context, entityType string, entityA, entityB int) (*EntityTagsDiff, error) {
+	entitiesTags, err := GetEntitiesTags(ctx, entityType, []int{entityA, entityB}, EntityTagsOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	inB := make(map[int]bool, len(entitiesTags[entityB]))
+	for _, tag := range entitiesTags[entityB] {
+		inB[tag.TagID] = true
+	}
+
+	diff := &EntityTagsDiff{OnlyInA: []*Tag{}, OnlyInB: []*Tag{}, Common: []*Tag{}}
+	inA := make(map[int]bool, len(entitiesTags[entityA]))
+	for _, tag := range entitiesTags[entityA] {
+		inA[tag.TagID] = true
+		if inB[tag.TagID] {
+			diff.Common = append(diff.Common, tag.Tag)
+		} else {
+			diff.OnlyInA = append(diff.OnlyInA, tag.Tag)
+		}
+	}
+	for _, tag := range entitiesTags[entityB] {
+		if !inA[tag.TagID] {
+			diff.OnlyInB = append(diff.OnlyInB, tag.Tag)
+		}
+	}
+	return diff, nil
+}
+
+// OnEntityTagsDiff 比较两个实体关联的标签，用于合并重复实体前查看差异，没有关联标签的实体按空集合比较
+// @Summary 比较两个实体关联的标签
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param a query int true "实体 a 的 ID"
+// @Param b query int true "实体 b 的 ID"
+// @Param entity_type query string false "两个实体的类型，不传时使用 DEFAULT_ENTITY_TYPE"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,a=int,b=int,only_in_a=[]Tag,only_in_b=[]Tag,common=[]Tag}}
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/entity_tags/diff [get]
+func OnEntityTagsDiff(c *gin.Context) {
+	entityA, ok := parseIntQuery(c, "a", 0)
+	if !ok {
+		return
+	}
+	entityB, ok := parseIntQuery(c, "b", 0)
+	if !ok {
+		return
+	}
+	if entityA == 0 || entityB == 0 {
+		respondError(c, http.StatusBadRequest, "a and b are required")
+		return
+	}
+
+	entityType, err := validateEntityType(c.Query("entity_type"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	diff, err := DiffEntityTags(c.Request.Context(), entityType, entityA, entityB)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"entity_type": entityType,
+		"a":           entityA,
+		"b":           entityB,
+		"only_in_a":   diff.OnlyInA,
+		"only_in_b":   diff.OnlyInB,
+		"common":      diff.Common,
+	})
+}
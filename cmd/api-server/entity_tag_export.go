@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// ExportedEntityTag 导出的实体关联，每行一个
+type ExportedEntityTag struct {
+	LinkID     int    `db:"id" json:"link_id"`
+	EntityType string `db:"entity_type" json:"entity_type"`
+	EntityID   int    `db:"entity_id" json:"entity_id"`
+	TagID      int    `db:"tag_id" json:"tag_id"`
+	// TagName 标签的名称，标签已软删除时仍然输出名称
+	TagName   string    `db:"-" json:"tag_name"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// getExportTagNames 查询一批关联的标签名称，包括已软删除的标签，导出的数据不依赖标签接口
+func getExportTagNames(ctx context.Context, links []*ExportedEntityTag) (map[int]string, error) {
+	tagIDs := make([]int, 0, len(links))
+	seen := make(map[int]bool, len(links))
+	for _, link := range links {
+		if !seen[link.TagID] {
+			seen[link.TagID] = true
+			tagIDs = append(tagIDs, link.TagID)
+		}
+	}
+
+	query, args, err := sqlx.In("select id, name from tag_tbl where tenant_id = ? and id in (?)", TenantIDFromContext(ctx), tagIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}{}
+	if err := dbSelect(ctx, &tags, query, args...); err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string, len(tags))
+	for _, tag := range tags {
+		names[tag.ID] = tag.Name
+	}
+	return names, nil
+}
+
+// writeExportedEntityTags 补充一批关联的标签名称后逐行写出并刷新响应
+func writeExportedEntityTags(c *gin.Context, encoder *json.Encoder, links []*ExportedEntityTag) error {
+	names, err := getExportTagNames(c.Request.Context(), links)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		link.TagName = names[link.TagID]
+		if err := encoder.Encode(link); err != nil {
+			return err
+		}
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// OnExportEntityTags 以 NDJSON 格式流式导出当前租户的实体关联，用于离线分析。
+// 按关联 ID 顺序逐行读取，每 500 行查询一次标签名称并刷新响应，内存占用与关联数量无关。
+// 客户端断开连接时查询随请求取消，停止导出
+// @Summary 导出实体关联
+// @Tags entity
+// @Produce application/x-ndjson
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param tag_id query int false "只导出该标签的关联"
+// @Param entity_type query string false "只导出该类型实体的关联，不传时导出所有类型"
+// @Param since query string false "只导出在该时间之后建立的关联，RFC3339 格式，例如 2020-06-01T00:00:00Z"
+// @Param until query string false "只导出在该时间之前建立的关联，RFC3339 格式"
+// @Success 200 {object} ExportedEntityTag "每行一个关联"
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Router /api/entity_tags/export [get]
+func OnExportEntityTags(c *gin.Context) {
+	ctx := c.Request.Context()
+	query := "select id, entity_type, entity_id, tag_id, created_at from entity_tag_tbl where tenant_id = ? and deleted_at is null"
+	args := []interface{}{TenantIDFromContext(ctx)}
+
+	tagID, ok := parseIntQuery(c, "tag_id", 0)
+	if !ok {
+		return
+	}
+	if tagID > 0 {
+		query += " and tag_id = ?"
+		args = append(args, tagID)
+	}
+
+	if value := c.Query("entity_type"); value != "" {
+		entityType, err := validateEntityType(value)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+		query += " and entity_type = ?"
+		args = append(args, entityType)
+	}
+
+	for _, param := range []struct{ name, cond string }{{"since", " and created_at > ?"}, {"until", " and created_at < ?"}} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid "+param.name)
+			return
+		}
+		query += param.cond
+		args = append(args, t)
+	}
+
+	// 导出的时间不固定，不使用 config.QueryTimeout，请求取消时查询随之结束
+	rows, err := mysqlDB.QueryxContext(ctx, query+" order by id", args...)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Transfer-Encoding", "chunked")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// 响应已经开始写出，之后的错误只能记录日志并中断输出
+	encoder := json.NewEncoder(c.Writer)
+	batch := make([]*ExportedEntityTag, 0, exportFlushRows)
+	for rows.Next() {
+		link := &ExportedEntityTag{}
+		if err := rows.StructScan(link); err != nil {
+			log.Printf("ExportEntityTagsErr: %s", err)
+			return
+		}
+
+		batch = append(batch, link)
+		if len(batch) < exportFlushRows {
+			continue
+		}
+		if err := writeExportedEntityTags(c, encoder, batch); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("ExportEntityTagsErr: %s", err)
+			}
+			return
+		}
+		batch = batch[:0]
+	}
+
+	// 客户端断开连接时 rows.Err 返回 context canceled，不需要记录
+	if err := rows.Err(); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("ExportEntityTagsErr: %s", err)
+		}
+		return
+	}
+
+	if len(batch) > 0 {
+		if err := writeExportedEntityTags(c, encoder, batch); err != nil && ctx.Err() == nil {
+			log.Printf("ExportEntityTagsErr: %s", err)
+		}
+	}
+}
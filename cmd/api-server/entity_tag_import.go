@@ -0,0 +1,604 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxEntityTagImportErrors 导入实体关联时最多返回的错误行数，超过时只计入 Failed
+const maxEntityTagImportErrors = 1000
+
+// ImportEntityTagLine NDJSON 导入实体关联时每行的内容，CSV 的两列依次为 entity_id 和 tag_name
+type ImportEntityTagLine struct {
+	EntityID int    `json:"entity_id"`
+	TagName  string `json:"tag_name"`
+}
+
+// ImportEntityTagsResult 导入实体关联的结果，dry_run 时为写入后预计的结果
+type ImportEntityTagsResult struct {
+	EntityType string `json:"entity_type"`
+	DryRun     bool   `json:"dry_run"`
+	// CreatedTags 新创建的标签数量
+	CreatedTags int `json:"created_tags"`
+	// CreatedLinks 新写入的关联数量
+	CreatedLinks int `json:"created_links"`
+	// SkippedDuplicates 已经存在或在文件中重复的关联数量
+	SkippedDuplicates int `json:"skipped_duplicates"`
+	// Failed 格式错误、标签已删除或超过 MAX_TAGS_PER_ENTITY 的行数
+	Failed int               `json:"failed"`
+	Errors []*ImportRowError `json:"errors"`
+	// ErrorsTruncated 错误行超过 maxEntityTagImportErrors 个，Errors 中只有前面的部分
+	ErrorsTruncated bool `json:"errors_truncated"`
+}
+
+// addError 记录一行的错误，超过 maxEntityTagImportErrors 个后只计数
+func (r *ImportEntityTagsResult) addError(row int, reason string) {
+	r.Failed++
+	if len(r.Errors) >= maxEntityTagImportErrors {
+		r.ErrorsTruncated = true
+		return
+	}
+	r.Errors = append(r.Errors, &ImportRowError{Row: row, Reason: reason})
+}
+
+// entityTagImportRow 校验通过的一行，Name 为规范化后的标签名称
+type entityTagImportRow struct {
+	Row         int
+	EntityID    int
+	Name        string
+	DisplayName string
+}
+
+// entityTagImport 一次导入的状态
+type entityTagImport struct {
+	EntityType string
+	DryRun     bool
+	Result     *ImportEntityTagsResult
+	// NewTags 本次导入创建的标签，导入结束后批量写入 ES
+	NewTags []*Tag
+	// plannedTagNames dry_run 时需要创建的标签名称，不同批次中的同一个名称只计数一次
+	plannedTagNames map[string]bool
+}
+
+// importTagRef 按名称查询到的标签
+type importTagRef struct {
+	TagID   int    `db:"id"`
+	Name    string `db:"name"`
+	Deleted bool   `db:"deleted"`
+}
+
+// selectImportTagsByName 查询规范化后的名称对应的标签，返回未删除标签的名称到 ID 的映射和已删除标签的名称
+func selectImportTagsByName(ctx context.Context, names []string) (map[string]int, map[string]bool, error) {
+	live := make(map[string]int, len(names))
+	deleted := make(map[string]bool)
+	if len(names) == 0 {
+		return live, deleted, nil
+	}
+
+	query, args, err := sqlx.In(
+		"select id, name, deleted_at is not null as deleted from tag_tbl where tenant_id = ? and name in (?)",
+		TenantIDFromContext(ctx), names,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	refs := []*importTagRef{}
+	if err := dbSelect(ctx, &refs, query, args...); err != nil {
+		return nil, nil, err
+	}
+
+	for _, ref := range refs {
+		if ref.Deleted {
+			deleted[ref.Name] = true
+		} else {
+			live[ref.Name] = ref.TagID
+		}
+	}
+	return live, deleted, nil
+}
+
+// resolveImportTags 把一批行的标签名称解析为标签 ID，不存在的标签使用 BulkInsertTagNames 一次创建。
+// dry_run 时不创建，返回的映射中没有这些名称。名称对应的标签已删除时该行记为错误，不会恢复标签
+func (imp *entityTagImport) resolveImportTags(ctx context.Context, rows []*entityTagImportRow) (map[string]int, map[string]bool, error) {
+	names := make([]string, 0, len(rows))
+	displayNames := make(map[string]string, len(rows))
+	for _, row := range rows {
+		if _, ok := displayNames[row.Name]; !ok {
+			displayNames[row.Name] = row.DisplayName
+			names = append(names, row.Name)
+		}
+	}
+
+	live, deleted, err := selectImportTagsByName(ctx, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missing := []string{}
+	for _, name := range names {
+		if _, ok := live[name]; !ok && !deleted[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return live, deleted, nil
+	}
+
+	if imp.DryRun {
+		for _, name := range missing {
+			if !imp.plannedTagNames[name] {
+				imp.plannedTagNames[name] = true
+				imp.Result.CreatedTags++
+			}
+		}
+		return live, deleted, nil
+	}
+
+	missingDisplayNames := make([]string, 0, len(missing))
+	for _, name := range missing {
+		missingDisplayNames = append(missingDisplayNames, displayNames[name])
+	}
+	tags, err := BulkInsertTagNames(ctx, missingDisplayNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	imp.NewTags = append(imp.NewTags, tags...)
+	imp.Result.CreatedTags += len(tags)
+
+	// 并发请求可能同时创建或删除了同名标签，重新查询这些名称
+	created, createdDeleted, err := selectImportTagsByName(ctx, missing)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, tagID := range created {
+		live[name] = tagID
+	}
+	for name := range createdDeleted {
+		deleted[name] = true
+	}
+	return live, deleted, nil
+}
+
+// importLinkKey 一批行中的一个关联，dry_run 时还没有创建的标签只有名称
+type importLinkKey struct {
+	EntityID int
+	Name     string
+}
+
+// importEntityTagStats 实体关联的标签数量和最大 position
+type importEntityTagStats struct {
+	EntityID int `db:"entity_id"`
+	Count    int `db:"count"`
+	Position int `db:"position"`
+}
+
+// importSelect tx 不为 nil 时在事务中查询，dry_run 时不开启事务
+func importSelect(ctx context.Context, tx *sqlx.Tx, dest interface{}, query string, args ...interface{}) error {
+	if tx != nil {
+		return txSelect(ctx, tx, dest, query, args...)
+	}
+	return dbSelect(ctx, dest, query, args...)
+}
+
+// importEntityTagBatch 解析一批行的标签后在一个事务中写入关联，每个实体的新关联排在已有标签的后面。
+// 已经存在或在本批次中重复的关联计入 SkippedDuplicates，会使实体超过 MAX_TAGS_PER_ENTITY 的行记为错误。
+// dry_run 时不开启事务，只统计会写入的关联
+func (imp *entityTagImport) importEntityTagBatch(ctx context.Context, rows []*entityTagImportRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	live, deleted, err := imp.resolveImportTags(ctx, rows)
+	if err != nil {
+		return err
+	}
+
+	// 标签 ID 到规范化名称的映射，用于按名称判断关联是否已经存在
+	names := make(map[int]string, len(live))
+	for name, tagID := range live {
+		names[tagID] = name
+	}
+
+	candidates := make([]*entityTagImportRow, 0, len(rows))
+	entityIDs := []int{}
+	tagIDs := []int{}
+	seenEntities := make(map[int]bool)
+	seenTags := make(map[int]bool)
+	for _, row := range rows {
+		if deleted[row.Name] {
+			imp.Result.addError(row.Row, "tag is deleted")
+			continue
+		}
+		tagID, ok := live[row.Name]
+		if !ok && !imp.DryRun {
+			imp.Result.addError(row.Row, "tag not found")
+			continue
+		}
+		candidates = append(candidates, row)
+		if !seenEntities[row.EntityID] {
+			seenEntities[row.EntityID] = true
+			entityIDs = append(entityIDs, row.EntityID)
+		}
+		if ok && !seenTags[tagID] {
+			seenTags[tagID] = true
+			tagIDs = append(tagIDs, tagID)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	tenantID := TenantIDFromContext(ctx)
+	var created, skipped int
+	var rowErrors []*ImportRowError
+	plan := func(tx *sqlx.Tx) error {
+		created, skipped, rowErrors = 0, 0, nil
+
+		// 锁住这一批实体后再统计关联的标签数量和 position，避免和单个关联的请求并发时超过上限或得到相同的 position
+		if tx != nil {
+			if err := lockEntitiesTx(ctx, tx, imp.EntityType, entityIDs); err != nil {
+				return err
+			}
+		}
+
+		// 关联到已删除标签的关联不计入数量，position 仍然取所有关联的最大值
+		query, args, err := sqlx.In(
+			"select et.entity_id, count(t.id) as count, max(et.position) as position from entity_tag_tbl et left join tag_tbl t on t.id = et.tag_id and t.deleted_at is null where et.tenant_id = ? and et.entity_type = ? and et.entity_id in (?) and et.deleted_at is null group by et.entity_id",
+			tenantID, imp.EntityType, entityIDs,
+		)
+		if err != nil {
+			return err
+		}
+		stats := []*importEntityTagStats{}
+		if err := importSelect(ctx, tx, &stats, query, args...); err != nil {
+			return err
+		}
+		counts := make(map[int]int, len(stats))
+		positions := make(map[int]int, len(stats))
+		for _, stat := range stats {
+			counts[stat.EntityID] = stat.Count
+			positions[stat.EntityID] = stat.Position
+		}
+
+		linked := make(map[importLinkKey]bool)
+		if len(tagIDs) > 0 {
+			query, args, err := sqlx.In(
+				"select entity_id, tag_id from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id in (?) and tag_id in (?) and deleted_at is null",
+				tenantID, imp.EntityType, entityIDs, tagIDs,
+			)
+			if err != nil {
+				return err
+			}
+			links := []struct {
+				EntityID int `db:"entity_id"`
+				TagID    int `db:"tag_id"`
+			}{}
+			if err := importSelect(ctx, tx, &links, query, args...); err != nil {
+				return err
+			}
+			for _, link := range links {
+				linked[importLinkKey{EntityID: link.EntityID, Name: names[link.TagID]}] = true
+			}
+		}
+
+		placements := []entityTagPlacement{}
+		placeholders := []string{}
+		insertArgs := []interface{}{}
+		refs := []entityTagRef{}
+		for _, row := range candidates {
+			key := importLinkKey{EntityID: row.EntityID, Name: row.Name}
+			if linked[key] {
+				skipped++
+				continue
+			}
+			if config.MaxTagsPerEntity > 0 && counts[row.EntityID] >= config.MaxTagsPerEntity {
+				rowErrors = append(rowErrors, &ImportRowError{
+					Row:    row.Row,
+					Reason: fmt.Sprintf("entity tag limit exceeded, count %d, limit %d", counts[row.EntityID], config.MaxTagsPerEntity),
+				})
+				continue
+			}
+			linked[key] = true
+			counts[row.EntityID]++
+			positions[row.EntityID]++
+			created++
+
+			if tx != nil {
+				placements = append(placements, entityTagPlacement{EntityID: row.EntityID, TagID: live[row.Name], Position: positions[row.EntityID]})
+				placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?)")
+				insertArgs = append(insertArgs, tenantID, imp.EntityType, row.EntityID, live[row.Name], positions[row.EntityID], LinkSourceImport, ActorIDFromContext(ctx))
+				refs = append(refs, entityTagRef{EntityID: row.EntityID, TagID: live[row.Name]})
+			}
+		}
+		if tx == nil || len(placeholders) == 0 {
+			return nil
+		}
+
+		// 取消后还没有被清理的关联直接恢复，保留原来的附加信息和 created_at
+		restored, err := restoreDeletedLinksTx(ctx, tx, imp.EntityType, placements)
+		if err != nil {
+			return err
+		}
+
+		// insert ignore 跳过恢复的关联和并发写入的同一关联，影响的行数即为新写入的数量
+		execResult, execErr := txExec(
+			ctx, tx,
+			"insert ignore into entity_tag_tbl (tenant_id, entity_type, entity_id, tag_id, position, source, added_by) values "+strings.Join(placeholders, ", "),
+			insertArgs...,
+		)
+		if execErr != nil {
+			return execErr
+		}
+		inserted, err := execResult.RowsAffected()
+		if err != nil {
+			return err
+		}
+		skipped += created - restored - int(inserted)
+		created = restored + int(inserted)
+
+		if err := recordEntityTagChangesTx(ctx, tx, EntityTagChangeLink, imp.EntityType, refs); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionEntityImportTags,
+			EntityType: AuditEntityTypeEntity,
+			Metadata:   gin.H{"entity_type": imp.EntityType, "entity_ids": entityIDs, "created": created},
+		})
+	}
+
+	if imp.DryRun {
+		err = plan(nil)
+	} else {
+		err = withTx(ctx, plan)
+	}
+	if err != nil {
+		return err
+	}
+
+	imp.Result.CreatedLinks += created
+	imp.Result.SkippedDuplicates += skipped
+	for _, rowErr := range rowErrors {
+		imp.Result.addError(rowErr.Row, rowErr.Reason)
+	}
+	return nil
+}
+
+// indexImportedTags 导入结束后使用 bulk 接口把新创建的标签写入 ES，每批 reindexBatchSize 个。
+// ES 不可用、请求失败或单个文档写入失败的标签写入 es_outbox_tbl，由 ESOutboxWorker 稍后重新上报
+func indexImportedTags(ctx context.Context, tenantID string, tags []*Tag) {
+	indexed := 0
+	for start := 0; start < len(tags); start += reindexBatchSize {
+		end := start + reindexBatchSize
+		if end > len(tags) {
+			end = len(tags)
+		}
+		batch := tags[start:end]
+
+		var failedIDs []int
+		err := fmt.Errorf("%w: not ready", ErrESUnavailable)
+		if IsESReady() {
+			failedIDs, err = bulkIndexTags(ctx, config.ESIndex, batch)
+		}
+		if err != nil {
+			log.Printf("ESBulkIndexErr: tenant=%s %s", tenantID, err)
+			failedIDs = failedIDs[:0]
+			for _, tag := range batch {
+				failedIDs = append(failedIDs, tag.TagID)
+			}
+		} else {
+			err = errors.New("bulk index item failed")
+		}
+		indexed += len(batch) - len(failedIDs)
+
+		failed := make(map[int]bool, len(failedIDs))
+		for _, tagID := range failedIDs {
+			failed[tagID] = true
+		}
+		for _, tag := range batch {
+			if !failed[tag.TagID] {
+				continue
+			}
+			esIndexFailures.Inc()
+			if enqueueErr := EnqueueESOutbox(ctx, config.ESIndex, tag, err); enqueueErr != nil {
+				log.Printf("ESOutboxEnqueueErr: tag=%d %s", tag.TagID, enqueueErr)
+			}
+		}
+	}
+
+	if indexed > 0 {
+		InvalidateSearchCache(tenantID)
+	}
+}
+
+// entityTagImportReader 逐行读取导入的数据，返回 io.EOF 表示读取结束。
+// 格式错误的行返回 *ImportRowError，调用方记录后继续读取
+type entityTagImportReader func() (int, *ImportEntityTagLine, error)
+
+// newNDJSONEntityTagReader 逐行解析 NDJSON，跳过空行，行号从 1 开始
+func newNDJSONEntityTagReader(r io.Reader) entityTagImportReader {
+	scanner := bufio.NewScanner(r)
+	row := 0
+	return func() (int, *ImportEntityTagLine, error) {
+		for scanner.Scan() {
+			row++
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+
+			line := &ImportEntityTagLine{}
+			if err := json.Unmarshal(text, line); err != nil {
+				return row, nil, &ImportRowError{Row: row, Reason: "invalid json"}
+			}
+			return row, line, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return row, nil, err
+		}
+		return row, nil, io.EOF
+	}
+}
+
+// newCSVEntityTagReader 逐行解析 CSV，两列依次为 entity_id 和 tag_name，第一行第一列为 entity_id 时视为表头
+func newCSVEntityTagReader(r io.Reader) entityTagImportReader {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	row := 0
+	return func() (int, *ImportEntityTagLine, error) {
+		for {
+			record, err := csvReader.Read()
+			if err == io.EOF {
+				return row, nil, io.EOF
+			}
+			row++
+
+			if err != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					return row, nil, &ImportRowError{Row: parseErr.Line, Reason: parseErr.Err.Error()}
+				}
+				return row, nil, err
+			}
+
+			if row == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "entity_id") {
+				continue
+			}
+			if len(record) != 2 {
+				return row, nil, &ImportRowError{Row: row, Reason: "expected 2 columns: entity_id,tag_name"}
+			}
+
+			entityID, err := strconv.Atoi(strings.TrimSpace(record[0]))
+			if err != nil {
+				return row, nil, &ImportRowError{Row: row, Reason: "invalid entity_id"}
+			}
+			return row, &ImportEntityTagLine{EntityID: entityID, TagName: record[1]}, nil
+		}
+	}
+}
+
+// ImportEntityTags 从 next 中逐行读取实体关联，每 importBatchSize 行解析标签并在一个事务中写入，
+// 不把整个请求体读入内存。中途失败时之前的批次已经提交，重新导入时这些关联会计入 SkippedDuplicates。
+// 新创建的标签在全部写入后通过 bulk 接口在后台写入 ES
+func ImportEntityTags(ctx context.Context, entityType string, dryRun bool, next entityTagImportReader) (*ImportEntityTagsResult, error) {
+	imp := &entityTagImport{
+		EntityType:      entityType,
+		DryRun:          dryRun,
+		Result:          &ImportEntityTagsResult{EntityType: entityType, DryRun: dryRun, Errors: []*ImportRowError{}},
+		plannedTagNames: make(map[string]bool),
+	}
+
+	// 已经提交的批次创建的标签即使后面的批次失败也需要写入 ES
+	defer func() {
+		if len(imp.NewTags) > 0 {
+			go indexImportedTags(detachedContext(ctx), TenantIDFromContext(ctx), imp.NewTags)
+		}
+	}()
+
+	batch := make([]*entityTagImportRow, 0, importBatchSize)
+	for {
+		row, line, err := next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *ImportRowError
+		if errors.As(err, &rowErr) {
+			imp.Result.addError(rowErr.Row, rowErr.Reason)
+			continue
+		}
+		if err != nil {
+			if isRequestBodyTooLarge(err) {
+				return nil, newAPIError(http.StatusRequestEntityTooLarge, err.Error())
+			}
+			return nil, newAPIError(http.StatusBadRequest, err.Error())
+		}
+
+		if line.EntityID <= 0 {
+			imp.Result.addError(row, "invalid entity_id")
+			continue
+		}
+		name, err := validateTagName(line.TagName)
+		if err != nil {
+			imp.Result.addError(row, err.Error())
+			continue
+		}
+
+		batch = append(batch, &entityTagImportRow{Row: row, EntityID: line.EntityID, Name: NormalizeTagName(name), DisplayName: name})
+		if len(batch) == importBatchSize {
+			if err := imp.importEntityTagBatch(ctx, batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := imp.importEntityTagBatch(ctx, batch); err != nil {
+		return nil, err
+	}
+	return imp.Result, nil
+}
+
+// OnImportEntityTags 导入实体与标签的关联，用于从其它系统迁移数据。Content-Type 为 text/csv 时每行为 entity_id,tag_name，
+// 为 application/x-ndjson 时每行为 {"entity_id": 1, "tag_name": "..."}。标签不存在时创建，已删除时该行记为错误。
+// dry_run=true 时只校验并返回预计的结果，不写入任何数据
+// @Summary 导入实体关联的标签
+// @Tags entity
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param entity_type query string false "所有行的实体类型，不传时使用 DEFAULT_ENTITY_TYPE"
+// @Param dry_run query bool false "为 true 时只校验，不写入"
+// @Param body body ImportEntityTagLine true "每行一个关联"
+// @Success 200 {object} APIResponse{data=ImportEntityTagsResult}
+// @Failure 400 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 415 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/entity_tags/import [post]
+func OnImportEntityTags(c *gin.Context) {
+	entityType, err := validateEntityType(c.Query("entity_type"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	var next entityTagImportReader
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		next = newCSVEntityTagReader(c.Request.Body)
+	case "application/x-ndjson":
+		next = newNDJSONEntityTagReader(c.Request.Body)
+	default:
+		respondError(c, http.StatusUnsupportedMediaType, "content type must be text/csv or application/x-ndjson")
+		return
+	}
+
+	result, err := ImportEntityTags(c.Request.Context(), entityType, c.Query("dry_run") == "true", next)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, result)
+}
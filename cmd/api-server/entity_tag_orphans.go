@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// orphanScanChunkSize 扫描孤立关联时每次查询的关联 ID 范围，按主键范围查询，不会长时间锁住 entity_tag_tbl
+const orphanScanChunkSize = 1000
+
+// orphanLink 标签已经不存在的关联
+type orphanLink struct {
+	LinkID     int        `db:"id"`
+	EntityType string     `db:"entity_type"`
+	EntityID   int        `db:"entity_id"`
+	TagID      int        `db:"tag_id"`
+	DeletedAt  *time.Time `db:"deleted_at"`
+}
+
+// OrphanTagCount 一个不存在的标签和引用它的关联数量
+type OrphanTagCount struct {
+	TagID int `json:"tag_id"`
+	Links int `json:"links"`
+}
+
+// OrphanLinksReport 孤立关联的扫描结果
+type OrphanLinksReport struct {
+	// Scanned 扫描的关联数量，包括已经取消、还没有被清理的关联
+	Scanned int `json:"scanned"`
+	// Orphans 标签在 tag_tbl 中不存在的关联数量
+	Orphans int `json:"orphans"`
+	// MissingTags 每个不存在的标签被引用的次数，按标签 ID 排列
+	MissingTags []*OrphanTagCount `json:"missing_tags"`
+	// Deleted repair 为 true 时删除的关联数量
+	Deleted int `json:"deleted"`
+}
+
+// deleteOrphanLinksTx 在事务中删除孤立关联。未取消的关联在删除之前记录为取消关联的变更和审计记录，
+// 审计记录的来源从关联记录中读取，需要在删除之前写入
+func deleteOrphanLinksTx(ctx context.Context, tx *sqlx.Tx, links []*orphanLink) (int, error) {
+	refsByType := map[string][]entityTagRef{}
+	linkIDs := make([]int, 0, len(links))
+	for _, link := range links {
+		linkIDs = append(linkIDs, link.LinkID)
+		if link.DeletedAt == nil {
+			refsByType[link.EntityType] = append(refsByType[link.EntityType], entityTagRef{EntityID: link.EntityID, TagID: link.TagID})
+		}
+	}
+
+	for entityType, refs := range refsByType {
+		if err := recordEntityTagChangesTx(ctx, tx, EntityTagChangeUnlink, entityType, refs); err != nil {
+			return 0, err
+		}
+	}
+
+	query, args, err := sqlx.In("delete from entity_tag_tbl where tenant_id = ? and id in (?)", TenantIDFromContext(ctx), linkIDs)
+	if err != nil {
+		return 0, err
+	}
+	execResult, execErr := txExec(ctx, tx, query, args...)
+	if execErr != nil {
+		return 0, execErr
+	}
+	affected, err := execResult.RowsAffected()
+	return int(affected), err
+}
+
+// ScanOrphanLinks 按 ID 范围分批扫描当前租户的关联，找出标签在 tag_tbl 中不存在的关联，
+// repair 为 true 时每批在一个事务中删除找到的关联。已软删除的标签可以恢复，关联到这些标签的关联不算孤立关联
+func ScanOrphanLinks(ctx context.Context, repair bool) (*OrphanLinksReport, error) {
+	tenantID := TenantIDFromContext(ctx)
+	report := &OrphanLinksReport{MissingTags: []*OrphanTagCount{}}
+
+	var maxLinkID int
+	if err := dbGet(ctx, &maxLinkID, "select coalesce(max(id), 0) from entity_tag_tbl where tenant_id = ?", tenantID); err != nil {
+		return nil, err
+	}
+
+	counts := map[int]int{}
+	for start := 0; start < maxLinkID; start += orphanScanChunkSize {
+		end := start + orphanScanChunkSize
+
+		var scanned int
+		if err := dbGet(ctx, &scanned, "select count(*) from entity_tag_tbl where tenant_id = ? and id > ? and id <= ?", tenantID, start, end); err != nil {
+			return nil, err
+		}
+		report.Scanned += scanned
+		if scanned == 0 {
+			continue
+		}
+
+		links := []*orphanLink{}
+		selectErr := dbSelect(
+			ctx, &links,
+			`select e.id, e.entity_type, e.entity_id, e.tag_id, e.deleted_at from entity_tag_tbl e
+			left join tag_tbl t on t.id = e.tag_id and t.tenant_id = e.tenant_id
+			where e.tenant_id = ? and e.id > ? and e.id <= ? and t.id is null order by e.id`,
+			tenantID, start, end,
+		)
+		if selectErr != nil {
+			return nil, selectErr
+		}
+		if len(links) == 0 {
+			continue
+		}
+
+		report.Orphans += len(links)
+		for _, link := range links {
+			counts[link.TagID]++
+		}
+
+		if repair {
+			var deleted int
+			txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+				var err error
+				deleted, err = deleteOrphanLinksTx(ctx, tx, links)
+				return err
+			})
+			if txErr != nil {
+				return nil, txErr
+			}
+			report.Deleted += deleted
+		}
+	}
+
+	for tagID, links := range counts {
+		report.MissingTags = append(report.MissingTags, &OrphanTagCount{TagID: tagID, Links: links})
+	}
+	sort.Slice(report.MissingTags, func(i, j int) bool { return report.MissingTags[i].TagID < report.MissingTags[j].TagID })
+	return report, nil
+}
+
+// OnScanOrphanLinks 扫描当前租户中标签已经不存在的关联，返回每个不存在的标签被引用的次数，
+// repair=true 时同时删除这些关联。这些关联在查询实体的标签时会被跳过，导致关联数量和标签列表不一致
+// @Summary 扫描和清理孤立关联
+// @Tags admin
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param repair query bool false "是否删除找到的孤立关联，默认只扫描"
+// @Success 200 {object} APIResponse{data=OrphanLinksReport}
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/admin/entity_tags/orphans [post]
+func OnScanOrphanLinks(c *gin.Context) {
+	report, err := ScanOrphanLinks(c.Request.Context(), c.Query("repair") == "true")
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, report)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxEntityTagsStreamConns 同时保持的实体标签推送连接数上限，每个连接都会定时查询 MySQL，超过时返回 503
+	maxEntityTagsStreamConns = 500
+	// entityTagsStreamPollInterval 检查实体关联是否变化的间隔
+	entityTagsStreamPollInterval = 2 * time.Second
+	// entityTagsStreamHeartbeatInterval 发送心跳注释的间隔，避免代理因为长时间没有数据而断开连接
+	entityTagsStreamHeartbeatInterval = 15 * time.Second
+)
+
+// entityTagsStreamSem 限制同时保持的实体标签推送连接数
+var entityTagsStreamSem = make(chan struct{}, maxEntityTagsStreamConns)
+
+// entityTagsStreamShutdown 服务关闭时被关闭，通知所有实体标签推送连接退出
+var entityTagsStreamShutdown = make(chan struct{})
+
+// closeEntityTagsStreamsOnce 保证 entityTagsStreamShutdown 只关闭一次
+var closeEntityTagsStreamsOnce sync.Once
+
+// CloseEntityTagsStreams 结束所有实体标签推送连接，注册为 http.Server 的 OnShutdown 回调
+func CloseEntityTagsStreams() {
+	closeEntityTagsStreamsOnce.Do(func() { close(entityTagsStreamShutdown) })
+}
+
+// entityTagsVersion 实体最新的关联变更记录的 ID。新建、取消和恢复关联都会在同一个事务中写入变更记录，
+// 恢复的关联沿用原来的关联 ID，不能用关联的 ID 判断，变更记录的 ID 不变时认为关联没有变化，不需要重新查询标签
+type entityTagsVersion struct {
+	LastChangeID int64 `db:"last_change_id"`
+}
+
+// getEntityTagsVersion 通过 tenant_entity 索引查询实体最新的关联变更记录的 ID，只读取索引
+func getEntityTagsVersion(ctx context.Context, entityType string, entityID int) (entityTagsVersion, error) {
+	var version entityTagsVersion
+	queryErr := dbGet(
+		ctx, &version,
+		"select coalesce(max(id), 0) as last_change_id from entity_tag_change_tbl where tenant_id = ? and entity_type = ? and entity_id = ?",
+		TenantIDFromContext(ctx), entityType, entityID,
+	)
+	return version, queryErr
+}
+
+// diffLinkedTags 比较两次查询的标签，返回新增的标签和被移除的标签 ID
+func diffLinkedTags(prev, curr []*LinkedTag) ([]*LinkedTag, []int) {
+	prevIDs := make(map[int]bool, len(prev))
+	for _, tag := range prev {
+		prevIDs[tag.TagID] = true
+	}
+	currIDs := make(map[int]bool, len(curr))
+	added := []*LinkedTag{}
+	for _, tag := range curr {
+		currIDs[tag.TagID] = true
+		if !prevIDs[tag.TagID] {
+			added = append(added, tag)
+		}
+	}
+
+	removed := []int{}
+	for _, tag := range prev {
+		if !currIDs[tag.TagID] {
+			removed = append(removed, tag.TagID)
+		}
+	}
+	return added, removed
+}
+
+// OnEntityTagsStream 通过 Server-Sent Events 推送实体关联的标签。连接建立后推送一次 snapshot 事件，
+// data 为 {"entity_type", "entity_id", "tags": [LinkedTag]}，之后每 2 秒按实体最新的关联变更记录检查是否变化，
+// 有变化时推送 delta 事件，data 为 {"added": [LinkedTag], "removed": [tag_id]}。
+// 只检测关联的新建和删除，权重、顺序等字段的修改不会推送
+// @Summary 订阅实体关联的标签
+// @Tags entity
+// @Produce text/event-stream
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param entity_id query int true "实体 ID"
+// @Param entity_type query string false "实体类型，不传时使用 DEFAULT_ENTITY_TYPE"
+// @Success 200 {string} string "SSE 事件流，先推送 snapshot 事件，之后推送 delta 事件"
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 503 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/entity_tags/stream [get]
+func OnEntityTagsStream(c *gin.Context) {
+	entityID, ok := parseIntQuery(c, "entity_id", 0)
+	if !ok {
+		return
+	}
+	if entityID == 0 {
+		respondError(c, http.StatusBadRequest, "entity_id is required")
+		return
+	}
+
+	entityType, err := validateEntityType(c.Query("entity_type"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		respondError(c, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	select {
+	case entityTagsStreamSem <- struct{}{}:
+		defer func() { <-entityTagsStreamSem }()
+	default:
+		respondError(c, http.StatusServiceUnavailable, "too many entity tags stream connections")
+		return
+	}
+
+	// 先读取版本再读取标签，两次查询之间的变化会在下一次检查时推送，不会遗漏
+	ctx := c.Request.Context()
+	version, err := getEntityTagsVersion(ctx, entityType, entityID)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+	tags, err := GetEntityTags(ctx, entityType, entityID, EntityTagsOptions{})
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// 关闭 nginx 的响应缓冲，否则事件会被攒到一起才发给客户端
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	snapshot := gin.H{"entity_type": entityType, "entity_id": entityID, "tags": tags}
+	if err := writeSSEEvent(c, flusher, "snapshot", snapshot); err != nil {
+		return
+	}
+
+	pollTicker := time.NewTicker(entityTagsStreamPollInterval)
+	defer pollTicker.Stop()
+	heartbeatTicker := time.NewTicker(entityTagsStreamHeartbeatInterval)
+	defer heartbeatTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-entityTagsStreamShutdown:
+			return
+		case <-heartbeatTicker.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-pollTicker.C:
+			// 查询失败时保留上一次的状态，下一次检查时重试
+			currVersion, err := getEntityTagsVersion(ctx, entityType, entityID)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[WARN] EntityTagsStreamPollErr: %s", err)
+				}
+				continue
+			}
+			if currVersion == version {
+				continue
+			}
+
+			currTags, err := GetEntityTags(ctx, entityType, entityID, EntityTagsOptions{})
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[WARN] EntityTagsStreamPollErr: %s", err)
+				}
+				continue
+			}
+			version = currVersion
+
+			added, removed := diffLinkedTags(tags, currTags)
+			tags = currTags
+			if len(added) == 0 && len(removed) == 0 {
+				continue
+			}
+			if err := writeSSEEvent(c, flusher, "delta", gin.H{"added": added, "removed": removed}); err != nil {
+				return
+			}
+		}
+	}
+}
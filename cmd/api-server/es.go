@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+// GetESAliasIndices 查询别名当前指向的索引，别名不存在时返回空列表
+func GetESAliasIndices(ctx context.Context, alias string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+
+	resp, err := esClient.Indices.GetAlias(
+		esClient.Indices.GetAlias.WithContext(ctx),
+		esClient.Indices.GetAlias.WithName(alias),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return []string{}, nil
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("get alias %s: %s", alias, resp.String())
+	}
+
+	// 响应为 {"索引名": {"aliases": {...}}}，只需要索引名
+	var indexMap map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&indexMap); err != nil {
+		return nil, err
+	}
+
+	indices := make([]string, 0, len(indexMap))
+	for index := range indexMap {
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+// SwitchESAlias 在一次请求中把别名从原来的索引上移除并指向 index，切换是原子的，
+// 搜索请求不会读到没有索引的别名。用于重建索引完成后把搜索别名切换到新索引
+func SwitchESAlias(ctx context.Context, alias, index string) error {
+	oldIndices, err := GetESAliasIndices(ctx, alias)
+	if err != nil {
+		return err
+	}
+
+	actions := make([]esAliasAction, 0, len(oldIndices)+1)
+	for _, oldIndex := range oldIndices {
+		actions = append(actions, esAliasAction{Remove: &esAliasTarget{Index: oldIndex, Alias: alias}})
+	}
+	actions = append(actions, esAliasAction{Add: &esAliasTarget{Index: index, Alias: alias}})
+	body := struct {
+		Actions []esAliasAction `json:"actions"`
+	}{Actions: actions}
+
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+
+	resp, err := esClient.Indices.UpdateAliases(
+		mustToJSONBuffer(&body),
+		esClient.Indices.UpdateAliases.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("switch alias %s to %s: %s", alias, index, resp.String())
+	}
+	return nil
+}
+
+// esRetryRand 计算重试等待时间的随机抖动，rand.Rand 不能并发使用，需要加锁
+var esRetryRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// esRetryBackoff 返回第 attempt 次重试前等待的时间，在指数退避的基础上随机取 [d/2, d) 之间的值，
+// 避免多个请求在 ES 恢复时同时重试
+func esRetryBackoff(attempt int) time.Duration {
+	d := config.ESIndexRetryBackoff << uint(attempt-1)
+	if d <= 1 {
+		return d
+	}
+
+	esRetryRand.Lock()
+	defer esRetryRand.Unlock()
+	return d/2 + time.Duration(esRetryRand.Int63n(int64(d/2)))
+}
+
+// DoESRequestWithRetry 调用 do 发出 ES 请求，网络错误和 5xx 响应时按指数退避重试，最多尝试 maxAttempts 次，
+// 4xx 响应说明请求本身有问题，直接返回。每次请求的超时时间为 config.QueryTimeout，ctx 结束后不再重试。
+// 返回的响应体已经读入内存，不受请求超时的影响
+func DoESRequestWithRetry(ctx context.Context, maxAttempts int, do func(ctx context.Context) (*esapi.Response, error)) (*esapi.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := doESRequestOnce(ctx, do)
+		retryable := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		if !retryable || attempt >= maxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		if err != nil {
+			log.Printf("[WARN] ESRequestRetry: attempt=%d %s", attempt, err)
+		} else {
+			log.Printf("[WARN] ESRequestRetry: attempt=%d %s", attempt, resp.Status())
+		}
+		esIndexRetries.Inc()
+
+		// 等待期间 ctx 结束时返回最后一次的结果
+		timer := time.NewTimer(esRetryBackoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+	}
+}
+
+// doESRequestOnce 使用单次请求的超时时间发出请求，并在超时取消前读完响应体
+func doESRequestOnce(ctx context.Context, do func(ctx context.Context) (*esapi.Response, error)) (*esapi.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+
+	resp, err := do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// 搜索标签名称的匹配方式
+const (
+	// ESSearchMatchPrefix 使用 match_phrase_prefix，只匹配以关键字开头的名称
+	ESSearchMatchPrefix = "prefix"
+	// ESSearchMatchInfix 使用 name.ngram 子字段，匹配包含关键字的名称，例如 script 可以匹配 javascript
+	ESSearchMatchInfix = "infix"
+	// ESSearchMatchWildcard 关键字为通配符模式，对 name.keyword 使用不区分大小写的 wildcard 查询，
+	// 只能在请求中指定，不能作为 ES_SEARCH_MATCH 的默认值
+	ESSearchMatchWildcard = "wildcard"
+)
+
+// 关键字包含多个以空白分隔的词时，多个词之间的组合方式
+const (
+	// SearchModeOr 匹配任意一个词即可，匹配的词越多相关度越高
+	SearchModeOr = "or"
+	// SearchModeAnd 需要匹配所有的词
+	SearchModeAnd = "and"
+)
+
+// maxSearchTerms 关键字最多可以包含的词数
+const maxSearchTerms = 10
+
+// maxWildcardPatternLength 通配符模式的最大长度，限制 wildcard 查询的开销
+const maxWildcardPatternLength = 50
+
+// isValidESSearchMatch 判断是否为可以作为默认值的匹配方式
+func isValidESSearchMatch(match string) bool {
+	return match == ESSearchMatchPrefix || match == ESSearchMatchInfix
+}
+
+// validateWildcardPattern 校验通配符模式，需要包含 * 或 ?，并且至少包含一个普通字符，避免扫描整个索引
+func validateWildcardPattern(pattern string) error {
+	if utf8.RuneCountInString(pattern) > maxWildcardPatternLength {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("wildcard pattern too long, max %d characters", maxWildcardPatternLength))
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		return newAPIError(http.StatusBadRequest, "wildcard pattern must contain * or ?")
+	}
+	if strings.Trim(pattern, "*?") == "" {
+		return newAPIError(http.StatusBadRequest, "wildcard pattern must contain at least one literal character")
+	}
+	return nil
+}
+
+// esTagIndexBody 标签索引的 settings 和 mappings。name.ngram 使用 1~2 个字符的 ngram 分词，
+// 搜索时关键字使用同样的分词并要求所有片段都匹配，从而支持名称中任意位置的匹配。
+// tenant_id 保持和动态 mapping 相同的结构，搜索使用 tenant_id.keyword 过滤。
+// synonyms 使用和 name 相同的子字段，每种匹配方式同时搜索名称和同义词。
+// metadata 的键由调用方决定，只保存在 _source 中不建立索引，避免 mapping 中的字段数量不断增加
+const esTagIndexBody = `{
+  "settings": {
+    "analysis": {
+      "tokenizer": {
+        "tag_name_ngram": {
+          "type": "ngram",
+          "min_gram": 1,
+          "max_gram": 2,
+          "token_chars": ["letter", "digit"]
+        }
+      },
+      "analyzer": {
+        "tag_name_ngram": {
+          "type": "custom",
+          "tokenizer": "tag_name_ngram",
+          "filter": ["lowercase"]
+        }
+      }
+    }
+  },
+  "mappings": {
+    "properties": {
+      "tag_id": {"type": "long"},
+      "tenant_id": {
+        "type": "text",
+        "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}
+      },
+      "name": {
+        "type": "text",
+        "fields": {
+          "keyword": {"type": "keyword", "ignore_above": 256},
+          "ngram": {"type": "text", "analyzer": "tag_name_ngram"}
+        }
+      },
+      "display_name": {"type": "keyword", "index": false},
+      "synonyms": {
+        "type": "text",
+        "fields": {
+          "keyword": {"type": "keyword", "ignore_above": 256},
+          "ngram": {"type": "text", "analyzer": "tag_name_ngram"}
+        }
+      },
+      "metadata": {"type": "object", "enabled": false},
+      "created_at": {"type": "date"},
+      "updated_at": {"type": "date"}
+    }
+  }
+}`
+
+// EnsureESTagIndex 索引不存在时使用 esTagIndexBody 创建索引，已存在时不做修改。
+// 已有索引的 mapping 不能修改分词器，需要写入新的索引后切换别名
+func EnsureESTagIndex(ctx context.Context, index string) error {
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+
+	existsResp, err := esClient.Indices.Exists(
+		[]string{index},
+		esClient.Indices.Exists.WithContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+	existsResp.Body.Close()
+
+	if existsResp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if existsResp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("check index %s: %s", index, existsResp.Status())
+	}
+
+	resp, err := esClient.Indices.Create(
+		index,
+		esClient.Indices.Create.WithContext(ctx),
+		esClient.Indices.Create.WithBody(strings.NewReader(esTagIndexBody)),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return fmt.Errorf("create index %s: %s", index, resp.String())
+	}
+	return nil
+}
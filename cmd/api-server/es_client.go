@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/estransport"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// esProbeTimeout 启动时检查每个 ES 地址的超时时间
+const esProbeTimeout = 2 * time.Second
+
+// primaryFirstSelector 按 ES_ADDRESSES 中的顺序选择第一个可用的节点。
+// 节点请求失败后被连接池标记为不可用，之后的请求和重试发往下一个地址，
+// 连接池在一段时间后（60 秒起翻倍）恢复该节点，恢复后请求重新发往排在前面的节点
+type primaryFirstSelector struct {
+	// rank 地址在 ES_ADDRESSES 中的位置
+	rank map[string]int
+}
+
+// Select 实现 estransport.Selector，conns 为连接池中当前可用的节点
+func (s *primaryFirstSelector) Select(conns []*estransport.Connection) (*estransport.Connection, error) {
+	if len(conns) == 0 {
+		return nil, errors.New("no connection available")
+	}
+
+	selected := conns[0]
+	for _, conn := range conns[1:] {
+		if s.rank[conn.URL.String()] < s.rank[selected.URL.String()] {
+			selected = conn
+		}
+	}
+	return selected, nil
+}
+
+// probeESAddresses 并发请求每个地址的根路径，记录哪些地址可以连接，返回可以连接的数量。
+// 只用于启动时的日志，不可用的地址仍然加入连接池，恢复后可以继续使用
+func probeESAddresses(urls []*url.URL) int {
+	client := &http.Client{Timeout: esProbeTimeout}
+	reachable := make([]bool, len(urls))
+
+	var wg sync.WaitGroup
+	for index, u := range urls {
+		wg.Add(1)
+		go func(index int, u *url.URL) {
+			defer wg.Done()
+
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+			if err != nil {
+				log.Printf("[WARN] ESProbeErr: addr=%s %s", u.Redacted(), err)
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				log.Printf("[WARN] ESProbeErr: addr=%s %s", u.Redacted(), err)
+				return
+			}
+			resp.Body.Close()
+			// 需要认证的集群返回 401 也说明节点可以连接
+			if resp.StatusCode >= http.StatusInternalServerError {
+				log.Printf("[WARN] ESProbeErr: addr=%s status=%d", u.Redacted(), resp.StatusCode)
+				return
+			}
+			reachable[index] = true
+		}(index, u)
+	}
+	wg.Wait()
+
+	count := 0
+	for index, u := range urls {
+		if reachable[index] {
+			count++
+			log.Printf("ESProbeOk: addr=%s primary=%t", u.Redacted(), index == 0)
+		}
+	}
+	return count
+}
+
+// NewResilientESClient 使用 addrs 中的所有地址创建 ES 客户端，创建前检查每个地址并记录可以连接的地址。
+// 请求优先发往排在前面的地址，连接失败或返回 502、503、504 时由客户端重试，并把请求发往下一个地址。
+// 没有地址可以连接时只记录日志，不返回错误，ES 恢复后由 WaitForES 发现
+func NewResilientESClient(addrs []string) (*elasticsearch7.Client, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no elasticsearch address")
+	}
+
+	// 与客户端解析地址的方式相同，去掉末尾的 /，连接的 URL 才能和 rank 对应
+	urls := make([]*url.URL, 0, len(addrs))
+	rank := make(map[string]int, len(addrs))
+	for index, addr := range addrs {
+		u, err := url.Parse(strings.TrimRight(addr, "/"))
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.New("invalid elasticsearch address: " + addr)
+		}
+		urls = append(urls, u)
+		rank[u.String()] = index
+	}
+
+	if reachable := probeESAddresses(urls); reachable == 0 {
+		log.Printf("[WARN] ESProbeErr: none of %d addresses is reachable", len(urls))
+	}
+
+	// otelhttp 为每个 ES 请求创建 span，并把链路信息写入请求头
+	return elasticsearch7.NewClient(elasticsearch7.Config{
+		Addresses: addrs,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		Selector:  &primaryFirstSelector{rank: rank},
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// esIndexDelayedHeader 写入 ES 失败、标签进入 outbox 时返回的响应头
+	esIndexDelayedHeader = "X-ES-Index-Delayed"
+	// esOutboxInterval ESOutboxWorker 处理 outbox 的间隔
+	esOutboxInterval = 10 * time.Second
+	// esOutboxBatchSize 每次从 es_outbox_tbl 中读取的记录数量
+	esOutboxBatchSize = 100
+	// esOutboxMaxErrorLength last_error 字段保存的最大字符数
+	esOutboxMaxErrorLength = 255
+)
+
+// esOutboxItem es_outbox_tbl 中等待重新上报的标签
+type esOutboxItem struct {
+	ID        int64  `db:"id"`
+	TenantID  string `db:"tenant_id"`
+	TagID     int    `db:"tag_id"`
+	IndexName string `db:"index_name"`
+}
+
+// truncateOutboxError 把错误信息截断到 last_error 字段的长度
+func truncateOutboxError(err error) string {
+	msg := err.Error()
+	if utf8.RuneCountInString(msg) <= esOutboxMaxErrorLength {
+		return msg
+	}
+	return string([]rune(msg)[:esOutboxMaxErrorLength])
+}
+
+// EnqueueESOutbox 把写入或删除 ES 文档失败的标签写入 es_outbox_tbl，同一个索引的同一个标签只保留一条记录。
+// 只记录标签 ID，重新上报时读取标签的最新数据，标签已经删除时删除文档
+func EnqueueESOutbox(ctx context.Context, index string, tag *Tag, cause error) error {
+	_, execErr := dbExec(
+		ctx,
+		"insert into es_outbox_tbl (tenant_id, tag_id, index_name, last_error) values (?, ?, ?, ?) on duplicate key update last_error = values(last_error)",
+		tag.TenantID, tag.TagID, index, truncateOutboxError(cause),
+	)
+	return execErr
+}
+
+// ProcessESOutbox 按写入顺序重新上报 es_outbox_tbl 中的标签，成功后删除记录，返回处理成功的数量。
+// 标签已经被删除（包括软删除和合并后删除）时从 ES 中删除文档，文档不存在同样视为成功。
+// 某个标签处理失败时记录错误并结束本次处理，ES 通常仍然不可用，下次运行时重试
+func ProcessESOutbox(ctx context.Context) (int, error) {
+	if !IsESReady() {
+		return 0, nil
+	}
+
+	processed := 0
+	for {
+		items := []*esOutboxItem{}
+		if queryErr := dbSelect(ctx, &items, "select id, tenant_id, tag_id, index_name from es_outbox_tbl order by id limit ?", esOutboxBatchSize); queryErr != nil {
+			return processed, queryErr
+		}
+
+		for _, item := range items {
+			// outbox 由所有租户共享，标签 ID 全局唯一，不需要按租户查询
+			var tag Tag
+			queryErr := dbGet(ctx, &tag, "select "+tagColumns+" from tag_tbl where id = ?", item.TagID)
+			if queryErr != nil && queryErr != sql.ErrNoRows {
+				return processed, queryErr
+			}
+
+			esCtx, cancel := context.WithTimeout(ctx, config.QueryTimeout*time.Duration(config.ESIndexMaxAttempts))
+			var err error
+			if queryErr == nil && tag.DeletedAt == nil {
+				err = IndexTagToES(esCtx, item.IndexName, &tag)
+			} else {
+				err = DeleteTagDocFromES(esCtx, item.IndexName, item.TenantID, item.TagID)
+			}
+			cancel()
+			if err != nil {
+				if _, execErr := dbExec(ctx, "update es_outbox_tbl set attempts = attempts + 1, last_error = ? where id = ?", truncateOutboxError(err), item.ID); execErr != nil {
+					log.Printf("ESOutboxUpdateErr: %s", execErr)
+				}
+				return processed, err
+			}
+			processed++
+
+			if _, execErr := dbExec(ctx, "delete from es_outbox_tbl where id = ?", item.ID); execErr != nil {
+				return processed, execErr
+			}
+		}
+
+		if len(items) < esOutboxBatchSize {
+			return processed, nil
+		}
+	}
+}
+
+// ESOutboxWorker 定期重新上报 es_outbox_tbl 中标签的后台任务
+type ESOutboxWorker struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartESOutboxWorker 每隔 interval 重新上报一次 es_outbox_tbl 中的标签
+func StartESOutboxWorker(interval time.Duration) *ESOutboxWorker {
+	w := &ESOutboxWorker{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+
+			processed, err := ProcessESOutbox(context.Background())
+			if err != nil {
+				log.Printf("ProcessESOutboxErr: %s", err)
+			}
+			if processed > 0 {
+				log.Printf("ProcessESOutboxOk: %d", processed)
+			}
+		}
+	}()
+	return w
+}
+
+// Stop 通知后台任务退出，并等待正在进行的一轮上报完成或 ctx 结束。
+// 没有上报完的标签仍然保存在 es_outbox_tbl 中，下次启动后继续处理
+func (w *ESOutboxWorker) Stop(ctx context.Context) error {
+	close(w.stop)
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
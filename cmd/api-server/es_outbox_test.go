@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+)
+
+// setupMockES 使用 httptest 替换全局的 ES 客户端，handler 处理所有 ES 请求，测试结束后恢复
+func setupMockES(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	es, err := elasticsearch7.NewClient(elasticsearch7.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatalf("elasticsearch7.NewClient: %s", err)
+	}
+
+	prevClient, prevReady := esClient, atomic.LoadInt32(&esReady)
+	esClient = es
+	atomic.StoreInt32(&esReady, 1)
+	t.Cleanup(func() {
+		srv.Close()
+		esClient = prevClient
+		atomic.StoreInt32(&esReady, prevReady)
+	})
+}
+
+func TestProcessESOutboxDeletesRemovedTags(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	cases := []struct {
+		name string
+		// tagRows 查询标签的结果，没有行表示标签已经不存在
+		tagRows  *sqlmock.Rows
+		esStatus int
+		// removed 是否删除 outbox 记录
+		removed bool
+	}{
+		{
+			name: "soft deleted",
+			tagRows: sqlmock.NewRows(strings.Split(tagColumns, ", ")).
+				AddRow(7, "t1", "go", "Go", "", "", "", 1, now, now, now, nil),
+			esStatus: http.StatusOK,
+			removed:  true,
+		},
+		{
+			name:     "missing document",
+			tagRows:  sqlmock.NewRows(strings.Split(tagColumns, ", ")),
+			esStatus: http.StatusNotFound,
+			removed:  true,
+		},
+		{
+			name:     "es error",
+			tagRows:  sqlmock.NewRows(strings.Split(tagColumns, ", ")),
+			esStatus: http.StatusInternalServerError,
+			removed:  false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := setupMockDB(t)
+			config.ESIndexMaxAttempts = 1
+
+			var deletePath string
+			setupMockES(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodDelete {
+					deletePath = r.URL.Path
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.esStatus)
+				w.Write([]byte(`{}`))
+			})
+
+			mock.ExpectQuery(regexp.QuoteMeta("select id, tenant_id, tag_id, index_name from es_outbox_tbl order by id limit ?")).
+				WithArgs(esOutboxBatchSize).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "tag_id", "index_name"}).AddRow(1, "t1", 7, "tags"))
+			mock.ExpectQuery(regexp.QuoteMeta("select " + tagColumns + " from tag_tbl where id = ?")).
+				WithArgs(7).
+				WillReturnRows(tc.tagRows)
+			if tc.removed {
+				mock.ExpectExec(regexp.QuoteMeta("delete from es_outbox_tbl where id = ?")).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			} else {
+				mock.ExpectExec(regexp.QuoteMeta("update es_outbox_tbl set attempts = attempts + 1")).
+					WithArgs(sqlmock.AnyArg(), 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			processed, err := ProcessESOutbox(context.Background())
+			if tc.removed && (err != nil || processed != 1) {
+				t.Errorf("ProcessESOutbox = (%d, %v), want (1, nil)", processed, err)
+			}
+			if !tc.removed && (err == nil || processed != 0) {
+				t.Errorf("ProcessESOutbox = (%d, %v), want (0, error)", processed, err)
+			}
+			if deletePath != "/tags/_doc/7" {
+				t.Errorf("delete path = %q, want %q", deletePath, "/tags/_doc/7")
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %s", err)
+			}
+		})
+	}
+}
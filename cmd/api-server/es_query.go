@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// ESQueryBuilder ES 查询子句，通过 MatchPhrasePrefix、Term、Bool 等函数构造，
+// 子句类型和字段在编译期确定，序列化时输出 {"<子句类型>": {...}}
+type ESQueryBuilder struct {
+	kind string
+	body interface{}
+}
+
+// MarshalJSON 把查询子句序列化为 ES 的查询 DSL
+func (q ESQueryBuilder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{q.kind: q.body})
+}
+
+// Build 返回只包含该查询子句的搜索请求体
+func (q ESQueryBuilder) Build() io.Reader {
+	return (&ESSearchRequest{Query: q}).Build()
+}
+
+// esBoolQuery bool 查询的各组子句，为空的组不输出
+type esBoolQuery struct {
+	Must    []ESQueryBuilder `json:"must,omitempty"`
+	Should  []ESQueryBuilder `json:"should,omitempty"`
+	MustNot []ESQueryBuilder `json:"must_not,omitempty"`
+	Filter  []ESQueryBuilder `json:"filter,omitempty"`
+}
+
+// esFuzzyQuery fuzzy 查询的参数
+type esFuzzyQuery struct {
+	Value     string `json:"value"`
+	Fuzziness string `json:"fuzziness,omitempty"`
+}
+
+// MatchPhrasePrefix 构造 match_phrase_prefix 查询，匹配以 value 为前缀的短语
+func MatchPhrasePrefix(field, value string) ESQueryBuilder {
+	return ESQueryBuilder{kind: "match_phrase_prefix", body: map[string]string{field: value}}
+}
+
+// esMatchQuery match 查询的参数
+type esMatchQuery struct {
+	Query    string `json:"query"`
+	Operator string `json:"operator,omitempty"`
+}
+
+// Match 构造 match 查询，operator 为 and 时需要匹配分词后的所有词项，为空时使用 ES 的默认值 or
+func Match(field, value string, operator string) ESQueryBuilder {
+	return ESQueryBuilder{kind: "match", body: map[string]esMatchQuery{field: {Query: value, Operator: operator}}}
+}
+
+// Fuzzy 构造 fuzzy 查询，fuzziness 为空时使用 ES 的默认值
+func Fuzzy(field, value string, fuzziness string) ESQueryBuilder {
+	return ESQueryBuilder{kind: "fuzzy", body: map[string]esFuzzyQuery{field: {Value: value, Fuzziness: fuzziness}}}
+}
+
+// esWildcardQuery wildcard 查询的参数
+type esWildcardQuery struct {
+	Value           string `json:"value"`
+	CaseInsensitive bool   `json:"case_insensitive,omitempty"`
+}
+
+// Wildcard 构造 wildcard 查询，value 中 * 匹配任意个字符，? 匹配一个字符。
+// caseInsensitive 需要 ES 7.10 及以上版本
+func Wildcard(field, value string, caseInsensitive bool) ESQueryBuilder {
+	return ESQueryBuilder{kind: "wildcard", body: map[string]esWildcardQuery{field: {Value: value, CaseInsensitive: caseInsensitive}}}
+}
+
+// Term 构造 term 查询，精确匹配字段的值
+func Term(field string, value interface{}) ESQueryBuilder {
+	return ESQueryBuilder{kind: "term", body: map[string]interface{}{field: value}}
+}
+
+// Terms 构造 terms 查询，字段的值等于 values 中任意一个即匹配
+func Terms(field string, values interface{}) ESQueryBuilder {
+	return ESQueryBuilder{kind: "terms", body: map[string]interface{}{field: values}}
+}
+
+// Bool 构造 bool 查询，过滤条件通过 Filter 追加
+func Bool(must, should, mustNot []ESQueryBuilder) ESQueryBuilder {
+	return ESQueryBuilder{kind: "bool", body: &esBoolQuery{Must: must, Should: should, MustNot: mustNot}}
+}
+
+// Filter 向 bool 查询追加不参与评分的过滤条件，对其它类型的查询调用时 panic
+func (q ESQueryBuilder) Filter(filters ...ESQueryBuilder) ESQueryBuilder {
+	boolQuery, ok := q.body.(*esBoolQuery)
+	if !ok {
+		panic("es query: Filter called on " + q.kind + " query")
+	}
+
+	merged := &esBoolQuery{
+		Must:    boolQuery.Must,
+		Should:  boolQuery.Should,
+		MustNot: boolQuery.MustNot,
+		Filter:  append(append([]ESQueryBuilder{}, boolQuery.Filter...), filters...),
+	}
+	return ESQueryBuilder{kind: q.kind, body: merged}
+}
+
+// ESSort 搜索结果的排序条件，例如 {"_score": "desc"}
+type ESSort map[string]string
+
+// ESSearchRequest 搜索请求体
+type ESSearchRequest struct {
+	Query       ESQueryBuilder `json:"query"`
+	Sort        []ESSort       `json:"sort,omitempty"`
+	Size        int            `json:"size,omitempty"`
+	SearchAfter []interface{}  `json:"search_after,omitempty"`
+	MinScore    float64        `json:"min_score,omitempty"`
+	Highlight   *ESHighlight   `json:"highlight,omitempty"`
+}
+
+// ESHighlight 搜索请求的 highlight 参数，匹配的片段使用 PreTags 和 PostTags 包围
+type ESHighlight struct {
+	PreTags  []string `json:"pre_tags"`
+	PostTags []string `json:"post_tags"`
+	// Encoder 为 html 时先转义字段原文再插入标签，结果可以直接作为 HTML 渲染
+	Encoder string                      `json:"encoder,omitempty"`
+	Fields  map[string]esHighlightField `json:"fields"`
+}
+
+// esHighlightField highlight 中单个字段的参数
+type esHighlightField struct {
+	// NumberOfFragments 为 0 时返回整个字段的值，不切分片段
+	NumberOfFragments int `json:"number_of_fragments"`
+}
+
+// HighlightFields 构造对 fields 整个值高亮的 highlight 参数，匹配的片段使用 <em></em> 包围，原文按 HTML 转义
+func HighlightFields(fields ...string) *ESHighlight {
+	highlight := &ESHighlight{
+		PreTags:  []string{"<em>"},
+		PostTags: []string{"</em>"},
+		Encoder:  "html",
+		Fields:   make(map[string]esHighlightField, len(fields)),
+	}
+	for _, field := range fields {
+		highlight.Fields[field] = esHighlightField{NumberOfFragments: 0}
+	}
+	return highlight
+}
+
+// Build 将搜索请求序列化为 JSON，查询子句都是确定的类型，序列化失败说明代码有问题，直接 panic
+func (r *ESSearchRequest) Build() io.Reader {
+	return mustToJSONBuffer(r)
+}
+
+// esAliasAction _aliases 接口的一个操作，Add 和 Remove 只设置其中一个
+type esAliasAction struct {
+	Add    *esAliasTarget `json:"add,omitempty"`
+	Remove *esAliasTarget `json:"remove,omitempty"`
+}
+
+// esAliasTarget 别名操作的索引和别名
+type esAliasTarget struct {
+	Index string `json:"index"`
+	Alias string `json:"alias"`
+}
+
+// mustToJSONBuffer 将 v 转换成 JSON 并返回对应的 Buffer
+func mustToJSONBuffer(v interface{}) *bytes.Buffer {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		panic(err)
+	}
+
+	return &buf
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// O 替换为 ESQueryBuilder 之前构造查询使用的 map，用于和原来的查询体比较
+type O map[string]interface{}
+
+// decodeJSON 把 v 序列化后再反序列化为通用的 JSON 值，数字统一为 float64，便于比较结构
+func decodeJSON(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %s", err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal %s: %s", data, err)
+	}
+	return decoded
+}
+
+// normalizeBoolClauses 把 bool 查询中单个对象形式的子句组改写为数组，ES 对两种形式的处理相同，
+// 原来的查询体中 must 是单个对象，ESQueryBuilder 总是输出数组
+func normalizeBoolClauses(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			value[key] = normalizeBoolClauses(child)
+		}
+		if boolQuery, ok := value["bool"].(map[string]interface{}); ok {
+			for _, group := range []string{"must", "should", "must_not", "filter"} {
+				if clause, ok := boolQuery[group].(map[string]interface{}); ok {
+					boolQuery[group] = []interface{}{clause}
+				}
+			}
+		}
+		return value
+	case []interface{}:
+		for i, child := range value {
+			value[i] = normalizeBoolClauses(child)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+func TestESQueryBuilderMatchesLegacyQuery(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  ESQueryBuilder
+		legacy O
+	}{
+		{
+			name:   "match_phrase_prefix",
+			query:  MatchPhrasePrefix("name", "go"),
+			legacy: O{"match_phrase_prefix": O{"name": "go"}},
+		},
+		{
+			name:   "fuzzy",
+			query:  Fuzzy("name", "golang", "AUTO"),
+			legacy: O{"fuzzy": O{"name": O{"value": "golang", "fuzziness": "AUTO"}}},
+		},
+		{
+			name:   "fuzzy without fuzziness",
+			query:  Fuzzy("name", "golang", ""),
+			legacy: O{"fuzzy": O{"name": O{"value": "golang"}}},
+		},
+		{
+			name:   "term",
+			query:  Term("tenant_id.keyword", "t1"),
+			legacy: O{"term": O{"tenant_id.keyword": "t1"}},
+		},
+		{
+			name:   "terms",
+			query:  Terms("tag_id", []int{1, 2}),
+			legacy: O{"terms": O{"tag_id": []int{1, 2}}},
+		},
+		{
+			name: "bool with filter",
+			query: Bool([]ESQueryBuilder{MatchPhrasePrefix("name", "go")}, nil, nil).
+				Filter(Term("tenant_id.keyword", "t1"), Terms("tag_id", []int{1, 2})),
+			legacy: O{
+				"bool": O{
+					"must": O{
+						"match_phrase_prefix": O{
+							"name": "go",
+						},
+					},
+					"filter": []O{
+						{"term": O{"tenant_id.keyword": "t1"}},
+						{"terms": O{"tag_id": []int{1, 2}}},
+					},
+				},
+			},
+		},
+		{
+			name:  "bool should and must_not",
+			query: Bool(nil, []ESQueryBuilder{Term("a", 1), Term("b", 2)}, []ESQueryBuilder{Term("c", 3)}),
+			legacy: O{
+				"bool": O{
+					"should":   []O{{"term": O{"a": 1}}, {"term": O{"b": 2}}},
+					"must_not": []O{{"term": O{"c": 3}}},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := normalizeBoolClauses(decodeJSON(t, tc.query))
+			want := normalizeBoolClauses(decodeJSON(t, tc.legacy))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("query = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestESSearchRequestMatchesLegacyQuery(t *testing.T) {
+	// 与替换之前 SearchTagsFromES 构造的查询体相同，size 和 search_after 只在传入时输出
+	legacySearch := func(size int, searchAfter []interface{}) O {
+		query := O{
+			"query": O{
+				"bool": O{
+					"must": O{
+						"match_phrase_prefix": O{
+							"name": "go",
+						},
+					},
+					"filter": []O{
+						{
+							"term": O{
+								"tenant_id.keyword": "t1",
+							},
+						},
+					},
+				},
+			},
+			"sort": []O{
+				{"_score": "desc"},
+				{"tag_id": "asc"},
+			},
+		}
+		if size > 0 {
+			query["size"] = size
+		}
+		if len(searchAfter) > 0 {
+			query["search_after"] = searchAfter
+		}
+		return query
+	}
+
+	cases := []struct {
+		name        string
+		size        int
+		searchAfter []interface{}
+	}{
+		{"first page", 10, nil},
+		{"next page", 10, []interface{}{1.5, 42}},
+		{"default size", 0, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := &ESSearchRequest{
+				Query: Bool([]ESQueryBuilder{MatchPhrasePrefix("name", "go")}, nil, nil).Filter(Term("tenant_id.keyword", "t1")),
+				Sort: []ESSort{
+					{"_score": "desc"},
+					{"tag_id": "asc"},
+				},
+				Size:        tc.size,
+				SearchAfter: tc.searchAfter,
+			}
+
+			// Build 的输出需要是合法的 JSON
+			data, err := ioutil.ReadAll(request.Build())
+			if err != nil {
+				t.Fatalf("read body: %s", err)
+			}
+			var got interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshal %s: %s", data, err)
+			}
+
+			want := normalizeBoolClauses(decodeJSON(t, legacySearch(tc.size, tc.searchAfter)))
+			if got = normalizeBoolClauses(got); !reflect.DeepEqual(got, want) {
+				t.Errorf("request = %s, want %#v", data, want)
+			}
+		})
+	}
+}
+
+func TestESQueryBuilderFilterDoesNotShareClauses(t *testing.T) {
+	base := Bool([]ESQueryBuilder{MatchPhrasePrefix("name", "go")}, nil, nil).Filter(Term("a", 1))
+	first := base.Filter(Term("b", 2))
+	second := base.Filter(Term("c", 3))
+
+	want := O{
+		"bool": O{
+			"must":   []O{{"match_phrase_prefix": O{"name": "go"}}},
+			"filter": []O{{"term": O{"a": 1}}, {"term": O{"b": 2}}},
+		},
+	}
+	if got := decodeJSON(t, first); !reflect.DeepEqual(got, decodeJSON(t, want)) {
+		t.Errorf("first = %#v, want %#v", got, decodeJSON(t, want))
+	}
+	if filters := decodeJSON(t, second).(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{}); len(filters) != 2 {
+		t.Errorf("second has %d filters, want 2", len(filters))
+	}
+}
+
+func TestESQueryBuilderFilterPanicsOnNonBool(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Filter on term query did not panic")
+		}
+	}()
+	Term("a", 1).Filter(Term("b", 2))
+}
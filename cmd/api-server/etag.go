@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tagETag 返回标签详情的 ETag。只包含标签字段时为版本号，可以原样放在 If-Match 请求头中；
+// 包含同义词或已删除的标签时，响应的内容不只由版本号决定，返回包含这些内容摘要的弱 ETag，只能用于 If-None-Match
+func tagETag(tag *Tag) string {
+	if tag.Synonyms == nil && tag.DeletedAt == nil {
+		return strconv.Quote(strconv.Itoa(tag.Version))
+	}
+
+	h := fnv.New64a()
+	if tag.DeletedAt != nil {
+		h.Write([]byte(tag.DeletedAt.UTC().Format(time.RFC3339Nano)))
+	}
+	for _, synonym := range tag.Synonyms {
+		h.Write([]byte{0})
+		h.Write([]byte(synonym))
+	}
+	return fmt.Sprintf(`W/"%d-%x"`, tag.Version, h.Sum64())
+}
+
+// etagMatches 按弱比较判断 If-None-Match 请求头中是否有与 etag 相同的值，* 匹配任意 etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondNotModified 设置 ETag 响应头，If-None-Match 与 etag 匹配时返回 304 并返回 true，调用方不需要再写入响应体
+func respondNotModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	if !etagMatches(c.GetHeader("If-None-Match"), etag) {
+		return false
+	}
+
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// respondOKWithETag 返回成功响应，ETag 为响应体摘要的弱 ETag，If-None-Match 匹配时返回 304。
+// 用于没有版本号的查询结果，仍然需要查询数据库，只节省传输的数据
+func respondOKWithETag(c *gin.Context, data interface{}) {
+	body, err := json.Marshal(APIResponse{Data: data})
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	h := fnv.New64a()
+	h.Write(body)
+	if respondNotModified(c, fmt.Sprintf(`W/"%x"`, h.Sum64())) {
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlSchemaSDL GraphQL 接口的 schema，查询和修改复用 HTTP 接口使用的函数，校验规则和错误信息与 HTTP 接口一致
+const graphqlSchemaSDL = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+scalar Time
+
+type Query {
+	# 查询当前租户未删除的标签，不存在时为 null
+	tag(id: ID!): Tag
+	# 搜索标签，ES 不可用时降级到 MySQL 搜索，limit 默认 10，最大 100
+	searchTags(keyword: String!, limit: Int): SearchTagsPayload!
+	# 查询实体关联的标签，entityType 不传时使用 DEFAULT_ENTITY_TYPE
+	entityTags(entityId: ID!, entityType: String): [LinkedTag!]!
+}
+
+type Mutation {
+	# 创建标签，需要 tag:write 角色，标签已经存在时返回已有的标签
+	createTag(name: String!): CreateTagPayload!
+	# 关联标签到实体，需要 tag:write 角色，参数含义与 POST /api/tag/link_entity 相同
+	linkEntity(input: LinkEntityInput!): LinkEntityPayload!
+}
+
+type Tag {
+	id: ID!
+	name: String!
+	displayName: String!
+	description: String!
+	color: String!
+	category: String!
+	version: Int!
+	createdAt: Time!
+	updatedAt: Time!
+	expiresAt: Time
+	# 只有搜索结果有相关度，其余为 null
+	score: Float
+}
+
+type LinkedTag {
+	tag: Tag!
+	source: String!
+	addedBy: String!
+	weight: Float!
+}
+
+type EntityTagLink {
+	entityType: String!
+	entityId: ID!
+	tag: Tag
+	source: String!
+	addedBy: String!
+	weight: Float!
+	createdAt: Time!
+}
+
+input LinkEntityInput {
+	entityId: ID!
+	entityType: String
+	tagId: ID
+	tagName: String
+	createIfMissing: Boolean
+	source: String
+	addedBy: String
+	overwriteSource: Boolean
+	weight: Float
+}
+
+type SearchTagsPayload {
+	tags: [Tag!]!
+	# 为 true 时 ES 不可用，结果来自 MySQL
+	degraded: Boolean!
+	# 关键字少于 SEARCH_MIN_KEYWORD_LENGTH 个字符时说明没有搜索的原因，否则为空字符串
+	message: String!
+}
+
+type CreateTagPayload {
+	tag: Tag!
+	# 为 true 时标签暂时搜索不到，由 ESOutboxWorker 稍后重新上报
+	esIndexDelayed: Boolean!
+}
+
+type LinkEntityPayload {
+	link: EntityTagLink!
+	created: Boolean!
+	tagCreated: Boolean!
+	esIndexDelayed: Boolean!
+}
+`
+
+// graphqlMaxDepth GraphQL 查询允许的最大嵌套层数
+const graphqlMaxDepth = 8
+
+// graphqlSchema 解析后的 GraphQL schema，schema 与 resolver 不匹配时在启动时 panic
+var graphqlSchema = graphql.MustParseSchema(graphqlSchemaSDL, &graphqlResolver{}, graphql.MaxDepth(graphqlMaxDepth))
+
+// GraphQLReqBody GraphQL 请求体
+type GraphQLReqBody struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// OnGraphQL 执行 GraphQL 查询。响应使用 GraphQL 的 {"data": ..., "errors": [...]} 结构而不是 APIResponse，
+// 查询中的错误同样返回 200，errors 中的 extensions.code 为对应 HTTP 接口的状态码。
+// 查询不需要认证，修改需要在 Authorization 请求头中传入拥有 tag:write 角色的 token
+// @Summary 执行 GraphQL 查询
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body GraphQLReqBody true "请求体"
+// @Success 200 {object} object{data=object,errors=[]object}
+// @Failure 400 {object} APIResponse
+// @Failure 401 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Router /api/graphql [post]
+func OnGraphQL(c *gin.Context) {
+	var reqBody GraphQLReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	// 每个请求使用单独的 tagLoader，缓存不会跨请求和租户
+	ctx := withTagLoader(c.Request.Context())
+	c.JSON(http.StatusOK, graphqlSchema.Exec(ctx, reqBody.Query, reqBody.OperationName, reqBody.Variables))
+}
+
+// graphqlError GraphQL resolver 返回的错误，extensions.code 为对应 HTTP 接口的状态码
+type graphqlError struct {
+	*APIError
+}
+
+// Extensions 实现 graphql-go 的 extensions 接口
+func (e *graphqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// toGraphQLError 把 resolver 中的错误转换为 graphqlError，状态码与 respondServerError 相同
+func toGraphQLError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return &graphqlError{apiErr}
+	}
+
+	log.Printf("GraphQLResolverErr: %s", err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &graphqlError{newAPIError(http.StatusGatewayTimeout, err.Error())}
+	}
+	return &graphqlError{newAPIError(http.StatusInternalServerError, err.Error())}
+}
+
+// parseGraphQLID 把 GraphQL 的 ID 解析为正整数，name 为参数名，用于错误信息
+func parseGraphQLID(id graphql.ID, name string) (int, error) {
+	value, err := strconv.Atoi(string(id))
+	if err != nil || value <= 0 {
+		return 0, &graphqlError{newAPIError(http.StatusBadRequest, "invalid "+name)}
+	}
+	return value, nil
+}
+
+// requireGraphQLRole 检查 context 中的操作人是否拥有 role，未认证时返回 401，没有角色时返回 403，与 RequireRole 一致
+func requireGraphQLRole(ctx context.Context, role string) error {
+	if ActorIDFromContext(ctx) == "" {
+		return &graphqlError{newAPIError(http.StatusUnauthorized, "missing token")}
+	}
+	if !HasRole(ctx, role) {
+		return &graphqlError{newAPIError(http.StatusForbidden, "insufficient role")}
+	}
+	return nil
+}
+
+// graphqlResolver GraphQL 的 Query 和 Mutation
+type graphqlResolver struct{}
+
+// Tag 查询标签，通过 tagLoader 查询，同一个请求中的多个 tag 字段合并为一次查询
+func (r *graphqlResolver) Tag(ctx context.Context, args struct{ ID graphql.ID }) (*tagResolver, error) {
+	tagID, err := parseGraphQLID(args.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := tagLoaderFromContext(ctx).Load(tagID)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	if tag == nil {
+		return nil, nil
+	}
+	return &tagResolver{tag}, nil
+}
+
+// SearchTags 搜索标签，与 GET /api/tag/search 使用相同的校验和降级逻辑，不支持翻页
+func (r *graphqlResolver) SearchTags(ctx context.Context, args struct {
+	Keyword string
+	Limit   *int32
+}) (*searchTagsPayloadResolver, error) {
+	keyword, err := validateSearchKeyword(args.Keyword)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	keyword = NormalizeTagName(keyword)
+	if message, tooShort := searchKeywordTooShort(keyword); tooShort {
+		return &searchTagsPayloadResolver{tags: []*tagResolver{}, message: message}, nil
+	}
+
+	limit := 10
+	if args.Limit != nil {
+		if *args.Limit <= 0 || *args.Limit > 100 {
+			return nil, &graphqlError{newAPIError(http.StatusBadRequest, "invalid limit")}
+		}
+		limit = int(*args.Limit)
+	}
+
+	result, degraded, err := SearchTags(ctx, keyword, SearchTagsOptions{Size: limit})
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+
+	resolvers := make([]*tagResolver, 0, len(result.Tags))
+	for _, tag := range result.Tags {
+		resolvers = append(resolvers, &tagResolver{tag})
+	}
+	return &searchTagsPayloadResolver{tags: resolvers, degraded: degraded}, nil
+}
+
+// EntityTags 查询实体关联的标签，标签和关联使用 GetEntityTags 的两次查询一起返回
+func (r *graphqlResolver) EntityTags(ctx context.Context, args struct {
+	EntityID   graphql.ID
+	EntityType *string
+}) ([]*linkedTagResolver, error) {
+	entityID, err := parseGraphQLID(args.EntityID, "entityId")
+	if err != nil {
+		return nil, err
+	}
+
+	entityType := ""
+	if args.EntityType != nil {
+		entityType = *args.EntityType
+	}
+	if entityType, err = validateEntityType(entityType); err != nil {
+		return nil, toGraphQLError(err)
+	}
+
+	tags, err := GetEntityTags(ctx, entityType, entityID, EntityTagsOptions{})
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+
+	loader := tagLoaderFromContext(ctx)
+	resolvers := make([]*linkedTagResolver, 0, len(tags))
+	for _, tag := range tags {
+		loader.Prime(tag.Tag)
+		resolvers = append(resolvers, &linkedTagResolver{tag})
+	}
+	return resolvers, nil
+}
+
+// CreateTag 创建标签，行为与 POST /api/tag 相同
+func (r *graphqlResolver) CreateTag(ctx context.Context, args struct{ Name string }) (*createTagPayloadResolver, error) {
+	if err := requireGraphQLRole(ctx, RoleTagWrite); err != nil {
+		return nil, err
+	}
+
+	tag, esIndexDelayed, err := CreateTag(ctx, args.Name, nil)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	tagLoaderFromContext(ctx).Prime(tag)
+	return &createTagPayloadResolver{tag: tag, esIndexDelayed: esIndexDelayed}, nil
+}
+
+// linkEntityInput linkEntity 的参数
+type linkEntityInput struct {
+	EntityID        graphql.ID
+	EntityType      *string
+	TagID           *graphql.ID
+	TagName         *string
+	CreateIfMissing *bool
+	Source          *string
+	AddedBy         *string
+	OverwriteSource *bool
+	Weight          *float64
+}
+
+// LinkEntity 关联标签到实体，行为与 POST /api/tag/link_entity 相同，不支持关联的附加信息
+func (r *graphqlResolver) LinkEntity(ctx context.Context, args struct{ Input linkEntityInput }) (*linkEntityPayloadResolver, error) {
+	if err := requireGraphQLRole(ctx, RoleTagWrite); err != nil {
+		return nil, err
+	}
+
+	input := args.Input
+	entityID, err := parseGraphQLID(input.EntityID, "entityId")
+	if err != nil {
+		return nil, err
+	}
+	reqBody := LinkEntityReqBody{EntityID: entityID, Weight: input.Weight}
+	if input.TagID != nil {
+		if reqBody.TagID, err = parseGraphQLID(*input.TagID, "tagId"); err != nil {
+			return nil, err
+		}
+	}
+	if input.EntityType != nil {
+		reqBody.EntityType = *input.EntityType
+	}
+	if input.TagName != nil {
+		reqBody.TagName = *input.TagName
+	}
+	if input.CreateIfMissing != nil {
+		reqBody.CreateIfMissing = *input.CreateIfMissing
+	}
+	if input.Source != nil {
+		reqBody.Source = *input.Source
+	}
+	if input.AddedBy != nil {
+		reqBody.AddedBy = *input.AddedBy
+	}
+	if input.OverwriteSource != nil {
+		reqBody.OverwriteSource = *input.OverwriteSource
+	}
+
+	result, err := LinkEntity(ctx, &reqBody)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	return &linkEntityPayloadResolver{result}, nil
+}
+
+// tagResolver GraphQL 的 Tag 类型
+type tagResolver struct {
+	tag *Tag
+}
+
+func (r *tagResolver) ID() graphql.ID          { return graphql.ID(strconv.Itoa(r.tag.TagID)) }
+func (r *tagResolver) Name() string            { return r.tag.Name }
+func (r *tagResolver) DisplayName() string     { return r.tag.DisplayName }
+func (r *tagResolver) Description() string     { return r.tag.Description }
+func (r *tagResolver) Color() string           { return r.tag.Color }
+func (r *tagResolver) Category() string        { return r.tag.Category }
+func (r *tagResolver) Version() int32          { return int32(r.tag.Version) }
+func (r *tagResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.tag.CreatedAt} }
+func (r *tagResolver) UpdatedAt() graphql.Time { return graphql.Time{Time: r.tag.UpdatedAt} }
+
+func (r *tagResolver) ExpiresAt() *graphql.Time {
+	if r.tag.ExpiresAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *r.tag.ExpiresAt}
+}
+
+func (r *tagResolver) Score() *float64 {
+	if r.tag.Score == 0 {
+		return nil
+	}
+	return &r.tag.Score
+}
+
+// linkedTagResolver GraphQL 的 LinkedTag 类型
+type linkedTagResolver struct {
+	linked *LinkedTag
+}
+
+func (r *linkedTagResolver) Tag() *tagResolver { return &tagResolver{r.linked.Tag} }
+func (r *linkedTagResolver) Source() string    { return r.linked.Source }
+func (r *linkedTagResolver) AddedBy() string   { return r.linked.AddedBy }
+func (r *linkedTagResolver) Weight() float64   { return r.linked.Weight }
+
+// entityTagLinkResolver GraphQL 的 EntityTagLink 类型
+type entityTagLinkResolver struct {
+	link *EntityTag
+}
+
+func (r *entityTagLinkResolver) EntityType() string { return r.link.EntityType }
+func (r *entityTagLinkResolver) EntityID() graphql.ID {
+	return graphql.ID(strconv.Itoa(r.link.EntityID))
+}
+func (r *entityTagLinkResolver) Source() string          { return r.link.Source }
+func (r *entityTagLinkResolver) AddedBy() string         { return r.link.AddedBy }
+func (r *entityTagLinkResolver) Weight() float64         { return r.link.Weight }
+func (r *entityTagLinkResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.link.CreatedAt} }
+
+// Tag 关联的标签通过 tagLoader 查询，标签在关联之后被删除时为 null
+func (r *entityTagLinkResolver) Tag(ctx context.Context) (*tagResolver, error) {
+	tag, err := tagLoaderFromContext(ctx).Load(r.link.TagID)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	if tag == nil {
+		return nil, nil
+	}
+	return &tagResolver{tag}, nil
+}
+
+// searchTagsPayloadResolver GraphQL 的 SearchTagsPayload 类型
+type searchTagsPayloadResolver struct {
+	tags     []*tagResolver
+	degraded bool
+	message  string
+}
+
+func (r *searchTagsPayloadResolver) Tags() []*tagResolver { return r.tags }
+func (r *searchTagsPayloadResolver) Degraded() bool       { return r.degraded }
+func (r *searchTagsPayloadResolver) Message() string      { return r.message }
+
+// createTagPayloadResolver GraphQL 的 CreateTagPayload 类型
+type createTagPayloadResolver struct {
+	tag            *Tag
+	esIndexDelayed bool
+}
+
+func (r *createTagPayloadResolver) Tag() *tagResolver    { return &tagResolver{r.tag} }
+func (r *createTagPayloadResolver) EsIndexDelayed() bool { return r.esIndexDelayed }
+
+// linkEntityPayloadResolver GraphQL 的 LinkEntityPayload 类型
+type linkEntityPayloadResolver struct {
+	result *LinkEntityOutcome
+}
+
+func (r *linkEntityPayloadResolver) Link() *entityTagLinkResolver {
+	return &entityTagLinkResolver{r.result.Link}
+}
+func (r *linkEntityPayloadResolver) Created() bool        { return r.result.Created }
+func (r *linkEntityPayloadResolver) TagCreated() bool     { return r.result.TagCreated }
+func (r *linkEntityPayloadResolver) EsIndexDelayed() bool { return r.result.ESIndexDelayed }
+
+// tagLoaderWait tagLoader 收集同一批标签 ID 的等待时间。graphql-go 并发执行同一层的查询字段，
+// 等待期间各个字段的 Load 合并为一次 GetTagsByIDs 查询
+const tagLoaderWait = 2 * time.Millisecond
+
+// tagLoader 合并同一个请求中的标签查询，避免每个 tag 字段单独查询 MySQL。
+// 查询过的标签缓存到请求结束，不存在的标签缓存为 nil
+type tagLoader struct {
+	ctx   context.Context
+	mu    sync.Mutex
+	cache map[int]*Tag
+	batch *tagLoaderBatch
+}
+
+// tagLoaderBatch 等待中的一批查询，查询完成后关闭 done
+type tagLoaderBatch struct {
+	tagIDs []int
+	once   sync.Once
+	done   chan struct{}
+	err    error
+}
+
+// tagLoaderContextKey 请求的 context 中保存 tagLoader 的键
+type tagLoaderContextKey struct{}
+
+// withTagLoader 返回带有新的 tagLoader 的 context，tagLoader 使用 ctx 中的租户查询
+func withTagLoader(ctx context.Context) context.Context {
+	loader := &tagLoader{ctx: ctx, cache: make(map[int]*Tag)}
+	return context.WithValue(ctx, tagLoaderContextKey{}, loader)
+}
+
+// tagLoaderFromContext 读取 withTagLoader 写入的 tagLoader
+func tagLoaderFromContext(ctx context.Context) *tagLoader {
+	return ctx.Value(tagLoaderContextKey{}).(*tagLoader)
+}
+
+// Prime 把已经查询到的标签放入缓存，后续 Load 不再查询
+func (l *tagLoader) Prime(tag *Tag) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache[tag.TagID] = tag
+}
+
+// Load 查询未删除的标签，标签不存在或已删除时返回 nil。
+// 等待 tagLoaderWait 或者凑满 maxBatchGetTags 个 ID 后一起查询
+func (l *tagLoader) Load(tagID int) (*Tag, error) {
+	l.mu.Lock()
+	if tag, ok := l.cache[tagID]; ok {
+		l.mu.Unlock()
+		return tag, nil
+	}
+
+	batch := l.batch
+	if batch == nil {
+		batch = &tagLoaderBatch{done: make(chan struct{})}
+		l.batch = batch
+		time.AfterFunc(tagLoaderWait, func() { l.fetch(batch) })
+	}
+	batch.tagIDs = append(batch.tagIDs, tagID)
+	if len(batch.tagIDs) >= maxBatchGetTags {
+		l.batch = nil
+		go l.fetch(batch)
+	}
+	l.mu.Unlock()
+
+	<-batch.done
+	if batch.err != nil {
+		return nil, batch.err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cache[tagID], nil
+}
+
+// fetch 查询一批标签并写入缓存，同一批只查询一次
+func (l *tagLoader) fetch(batch *tagLoaderBatch) {
+	batch.once.Do(func() {
+		l.mu.Lock()
+		if l.batch == batch {
+			l.batch = nil
+		}
+		tagIDs := batch.tagIDs
+		l.mu.Unlock()
+
+		tags, _, err := GetTagsByIDs(l.ctx, tagIDs)
+		l.mu.Lock()
+		if err == nil {
+			for index, tagID := range tagIDs {
+				l.cache[tagID] = tags[index]
+			}
+		}
+		l.mu.Unlock()
+
+		batch.err = err
+		close(batch.done)
+	})
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/3vilive/tag-server/tagpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// gRPC 请求中传入租户 ID 和 token 的 metadata 键，gRPC 要求小写
+const (
+	grpcTenantIDKey      = "x-tenant-id"
+	grpcAuthorizationKey = "authorization"
+)
+
+// grpcMethodRoles 需要角色的 RPC 及其角色，与 HTTP 接口的 RequireRole 一致，未列出的 RPC 不需要认证
+var grpcMethodRoles = map[string]string{
+	"/tag.v1.TagService/CreateTag":  RoleTagWrite,
+	"/tag.v1.TagService/LinkEntity": RoleTagWrite,
+}
+
+// grpcStatusCodes APIError 的 HTTP 状态码对应的 gRPC 状态码，未列出的状态码按 Internal 处理
+var grpcStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.Aborted,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusUnprocessableEntity:   codes.FailedPrecondition,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// toGRPCError 把内部错误转换为 gRPC status，APIError 按 grpcStatusCodes 转换状态码并保留错误信息，
+// 调用 MySQL 或 ES 超时时为 DeadlineExceeded，客户端取消时为 Canceled，其余为 Internal
+func toGRPCError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		code, ok := grpcStatusCodes[apiErr.Code]
+		if !ok {
+			code = codes.Internal
+		}
+		return status.Error(code, apiErr.Message)
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	log.Printf("GRPCErr: %s", err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+// metadataValue 读取 metadata 中 key 的第一个值，没有时返回空字符串
+func metadataValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// GRPCAuthInterceptor 与 HTTP 接口的 TenantMiddleware 和 JWTMiddleware 相同，校验 x-tenant-id，
+// 需要角色的 RPC 校验 authorization 中的 Bearer token 及其 tenant_id，并把租户、操作人和角色写入 context
+func GRPCAuthInterceptor(secret string) grpc.UnaryServerInterceptor {
+	parseToken := newTokenParser(secret)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		tenantID := metadataValue(md, grpcTenantIDKey)
+		if !tenantIDPattern.MatchString(tenantID) {
+			return nil, status.Error(codes.InvalidArgument, "invalid "+grpcTenantIDKey)
+		}
+		ctx = WithTenantID(ctx, tenantID)
+
+		if role, ok := grpcMethodRoles[info.FullMethod]; ok {
+			claims, err := parseToken(metadataValue(md, grpcAuthorizationKey))
+			if err != nil {
+				return nil, toGRPCError(err)
+			}
+			if err := checkTokenTenant(ctx, claims); err != nil {
+				return nil, toGRPCError(err)
+			}
+			if !hasAnyRole(claims.Roles, role) {
+				return nil, status.Error(codes.PermissionDenied, "insufficient role")
+			}
+			ctx = WithAuthClaims(ctx, claims)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// tagGRPCServer 实现 tagpb.TagServiceServer，复用 HTTP 接口的校验和数据访问函数
+type tagGRPCServer struct {
+	tagpb.UnimplementedTagServiceServer
+}
+
+// CreateTag 创建标签，行为与 POST /api/tag 相同
+func (s *tagGRPCServer) CreateTag(ctx context.Context, req *tagpb.CreateTagRequest) (*tagpb.CreateTagResponse, error) {
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t := req.ExpiresAt.AsTime()
+		expiresAt = &t
+	}
+
+	tag, esIndexDelayed, err := CreateTag(ctx, req.Name, expiresAt)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	return &tagpb.CreateTagResponse{Tag: tagToProto(tag), EsIndexDelayed: esIndexDelayed}, nil
+}
+
+// SearchTags 搜索标签，行为与 GET /api/tag/search 相同，不支持 min_score 和 highlight
+func (s *tagGRPCServer) SearchTags(ctx context.Context, req *tagpb.SearchTagsRequest) (*tagpb.SearchTagsResponse, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = SearchModeOr
+	}
+	keyword, err := validateSearchTagsParams(req.Keyword, req.Match, mode)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+	if message, tooShort := searchKeywordTooShort(keyword); tooShort {
+		return &tagpb.SearchTagsResponse{Tags: []*tagpb.Tag{}, Message: message}, nil
+	}
+
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = 10
+	}
+	if limit < 0 || limit > 100 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit")
+	}
+
+	opts := SearchTagsOptions{Size: limit, Match: req.Match, Mode: mode}
+	for _, tagID := range req.TagIds {
+		if tagID <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid tag_ids")
+		}
+		opts.TagIDs = append(opts.TagIDs, int(tagID))
+	}
+	if req.Cursor != "" {
+		if opts.SearchAfter, err = DecodeSearchCursor(req.Cursor); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+		}
+	}
+
+	result, degraded, err := SearchTags(ctx, keyword, opts)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+
+	resp := &tagpb.SearchTagsResponse{Tags: make([]*tagpb.Tag, 0, len(result.Tags)), Degraded: degraded}
+	for _, tag := range result.Tags {
+		resp.Tags = append(resp.Tags, tagToProto(tag))
+	}
+	resp.NextCursor = searchNextCursor(result, limit)
+	return resp, nil
+}
+
+// LinkEntity 关联标签到实体，行为与 POST /api/tag/link_entity 相同，不支持关联的附加信息
+func (s *tagGRPCServer) LinkEntity(ctx context.Context, req *tagpb.LinkEntityRequest) (*tagpb.LinkEntityResponse, error) {
+	result, err := LinkEntity(ctx, &LinkEntityReqBody{
+		EntityType:      req.EntityType,
+		EntityID:        int(req.EntityId),
+		TagID:           int(req.TagId),
+		TagName:         req.TagName,
+		CreateIfMissing: req.CreateIfMissing,
+		Source:          req.Source,
+		AddedBy:         req.AddedBy,
+		OverwriteSource: req.OverwriteSource,
+		Weight:          req.Weight,
+	})
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+
+	link := result.Link
+	return &tagpb.LinkEntityResponse{
+		Link: &tagpb.EntityTagLink{
+			LinkId:     int64(link.LinkID),
+			EntityType: link.EntityType,
+			EntityId:   int64(link.EntityID),
+			TagId:      int64(link.TagID),
+			Source:     link.Source,
+			AddedBy:    link.AddedBy,
+			Weight:     link.Weight,
+			CreatedAt:  timestamppb.New(link.CreatedAt),
+		},
+		Created:        result.Created,
+		TagCreated:     result.TagCreated,
+		EsIndexDelayed: result.ESIndexDelayed,
+	}, nil
+}
+
+// GetEntityTags 查询实体关联的标签，行为与 GET /api/tag/entity_tags 相同
+func (s *tagGRPCServer) GetEntityTags(ctx context.Context, req *tagpb.GetEntityTagsRequest) (*tagpb.GetEntityTagsResponse, error) {
+	if req.EntityId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid entity_id")
+	}
+
+	entityType, err := validateEntityType(req.EntityType)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+
+	opts := EntityTagsOptions{Source: req.Source, Order: req.Order}
+	if err := validateEntityTagsOptions(opts); err != nil {
+		return nil, toGRPCError(err)
+	}
+
+	tags, err := GetEntityTags(ctx, entityType, int(req.EntityId), opts)
+	if err != nil {
+		return nil, toGRPCError(err)
+	}
+
+	resp := &tagpb.GetEntityTagsResponse{
+		EntityType: entityType,
+		EntityId:   req.EntityId,
+		Tags:       make([]*tagpb.LinkedTag, 0, len(tags)),
+	}
+	for _, tag := range tags {
+		resp.Tags = append(resp.Tags, &tagpb.LinkedTag{
+			Tag:     tagToProto(tag.Tag),
+			Source:  tag.Source,
+			AddedBy: tag.AddedBy,
+			Weight:  tag.Weight,
+		})
+	}
+	return resp, nil
+}
+
+// tagToProto 把 Tag 转换为 tagpb.Tag
+func tagToProto(tag *Tag) *tagpb.Tag {
+	pb := &tagpb.Tag{
+		TagId:       int64(tag.TagID),
+		Name:        tag.Name,
+		DisplayName: tag.DisplayName,
+		Description: tag.Description,
+		Color:       tag.Color,
+		Category:    tag.Category,
+		Version:     int64(tag.Version),
+		CreatedAt:   timestamppb.New(tag.CreatedAt),
+		UpdatedAt:   timestamppb.New(tag.UpdatedAt),
+		Score:       tag.Score,
+	}
+	if tag.ExpiresAt != nil {
+		pb.ExpiresAt = timestamppb.New(*tag.ExpiresAt)
+	}
+	return pb
+}
+
+// NewGRPCServer 返回注册了 TagService 的 gRPC 服务
+func NewGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.UnaryInterceptor(GRPCAuthInterceptor(config.JWTSecret)))
+	tagpb.RegisterTagServiceServer(srv, &tagGRPCServer{})
+	return srv
+}
+
+// StartGRPCServer 在 addr 上启动 gRPC 服务，与 HTTP 接口使用不同的端口。
+// addr 为空时不启动并返回 nil，监听失败时退出进程
+func StartGRPCServer(addr string) *grpc.Server {
+	if addr == "" {
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("GRPCListenErr: %s", err)
+	}
+
+	srv := NewGRPCServer()
+	go func() {
+		log.Printf("GRPCListen: %s", addr)
+		if err := srv.Serve(lis); err != nil {
+			log.Printf("GRPCServeErr: %s", err)
+		}
+	}()
+	return srv
+}
+
+// StopGRPCServer 停止接收新请求并等待进行中的请求完成，ctx 结束时强制关闭剩余的连接
+func StopGRPCServer(ctx context.Context, srv *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		srv.Stop()
+		return ctx.Err()
+	}
+}
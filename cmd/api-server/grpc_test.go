@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCAuthInterceptorTenant(t *testing.T) {
+	interceptor := GRPCAuthInterceptor(testJWTSecret)
+	info := &grpc.UnaryServerInfo{FullMethod: "/tag.v1.TagService/CreateTag"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return TenantIDFromContext(ctx), nil
+	}
+
+	cases := []struct {
+		name     string
+		tenantID string
+		want     codes.Code
+	}{
+		{"same tenant", "t1", codes.OK},
+		{"other tenant", "t2", codes.PermissionDenied},
+		{"missing tenant", "", codes.PermissionDenied},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			md := metadata.Pairs(
+				grpcTenantIDKey, "t1",
+				grpcAuthorizationKey, "Bearer "+signTestToken(t, testJWTSecret, tc.tenantID, RoleTagWrite),
+			)
+			resp, err := interceptor(metadata.NewIncomingContext(context.Background(), md), nil, info, handler)
+			if code := status.Code(err); code != tc.want {
+				t.Fatalf("code = %s, want %s, err: %v", code, tc.want, err)
+			}
+			if tc.want == codes.OK && resp != "t1" {
+				t.Errorf("tenant = %v, want t1", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
+	"github.com/gin-gonic/gin"
+)
+
+// esMaxRetryInterval 等待 ES 可用时两次检查之间的最长间隔
+const esMaxRetryInterval = 30 * time.Second
+
+// esReady 第一次成功调用 ES 的 Info 接口后置为 1
+var esReady int32
+
+// IsESReady 判断 ES 是否已经可用，启动后 ES 可用之前搜索直接降级到 MySQL
+func IsESReady() bool {
+	return atomic.LoadInt32(&esReady) == 1
+}
+
+// WaitForES 在后台反复调用 ES 的 Info 接口直到成功，间隔从 1 秒开始翻倍，最长 esMaxRetryInterval。
+// ES 不可用时服务仍然可以启动，只依赖 MySQL 的接口不受影响
+func WaitForES(es *elasticsearch7.Client) {
+	interval := time.Second
+	for {
+		resp, err := es.Info()
+		if err == nil {
+			resp.Body.Close()
+			if !resp.IsError() {
+				atomic.StoreInt32(&esReady, 1)
+				log.Printf("ESReady: %s", resp.Status())
+				return
+			}
+			log.Printf("[WARN] ESInfoErr: %s", resp.Status())
+		} else {
+			log.Printf("[WARN] ESInfoErr: %s", err)
+		}
+
+		time.Sleep(interval)
+		if interval *= 2; interval > esMaxRetryInterval {
+			interval = esMaxRetryInterval
+		}
+	}
+}
+
+// OnReadyz 就绪检查，MySQL 无法连接或 ES 还没有可用过时返回 503，负载均衡据此决定是否转发流量
+// @Summary 就绪检查
+// @Tags health
+// @Produce json
+// @Success 200 {object} APIResponse{data=object{mysql=string,elasticsearch=string}}
+// @Failure 503 {object} APIResponse
+// @Router /readyz [get]
+func OnReadyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), config.QueryTimeout)
+	defer cancel()
+
+	if err := mysqlDB.PingContext(ctx); err != nil {
+		log.Printf("ReadyzMySQLErr: %s", err)
+		respondError(c, http.StatusServiceUnavailable, "mysql not ready")
+		return
+	}
+
+	if !IsESReady() {
+		respondError(c, http.StatusServiceUnavailable, "elasticsearch not ready")
+		return
+	}
+
+	respondOK(c, gin.H{
+		"mysql":         "ok",
+		"elasticsearch": "ok",
+	})
+}
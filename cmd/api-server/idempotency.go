@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader 客户端传入幂等键的请求头
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyReplayedHeader 命中幂等记录时返回的响应头
+	IdempotencyReplayedHeader = "Idempotency-Replayed"
+
+	// maxIdempotencyKeyLength 幂等键的最大长度，与 request_idempotency_tbl.key 字段的长度一致
+	maxIdempotencyKeyLength = 64
+)
+
+// idempotencyRecord 幂等记录，StatusCode 为 0 时表示第一次请求还在处理中
+type idempotencyRecord struct {
+	RequestHash  string `db:"request_hash"`
+	ResponseBody []byte `db:"response_body"`
+	StatusCode   int    `db:"status_code"`
+}
+
+// idempotencyScope 幂等记录的作用范围，包括请求方法和路由，同一个键在不同接口上互不影响
+func idempotencyScope(c *gin.Context) string {
+	return c.Request.Method + " " + c.FullPath()
+}
+
+// bodyCaptureWriter 在写出响应的同时记录响应体
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware 根据 Idempotency-Key 请求头缓存响应，记录按租户、JWT 中的操作人、请求方法和路由区分。
+// 有效期内再次收到相同的键时直接返回缓存的响应而不再执行处理函数；请求体与第一次不同时返回 422，
+// 第一次请求还在处理中时返回 409。处理之前先插入记录占用该键，并发的重复请求不会同时执行
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			respondError(c, http.StatusBadRequest, "invalid "+IdempotencyKeyHeader)
+			return
+		}
+
+		// 读取请求体计算摘要后放回，处理函数仍然可以正常绑定
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, bindErrorStatus(err), err.Error())
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+
+		ctx := c.Request.Context()
+		recordKey := []interface{}{TenantIDFromContext(ctx), ActorIDFromContext(ctx), idempotencyScope(c), key}
+		expiredBefore := time.Now().Add(-config.IdempotencyTTL)
+
+		// 没有记录时插入占位记录；已有记录过期但还没有被清理时覆盖为新的占位记录，created_at 需要最后更新，
+		// 前面的判断使用原来的 created_at。未过期的记录不会被修改，影响行数为 0
+		reserveArgs := append(append([]interface{}{}, recordKey...), requestHash, expiredBefore, expiredBefore, expiredBefore, expiredBefore)
+		execResult, execErr := dbExec(
+			ctx,
+			"insert into request_idempotency_tbl (tenant_id, actor_id, scope, `key`, request_hash, response_body, status_code) values (?, ?, ?, ?, ?, '', 0) "+
+				"on duplicate key update request_hash = if(created_at <= ?, values(request_hash), request_hash), "+
+				"response_body = if(created_at <= ?, '', response_body), status_code = if(created_at <= ?, 0, status_code), "+
+				"created_at = if(created_at <= ?, now(), created_at)",
+			reserveArgs...,
+		)
+		if execErr != nil {
+			respondServerError(c, execErr)
+			return
+		}
+		reserved, err := execResult.RowsAffected()
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+
+		if reserved == 0 {
+			var record idempotencyRecord
+			queryErr := dbGet(
+				ctx, &record,
+				"select request_hash, response_body, status_code from request_idempotency_tbl where tenant_id = ? and actor_id = ? and scope = ? and `key` = ?",
+				recordKey...,
+			)
+			if queryErr == sql.ErrNoRows {
+				// 第一次请求刚刚失败并删除了占位记录，与处理中一样让客户端稍后重试
+				respondError(c, http.StatusConflict, "request with this "+IdempotencyKeyHeader+" is in progress")
+				return
+			}
+			if queryErr != nil {
+				respondServerError(c, queryErr)
+				return
+			}
+			if record.RequestHash != requestHash {
+				respondError(c, http.StatusUnprocessableEntity, IdempotencyKeyHeader+" reused with a different request body")
+				return
+			}
+			if record.StatusCode == 0 {
+				respondError(c, http.StatusConflict, "request with this "+IdempotencyKeyHeader+" is in progress")
+				return
+			}
+
+			c.Header(IdempotencyReplayedHeader, "true")
+			c.Data(record.StatusCode, "application/json; charset=utf-8", record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		// 处理函数 panic 或返回服务端错误时删除占位记录，允许客户端使用相同的键重试
+		saved := false
+		defer func() {
+			if saved {
+				return
+			}
+			_, execErr := dbExec(
+				context.Background(),
+				"delete from request_idempotency_tbl where tenant_id = ? and actor_id = ? and scope = ? and `key` = ? and status_code = 0",
+				recordKey...,
+			)
+			if execErr != nil {
+				log.Printf("ReleaseIdempotencyRecordErr: %s", execErr)
+			}
+		}()
+
+		writer := &bodyCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		saveArgs := append([]interface{}{writer.body.Bytes(), writer.Status()}, recordKey...)
+		_, execErr = dbExec(
+			context.Background(),
+			"update request_idempotency_tbl set response_body = ?, status_code = ? where tenant_id = ? and actor_id = ? and scope = ? and `key` = ?",
+			saveArgs...,
+		)
+		if execErr != nil {
+			log.Printf("SaveIdempotencyRecordErr: %s", execErr)
+			return
+		}
+		saved = true
+	}
+}
+
+// StartIdempotencyPurger 定期清理过期的幂等记录
+func StartIdempotencyPurger(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			result, execErr := dbExec(
+				context.Background(),
+				"delete from request_idempotency_tbl where created_at <= ?",
+				time.Now().Add(-config.IdempotencyTTL),
+			)
+			if execErr != nil {
+				log.Printf("PurgeIdempotencyRecordsErr: %s", execErr)
+				continue
+			}
+
+			if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+				log.Printf("PurgeIdempotencyRecordsOk: %d", affected)
+			}
+		}
+	}()
+}
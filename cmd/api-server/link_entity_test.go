@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// setupMockDB 使用 sqlmock 替换全局的 MySQL 连接和配置，测试结束后恢复
+func setupMockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %s", err)
+	}
+
+	prevDB, prevConfig := mysqlDB, config
+	mysqlDB = sqlx.NewDb(db, "mysql")
+	config = &Config{QueryTimeout: time.Second, DefaultEntityType: "article"}
+	t.Cleanup(func() {
+		db.Close()
+		mysqlDB, config = prevDB, prevConfig
+	})
+	return mock
+}
+
+func TestLinkEntityDuplicateKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := setupMockDB(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	linkColumns := strings.Split(entityTagColumns, ", ")
+
+	// 预先查询时关联还不存在
+	mock.ExpectQuery(regexp.QuoteMeta("select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and tag_id = ? and deleted_at is null")).
+		WithArgs("t1", "article", 100, 7).
+		WillReturnRows(sqlmock.NewRows(linkColumns))
+	mock.ExpectQuery(regexp.QuoteMeta("select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ?")).
+		WithArgs("t1", 7).
+		WillReturnRows(sqlmock.NewRows(strings.Split(tagColumns, ", ")).
+			AddRow(7, "t1", "go", "Go", "", "", "", 1, now, now, nil, nil))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("insert into entity_lock_tbl")).
+		WithArgs("t1", "article", 100).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("select coalesce(max(position), 0) + 1 from entity_tag_tbl")).
+		WithArgs("t1", "article", 100).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(3))
+	mock.ExpectExec(regexp.QuoteMeta("update entity_tag_tbl set position = case")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// 并发请求在查询之后创建了关联，insert 没有影响任何行
+	mock.ExpectExec(regexp.QuoteMeta("insert into entity_tag_tbl")).
+		WithArgs("t1", "article", 100, 7, nil, 3, LinkSourceAPI, "", defaultLinkWeight).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and tag_id = ?")).
+		WithArgs("t1", "article", 100, 7).
+		WillReturnRows(sqlmock.NewRows(linkColumns).
+			AddRow(42, "t1", "article", 100, 7, nil, LinkSourceAPI, "", defaultLinkWeight, now, now, nil))
+	mock.ExpectCommit()
+
+	r := gin.New()
+	r.POST("/api/tag/link_entity", TenantMiddleware(), OnLinkEntity)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tag/link_entity", strings.NewReader(`{"entity_id": 100, "tag_id": 7}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TenantIDHeader, "t1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var resp struct {
+		Data struct {
+			LinkID  int  `json:"link_id"`
+			Created bool `json:"created"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %s", err)
+	}
+	if resp.Data.Created {
+		t.Errorf("created = true, want false")
+	}
+	if resp.Data.LinkID != 42 {
+		t.Errorf("link_id = %d, want 42", resp.Data.LinkID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %s", err)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxLinkMetadataBytes 关联附加信息序列化为 JSON 后的最大长度
+const maxLinkMetadataBytes = 4096
+
+// linkMetadataKeyPattern 按附加信息过滤时键名只能包含字母、数字和下划线，直接拼接到 JSON 路径中
+var linkMetadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,64}$`)
+
+// 关联的来源，写入 entity_tag_tbl.source。来源不限于这些取值，调用方可以使用其它符合 linkSourcePattern 的值
+const (
+	// LinkSourceAPI 通过接口关联，没有指定来源时的默认值
+	LinkSourceAPI = "api"
+	// LinkSourceManual 由编辑人工关联
+	LinkSourceManual = "manual"
+	// LinkSourceML 由机器学习流程自动关联
+	LinkSourceML = "ml"
+	// LinkSourceImport 由导入任务关联
+	LinkSourceImport = "import"
+)
+
+// defaultLinkWeight 没有指定权重时关联的权重
+const defaultLinkWeight = 1.0
+
+// maxLinkAddedByLength 关联操作人的最大长度，与 entity_tag_tbl.added_by 字段一致
+const maxLinkAddedByLength = 64
+
+// linkSourcePattern 关联来源只能包含小写字母、数字和下划线，长度与 entity_tag_tbl.source 字段一致
+var linkSourcePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// validateLinkSource 校验关联来源，为空时返回 LinkSourceAPI
+func validateLinkSource(source string) (string, error) {
+	if source == "" {
+		return LinkSourceAPI, nil
+	}
+	if !linkSourcePattern.MatchString(source) {
+		return "", newAPIError(http.StatusBadRequest, "invalid source")
+	}
+	return source, nil
+}
+
+// validateLinkWeight 校验关联的权重，取值范围为 [0, 1]，为 nil 时返回默认值 1
+func validateLinkWeight(weight *float64) (float64, error) {
+	if weight == nil {
+		return defaultLinkWeight, nil
+	}
+	if *weight < 0 || *weight > 1 {
+		return 0, newAPIError(http.StatusBadRequest, "weight must be between 0 and 1")
+	}
+	return *weight, nil
+}
+
+// validateLinkAddedBy 校验关联的操作人，为空时使用 JWT 中的操作人
+func validateLinkAddedBy(ctx context.Context, addedBy string) (string, error) {
+	if addedBy == "" {
+		return ActorIDFromContext(ctx), nil
+	}
+	if utf8.RuneCountInString(addedBy) > maxLinkAddedByLength {
+		return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("added_by too long, max %d characters", maxLinkAddedByLength))
+	}
+	return addedBy, nil
+}
+
+// LinkMetadata 实体与标签关联的附加信息，例如打标签的置信度、来源，以 JSON 对象保存在 entity_tag_tbl.metadata 中，
+// 为 nil 时对应 NULL
+type LinkMetadata map[string]interface{}
+
+// Value 实现 driver.Valuer，以字符串传给 MySQL，binary 字符集的参数不能写入 JSON 字段
+func (m LinkMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner，解析 JSON 字段，NULL 时为 nil
+func (m *LinkMetadata) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return fmt.Errorf("unsupported metadata type %T", src)
+	}
+}
+
+// validateLinkMetadata 校验关联附加信息的大小，nil 表示没有附加信息
+func validateLinkMetadata(metadata LinkMetadata) error {
+	if metadata == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "invalid metadata")
+	}
+	if len(data) > maxLinkMetadataBytes {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("metadata too large, max %d bytes", maxLinkMetadataBytes))
+	}
+	return nil
+}
+
+// UpdateLinkMetadata 在事务中替换实体与标签关联的附加信息，metadata 为 nil 时清空，关联不存在时返回 404
+func UpdateLinkMetadata(ctx context.Context, entityType string, entityID, tagID int, metadata LinkMetadata) (*EntityTag, error) {
+	tenantID := TenantIDFromContext(ctx)
+
+	var link EntityTag
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var before EntityTag
+		queryErr := txGet(
+			ctx, tx, &before,
+			"select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and tag_id = ? and deleted_at is null for update",
+			tenantID, entityType, entityID, tagID,
+		)
+		if queryErr == sql.ErrNoRows {
+			return newAPIError(http.StatusNotFound, "link not found")
+		}
+		if queryErr != nil {
+			return queryErr
+		}
+
+		if _, execErr := txExec(ctx, tx, "update entity_tag_tbl set metadata = ? where id = ?", metadata, before.LinkID); execErr != nil {
+			return execErr
+		}
+
+		link = before
+		link.Metadata = metadata
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionEntityUpdateLink,
+			EntityType: AuditEntityTypeEntity,
+			EntityID:   entityID,
+			Metadata: gin.H{
+				"entity_type": entityType,
+				"tag_id":      tagID,
+				"before":      before.Metadata,
+				"after":       metadata,
+			},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	return &link, nil
+}
+
+// LinkUpdate 重复关联时需要修改的字段，为 nil 的字段保持不变
+type LinkUpdate struct {
+	Source  *string
+	AddedBy *string
+	Weight  *float64
+}
+
+// UpdateLink 在事务中修改实体与标签关联的来源、操作人和权重，用于重复关联同一个标签，关联不存在时返回 404。
+// 修改后 updated_at 会更新为当前时间
+func UpdateLink(ctx context.Context, entityType string, entityID, tagID int, update LinkUpdate) (*EntityTag, error) {
+	tenantID := TenantIDFromContext(ctx)
+
+	var link EntityTag
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var before EntityTag
+		queryErr := txGet(
+			ctx, tx, &before,
+			"select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and tag_id = ? and deleted_at is null for update",
+			tenantID, entityType, entityID, tagID,
+		)
+		if queryErr == sql.ErrNoRows {
+			return newAPIError(http.StatusNotFound, "link not found")
+		}
+		if queryErr != nil {
+			return queryErr
+		}
+
+		link = before
+		sets := []string{}
+		args := []interface{}{}
+		changedBefore := gin.H{}
+		changedAfter := gin.H{}
+		if update.Source != nil && *update.Source != before.Source {
+			sets = append(sets, "source = ?")
+			args = append(args, *update.Source)
+			changedBefore["source"], changedAfter["source"] = before.Source, *update.Source
+			link.Source = *update.Source
+		}
+		if update.AddedBy != nil && *update.AddedBy != before.AddedBy {
+			sets = append(sets, "added_by = ?")
+			args = append(args, *update.AddedBy)
+			changedBefore["added_by"], changedAfter["added_by"] = before.AddedBy, *update.AddedBy
+			link.AddedBy = *update.AddedBy
+		}
+		if update.Weight != nil && *update.Weight != before.Weight {
+			sets = append(sets, "weight = ?")
+			args = append(args, *update.Weight)
+			changedBefore["weight"], changedAfter["weight"] = before.Weight, *update.Weight
+			link.Weight = *update.Weight
+		}
+
+		// 没有变化时不修改 updated_at，也不记录审计日志
+		if len(sets) == 0 {
+			return nil
+		}
+
+		args = append(args, before.LinkID)
+		if _, execErr := txExec(ctx, tx, "update entity_tag_tbl set "+strings.Join(sets, ", ")+" where id = ?", args...); execErr != nil {
+			return execErr
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionEntityUpdateLink,
+			EntityType: AuditEntityTypeEntity,
+			EntityID:   entityID,
+			Metadata: gin.H{
+				"entity_type": entityType,
+				"tag_id":      tagID,
+				"before":      changedBefore,
+				"after":       changedAfter,
+			},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	return &link, nil
+}
+
+// PutLinkMetadataReqBody 修改关联附加信息的请求体
+type PutLinkMetadataReqBody struct {
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	// Metadata 新的附加信息，整体替换原有的值，为 null 时清空
+	Metadata LinkMetadata `json:"metadata"`
+}
+
+// OnPutLinkMetadata 修改实体与标签关联的附加信息
+// @Summary 修改关联的附加信息
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "实体 ID"
+// @Param tag_id path int true "标签 ID"
+// @Param body body PutLinkMetadataReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=EntityTag}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/entity/{id}/tags/{tag_id}/metadata [put]
+func OnPutLinkMetadata(c *gin.Context) {
+	entityID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	tagID, ok := parseIDParam(c, "tag_id")
+	if !ok {
+		return
+	}
+
+	var reqBody PutLinkMetadataReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	if err := validateLinkMetadata(reqBody.Metadata); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	link, err := UpdateLinkMetadata(c.Request.Context(), entityType, entityID, tagID, reqBody.Metadata)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, link)
+}
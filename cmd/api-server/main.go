@@ -1,62 +1,171 @@
+//go:generate swag init -g main.go -o ../../docs
+
 package main
 
 import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
-	"sort"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
-	"github.com/bitly/go-simplejson"
 	elasticsearch7 "github.com/elastic/go-elasticsearch/v7"
 	esapi "github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/swaggo/gin-swagger/swaggerFiles"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/text/unicode/norm"
 
 	"github.com/jmoiron/sqlx"
 
-	_ "github.com/go-sql-driver/mysql" // mysql driver
+	_ "github.com/3vilive/tag-server/docs" // swagger docs
+	_ "github.com/go-sql-driver/mysql"     // mysql driver
 )
 
 var (
+	config   *Config
 	mysqlDB  *sqlx.DB
 	esClient *elasticsearch7.Client
 )
 
-func init() {
+// shutdownTimeout 服务退出时等待进行中的请求和后台任务完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
+// mysqlDSN MySQL 连接地址
+const mysqlDSN = "test:test@tcp(localhost:3306)/test?parseTime=True&loc=Local&multiStatements=true&charset=utf8mb4"
+
+// setupClients 初始化 MySQL 连接池和 ES 客户端，需要在数据库迁移完成之后调用，不会等待 ES 可用
+func setupClients() {
 	// 初始化 mysql
-	mysqlDB = sqlx.MustOpen("mysql", "test:test@tcp(localhost:3306)/test?parseTime=True&loc=Local&multiStatements=true&charset=utf8mb4")
+	mysqlDB = sqlx.MustOpen("mysql", mysqlDSN)
+	mysqlDB.SetMaxOpenConns(config.DBMaxOpenConns)
+	mysqlDB.SetMaxIdleConns(config.DBMaxIdleConns)
+	mysqlDB.SetConnMaxLifetime(config.DBConnMaxLifetime)
+	RegisterDBMetrics(mysqlDB)
+	RegisterTagCacheMetrics()
 
 	// 初始化 ES
-	esConf := elasticsearch7.Config{
-		Addresses: []string{"http://localhost:9200"},
-	}
-	es, err := elasticsearch7.NewClient(esConf)
+	es, err := NewResilientESClient(config.ESAddresses)
 	if err != nil {
 		panic(err)
 	}
 
-	res, err := es.Info()
-	if err != nil {
-		panic(err)
+	// ES 不可用时不阻止服务启动，在后台等待 ES 可用，期间 /readyz 返回 503
+	esClient = es
+	go WaitForES(es)
+	RegisterESMetrics()
+
+	setupRedis()
+}
+
+// Tag 标签结构定义
+type Tag struct {
+	TagID    int    `db:"id" json:"tag_id"`
+	TenantID string `db:"tenant_id" json:"tenant_id"`
+	// Name 经过 NormalizeTagName 处理的名称，用于去重和查询
+	Name string `db:"name" json:"name"`
+	// DisplayName 创建或改名时传入的原始名称，用于展示
+	DisplayName string    `db:"display_name" json:"display_name"`
+	Description string    `db:"description" json:"description"`
+	Color       string    `db:"color" json:"color"`
+	Category    string    `db:"category" json:"category"`
+	Version     int       `db:"version" json:"version"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	// DeletedAt 软删除的时间，未删除时为 nil
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	// ExpiresAt 过期时间，过期后由 TagExpiryWorker 软删除，为 nil 时不过期
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	// Score ES 搜索结果的相关度，只有搜索接口返回。取值为 BM25 算出的非负数，没有固定上限，
+	// 只能在同一次搜索的结果之间比较，不同关键字或不同匹配方式的分数不可比较。
+	// 降级到 MySQL 搜索时没有相关度，为 0 并省略
+	Score float64 `db:"-" json:"score,omitempty"`
+	// Synonyms 标签的同义词，保存在 tag_synonym_tbl 中，通过 LoadTagSynonyms 加载，
+	// 上报到 ES 时一起写入，搜索同义词时可以搜索到该标签
+	Synonyms []string `db:"-" json:"synonyms,omitempty"`
+	// Metadata 标签的元数据，例如颜色代码、图标地址，保存在 tag_metadata_tbl 中，通过 LoadTagMetadata 加载，
+	// 上报到 ES 时一起写入。修改元数据时版本号加 1
+	Metadata map[string]string `db:"-" json:"metadata,omitempty"`
+	// Highlighted 名称中匹配关键字的部分使用 <em></em> 包围后的结果，其余部分按 HTML 转义，
+	// 只有搜索时传入 highlight 才返回，名称没有可以高亮的片段时为转义后的名称
+	Highlighted string `db:"-" json:"highlighted,omitempty"`
+}
+
+// tagColumns 查询 tag_tbl 时使用的字段列表，与 Tag 结构对应
+const tagColumns = "id, tenant_id, name, display_name, description, color, category, version, created_at, updated_at, deleted_at, expires_at"
+
+// GetTagByID 根据 ID 查询当前租户未删除的标签，优先读取进程内缓存，标签不存在或已被删除时返回 sql.ErrNoRows
+func GetTagByID(ctx context.Context, tagID int) (*Tag, error) {
+	if tag := getCachedTag(TenantIDFromContext(ctx), tagID); tag != nil {
+		return tag, nil
 	}
 
-	if res.IsError() {
-		panic(res.String())
+	var tag Tag
+	if err := dbGet(ctx, &tag, "select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ? and deleted_at is null", TenantIDFromContext(ctx), tagID); err != nil {
+		return nil, err
 	}
+	setCachedTags(&tag)
+	return &tag, nil
+}
 
-	esClient = es
+// GetTagByName 根据名称查询当前租户未删除的标签，名称经过 NormalizeTagName 处理后比较，
+// 标签不存在或已被删除时返回 sql.ErrNoRows
+func GetTagByName(ctx context.Context, name string) (*Tag, error) {
+	var tag Tag
+	if err := dbGet(ctx, &tag, "select "+tagColumns+" from tag_tbl where tenant_id = ? and name = ? and deleted_at is null", TenantIDFromContext(ctx), NormalizeTagName(name)); err != nil {
+		return nil, err
+	}
+	return &tag, nil
 }
 
-// Tag 标签结构定义
-type Tag struct {
-	TagID int    `db:"id" json:"tag_id"`
-	Name  string `db:"name" json:"name"`
+// ResolveTagID 返回请求中通过 tag_id 或 tag_name 指定的标签 ID，用于允许按名称指定标签的接口。
+// 名称与创建标签时一样去除两端空白并规范化后查询，标签不存在或已被删除时返回 404，
+// 两者都没有传入或者同时传入但不是同一个标签时返回 400
+func ResolveTagID(ctx context.Context, tagID int, tagName string) (int, error) {
+	if tagName == "" {
+		if tagID <= 0 {
+			return 0, newAPIError(http.StatusBadRequest, "tag_id or tag_name is required")
+		}
+		return tagID, nil
+	}
+	if tagID < 0 {
+		return 0, newAPIError(http.StatusBadRequest, "invalid tag_id")
+	}
+
+	name, err := validateTagName(tagName)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, queryErr := GetTagByName(ctx, name)
+	if queryErr == sql.ErrNoRows {
+		return 0, newAPIError(http.StatusNotFound, "tag not found")
+	}
+	if queryErr != nil {
+		return 0, queryErr
+	}
+
+	if tagID > 0 && tagID != tag.TagID {
+		return 0, newAPIError(http.StatusBadRequest, "tag_id does not match tag_name")
+	}
+	return tag.TagID, nil
 }
 
 // MustToJSON 将结构转换成 JSON
@@ -68,413 +177,1545 @@ func (t *Tag) MustToJSON() string {
 	return string(bs)
 }
 
-// ReportTagToES 上报 Tag 到 ES
-func ReportTagToES(tag *Tag) {
-	req := esapi.IndexRequest{
-		Index:        "test",
-		DocumentType: "tag",
-		DocumentID:   strconv.Itoa(tag.TagID),
-		Body:         strings.NewReader(tag.MustToJSON()),
-		Refresh:      "true",
+// ReportTagToES 上报 Tag 到 ES 的 index 索引，index 也可以是设置了写索引的别名。ES 不可用或重试后仍然失败时
+// 把标签写入 es_outbox_tbl，由 ESOutboxWorker 稍后重新上报，此时返回 true。
+// 上报不受请求的 context 取消影响，可以在请求中同步调用，也可以通过 go 语句在后台调用
+func ReportTagToES(ctx context.Context, index string, tag *Tag) bool {
+	// 只保留请求 context 中的链路信息。重试的总时长不超过每次请求超时时间之和
+	ctx, cancel := context.WithTimeout(detachedContext(ctx), config.QueryTimeout*time.Duration(config.ESIndexMaxAttempts))
+	defer cancel()
+
+	// ES 还没有可用过时直接写入 outbox，不等待重试超时
+	err := fmt.Errorf("%w: not ready", ErrESUnavailable)
+	if IsESReady() {
+		err = IndexTagToES(ctx, index, tag)
+	}
+	if err == nil {
+		return false
+	}
+
+	esIndexFailures.Inc()
+	log.Printf("ESIndexRequestErr: tag=%d %s", tag.TagID, err)
+	if err := EnqueueESOutbox(ctx, index, tag, err); err != nil {
+		log.Printf("ESOutboxEnqueueErr: tag=%d %s", tag.TagID, err)
 	}
+	return true
+}
 
-	resp, err := req.Do(context.Background(), esClient)
+// IndexTagToES 把 Tag 写入 ES 的 index 索引，网络错误和 5xx 响应按 ES_INDEX_MAX_ATTEMPTS 重试。
+// 文档中的同义词和元数据在写入时从 MySQL 重新加载，不修改传入的 tag
+func IndexTagToES(ctx context.Context, index string, tag *Tag) error {
+	// 调用方可能同时在序列化 tag，复制一份再填充同义词和元数据
+	docTag := *tag
+	if err := LoadTagSynonyms(ctx, []*Tag{&docTag}); err != nil {
+		return err
+	}
+	if err := LoadTagMetadata(ctx, []*Tag{&docTag}); err != nil {
+		return err
+	}
+	doc := docTag.MustToJSON()
+
+	ctx, span := tracer.Start(ctx, "es.index", trace.WithAttributes(
+		attribute.String("es.index", index),
+		attribute.Int("tag.id", tag.TagID),
+	))
+	var spanErr error
+	defer func() { endSpan(span, spanErr) }()
+
+	resp, err := DoESRequestWithRetry(ctx, config.ESIndexMaxAttempts, func(ctx context.Context) (*esapi.Response, error) {
+		req := esapi.IndexRequest{
+			Index:      index,
+			DocumentID: strconv.Itoa(tag.TagID),
+			Body:       strings.NewReader(doc),
+			Refresh:    "true",
+		}
+		return req.Do(ctx, esClient)
+	})
 	if err != nil {
-		log.Printf("ESIndexRequestErr: %s", err.Error())
-		return
+		spanErr = err
+		return err
 	}
 
 	defer resp.Body.Close()
 	if resp.IsError() {
-		log.Printf("ESIndexRequestErr: %s", resp.String())
-	} else {
-		log.Printf("ESIndexRequestOk: %s", resp.String())
+		spanErr = errors.New(resp.Status())
+		return errors.New(resp.String())
 	}
+
+	log.Printf("ESIndexRequestOk: %s", resp.String())
+	// 索引已经刷新，删除缓存后的搜索可以看到新的标签
+	InvalidateSearchCache(tag.TenantID)
+	return nil
 }
 
-// O is shortcut of map[string]interface{}
-type O map[string]interface{}
+// DeleteTagFromES 从 ES 的 index 索引中删除 Tag，ES 不可用或删除失败时写入 es_outbox_tbl，
+// 后台任务发现标签已经删除后重新从 ES 中删除文档
+func DeleteTagFromES(index, tenantID string, tagID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.QueryTimeout)
+	defer cancel()
 
-// MustToJSONBytesBuffer 将 O 结构转换成 JSON 并返回对应的 Buffer
-func (o *O) MustToJSONBytesBuffer() *bytes.Buffer {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(o); err != nil {
-		panic(err)
+	err := fmt.Errorf("%w: not ready", ErrESUnavailable)
+	if IsESReady() {
+		err = DeleteTagDocFromES(ctx, index, tenantID, tagID)
+	}
+	if err == nil {
+		return
 	}
 
-	return &buf
+	log.Printf("ESDeleteRequestErr: tag=%d %s", tagID, err)
+	// ctx 可能已经超时，使用新的 context 写入 outbox
+	if err := EnqueueESOutbox(context.Background(), index, &Tag{TagID: tagID, TenantID: tenantID}, err); err != nil {
+		log.Printf("ESOutboxEnqueueErr: tag=%d %s", tagID, err)
+	}
 }
 
-// SearchTagsFromES 从 ES 搜索标签
-func SearchTagsFromES(keyword string) ([]*Tag, error) {
-	// 构建查询
-	query := O{
-		"query": O{
-			"match_phrase_prefix": O{
-				"name": keyword,
-			},
+// DeleteTagDocFromES 从 ES 的 index 索引中删除 Tag 的文档，文档不存在时同样视为删除成功，
+// 成功后删除租户缓存的搜索结果
+func DeleteTagDocFromES(ctx context.Context, index, tenantID string, tagID int) error {
+	req := esapi.DeleteRequest{
+		Index:      index,
+		DocumentID: strconv.Itoa(tagID),
+		Refresh:    "true",
+	}
+
+	resp, err := req.Do(ctx, esClient)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	// 文档不存在时 ES 返回 404
+	if resp.IsError() && resp.StatusCode != http.StatusNotFound {
+		return errors.New(resp.String())
+	}
+
+	log.Printf("ESDeleteRequestOk: %s", resp.String())
+	InvalidateSearchCache(tenantID)
+	return nil
+}
+
+// SearchTagsOptions 搜索标签的可选条件
+type SearchTagsOptions struct {
+	// TagIDs 只在这些标签中搜索，为空时不限制
+	TagIDs []int
+	// Size 返回的数量，为 0 时使用 ES 的默认值
+	Size int
+	// SearchAfter 上一页最后一条结果的排序值，用于 search_after 翻页
+	SearchAfter []interface{}
+	// Index 搜索的索引或别名，为空时使用 config.ESSearchIndex
+	Index string
+	// Match 匹配方式，prefix、infix 或 wildcard，为空时使用 config.ESSearchMatch
+	Match string
+	// MinScore 过滤掉相关度低于该值的结果，为 0 时不过滤
+	MinScore float64
+	// Highlight 为 true 时填充结果的 Highlighted
+	Highlight bool
+	// Mode 关键字包含多个词时的组合方式，and 或 or，为空时为 or。wildcard 匹配方式下关键字作为一个整体，忽略 Mode
+	Mode string
+}
+
+// SearchTagsResult 搜索标签的结果
+type SearchTagsResult struct {
+	Tags []*Tag
+	// LastSort 最后一条结果的排序值，没有结果时为 nil
+	LastSort []interface{}
+	// Hits ES 返回的命中数量，包括去重时丢弃的命中，等于请求的数量时可能还有下一页
+	Hits int
+	// Total ES 统计的匹配总数，超过 10000 时只是下限。降级到 MySQL 时为 0
+	Total esHitsTotal
+}
+
+// esSearchHit ES 搜索结果中的一条记录，_source 为上报时写入的 Tag
+type esSearchHit struct {
+	Score  *float64      `json:"_score"`
+	Source *Tag          `json:"_source"`
+	Sort   []interface{} `json:"sort"`
+	// Highlight 字段名到高亮结果的映射，只有请求中带有 highlight 且字段有匹配时才有
+	Highlight map[string][]string `json:"highlight"`
+}
+
+// esHitsTotal 搜索结果的匹配总数。ES 7 返回 {"value": 10, "relation": "eq"}，
+// relation 为 gte 时 value 只是下限；ES 6 及 rest_total_hits_as_int=true 时直接返回数字，按 eq 处理
+type esHitsTotal struct {
+	Value    int    `json:"value"`
+	Relation string `json:"relation"`
+}
+
+// UnmarshalJSON 同时支持对象和数字两种格式
+func (t *esHitsTotal) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] != '{' {
+		t.Relation = "eq"
+		return json.Unmarshal(data, &t.Value)
+	}
+
+	type plain esHitsTotal
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+// esSearchResponse ES 搜索接口的响应中用到的部分
+type esSearchResponse struct {
+	Hits struct {
+		Total esHitsTotal    `json:"total"`
+		Hits  []*esSearchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// ErrESUnavailable 无法连接 ES 或 ES 集群不可用，搜索时可以降级到 MySQL
+var ErrESUnavailable = errors.New("elasticsearch unavailable")
+
+// SearchTagsFromES 从 ES 搜索当前租户的标签，结果按 _score 和 tag_id 排序，保证翻页时顺序稳定。
+// 搜索过程记录在 es.search span 中
+func SearchTagsFromES(ctx context.Context, keyword string, opts SearchTagsOptions) (*SearchTagsResult, error) {
+	ctx, span := tracer.Start(ctx, "es.search", trace.WithAttributes(
+		attribute.String("es.search.match", opts.Match),
+		attribute.Int("es.search.size", opts.Size),
+	))
+	result, err := searchTagsFromES(ctx, keyword, opts)
+	if err == nil {
+		span.SetAttributes(
+			attribute.Int("es.search.hits", len(result.Tags)),
+			attribute.Int("es.search.total", result.Total.Value),
+			attribute.String("es.search.total_relation", result.Total.Relation),
+		)
+	}
+	endSpan(span, err)
+	return result, err
+}
+
+// searchTagsFromES 实现 SearchTagsFromES
+func searchTagsFromES(ctx context.Context, keyword string, opts SearchTagsOptions) (*SearchTagsResult, error) {
+	// 启动后 ES 还没有可用过时不发出请求，直接按不可用处理
+	if !IsESReady() {
+		return nil, fmt.Errorf("%w: not ready", ErrESUnavailable)
+	}
+
+	// 构建查询，只能搜索到当前租户的标签
+	filters := []ESQueryBuilder{Term("tenant_id.keyword", TenantIDFromContext(ctx))}
+
+	// 限制可以搜索到的标签
+	if len(opts.TagIDs) > 0 {
+		filters = append(filters, Terms("tag_id", opts.TagIDs))
+	}
+
+	// infix 使用 name.ngram 子字段匹配名称中任意位置的片段，需要索引使用 esTagIndexBody 中的 mapping
+	match := opts.Match
+	if match == "" {
+		match = config.ESSearchMatch
+	}
+	// 名称和同义词使用同样的匹配方式，满足其中之一即可
+	// 高亮时需要指定查询实际使用的字段，否则 ES 不会高亮
+	var subField string
+	switch match {
+	case ESSearchMatchInfix:
+		subField = ".ngram"
+	case ESSearchMatchWildcard:
+		subField = ".keyword"
+	}
+	// 每个词在名称或同义词中匹配即可，多个词按 Mode 组合
+	terms := []string{keyword}
+	if match != ESSearchMatchWildcard {
+		terms = strings.Fields(keyword)
+	}
+	termQueries := make([]ESQueryBuilder, 0, len(terms))
+	for _, term := range terms {
+		nameQueries := make([]ESQueryBuilder, 0, 2)
+		for _, field := range []string{"name", "synonyms"} {
+			switch match {
+			case ESSearchMatchInfix:
+				nameQueries = append(nameQueries, Match(field+subField, term, "and"))
+			case ESSearchMatchWildcard:
+				nameQueries = append(nameQueries, Wildcard(field+subField, term, true))
+			default:
+				nameQueries = append(nameQueries, MatchPhrasePrefix(field, term))
+			}
+		}
+		termQueries = append(termQueries, Bool(nil, nameQueries, nil))
+	}
+	keywordQuery := Bool(nil, termQueries, nil)
+	if opts.Mode == SearchModeAnd {
+		keywordQuery = Bool(termQueries, nil, nil)
+	}
+
+	query := &ESSearchRequest{
+		Query: Bool([]ESQueryBuilder{keywordQuery}, nil, nil).Filter(filters...),
+		Sort: []ESSort{
+			{"_score": "desc"},
+			{"tag_id": "asc"},
 		},
+		Size:        opts.Size,
+		SearchAfter: opts.SearchAfter,
+		MinScore:    opts.MinScore,
+	}
+	if opts.Highlight {
+		query.Highlight = HighlightFields("name" + subField)
+	}
+
+	index := opts.Index
+	if index == "" {
+		index = config.ESSearchIndex
 	}
-	jsonBuf := query.MustToJSONBytesBuffer()
 
 	// 发出查询请求
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+
 	resp, err := esClient.Search(
-		esClient.Search.WithContext(context.Background()),
-		esClient.Search.WithIndex("test"),
-		esClient.Search.WithBody(jsonBuf),
+		esClient.Search.WithContext(ctx),
+		esClient.Search.WithIndex(index),
+		esClient.Search.WithBody(query.Build()),
 	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %s", ErrESUnavailable, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.IsError() {
-		return nil, errors.New(resp.Status())
-	}
-
-	js, err := simplejson.NewFromReader(resp.Body)
-	if err != nil {
-		return nil, err
+		// 网关错误和集群不可用同样视为连接问题，其它错误说明查询本身有问题
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return nil, fmt.Errorf("%w: %s", ErrESUnavailable, resp.Status())
+		}
+		return nil, errors.New(resp.String())
 	}
 
-	hitsJS := js.GetPath("hits", "hits")
-	hits, err := hitsJS.Array()
-	if err != nil {
+	// 使用 json.Number 解析排序值，可以原样传回 ES
+	var searchResp esSearchResponse
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&searchResp); err != nil {
 		return nil, err
 	}
 
-	hitsLen := len(hits)
-	if hitsLen == 0 {
-		return []*Tag{}, nil
+	highlightField := ""
+	if opts.Highlight {
+		highlightField = "name" + subField
 	}
+	return searchTagsResultFromHits(&searchResp, highlightField)
+}
 
-	tags := make([]*Tag, 0, len(hits))
-	for idx := 0; idx < hitsLen; idx++ {
-		sourceJS := hitsJS.GetIndex(idx).Get("_source")
+// searchTagsResultFromHits 把 ES 的搜索结果转换为 SearchTagsResult，highlightField 为空时不读取高亮结果。
+// 结果已经由 ES 按 _score 从高到低排序，分数相同时按 tag_id 排序。
+// 搜索的别名同时指向多个索引时同一个标签可能出现多次，只保留分数最高的一次，
+// LastSort 仍然取最后一条命中，保证翻页不会遗漏
+func searchTagsResultFromHits(searchResp *esSearchResponse, highlightField string) (*SearchTagsResult, error) {
+	hits := searchResp.Hits.Hits
+	result := &SearchTagsResult{Tags: make([]*Tag, 0, len(hits)), Hits: len(hits), Total: searchResp.Hits.Total}
+	tagIdx := make(map[int]int, len(hits))
+	for _, hit := range hits {
+		if hit.Source == nil {
+			return nil, errors.New("search hit without _source")
+		}
 
-		tagID, err := sourceJS.Get("tag_id").Int()
-		if err != nil {
-			return nil, err
+		tag := hit.Source
+		if hit.Score != nil {
+			tag.Score = *hit.Score
+		}
+		if highlightField != "" {
+			// 只通过同义词匹配时名称没有高亮结果
+			if fragments := hit.Highlight[highlightField]; len(fragments) > 0 {
+				tag.Highlighted = fragments[0]
+			} else {
+				tag.Highlighted = html.EscapeString(tag.Name)
+			}
 		}
 
-		tagName, err := sourceJS.Get("name").String()
-		if err != nil {
-			return nil, err
+		result.LastSort = hit.Sort
+		if idx, ok := tagIdx[tag.TagID]; ok {
+			if tag.Score > result.Tags[idx].Score {
+				result.Tags[idx] = tag
+			}
+			continue
 		}
+		tagIdx[tag.TagID] = len(result.Tags)
+		result.Tags = append(result.Tags, tag)
+	}
+
+	return result, nil
+}
 
-		tagEntity := &Tag{TagID: tagID, Name: tagName}
-		tags = append(tags, tagEntity)
+// SearchTags 从 ES 搜索当前租户的标签，ES 不可用时降级到 MySQL 搜索，此时 degraded 为 true，降级结果不支持翻页。
+// keyword 需要先经过 validateSearchKeyword 校验和 NormalizeTagName 规范化
+func SearchTags(ctx context.Context, keyword string, opts SearchTagsOptions) (result *SearchTagsResult, degraded bool, err error) {
+	result, err = SearchTagsFromES(ctx, keyword, opts)
+	if errors.Is(err, ErrESUnavailable) {
+		log.Printf("[WARN] SearchTagsFallbackToMySQL: %s", err)
+		result, err = SearchTagsFromMySQL(ctx, keyword, opts)
+		degraded = true
 	}
+	return result, degraded, err
+}
+
+// searchNextCursor 返回下一页的游标，没有更多结果时为空字符串。去重后的标签数量可能少于 limit，
+// 需要按 ES 返回的命中数量判断是否还有下一页
+func searchNextCursor(result *SearchTagsResult, limit int) string {
+	if result.Hits == limit && result.LastSort != nil {
+		return EncodeSearchCursor(result.LastSort)
+	}
+	return ""
+}
 
-	return tags, nil
+// EncodeSearchCursor 把排序值编码为客户端使用的游标
+func EncodeSearchCursor(sortValues []interface{}) string {
+	bs, err := json.Marshal(sortValues)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+// DecodeSearchCursor 解析客户端传回的游标，返回排序值
+func DecodeSearchCursor(cursor string) ([]interface{}, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+	var sortValues []interface{}
+	if err := decoder.Decode(&sortValues); err != nil {
+		return nil, err
+	}
+	if len(sortValues) == 0 {
+		return nil, errors.New("empty cursor")
+	}
+	return sortValues, nil
+}
+
+// maxTagNameLength 标签名称的最大长度，与 tag_tbl.name 字段的长度一致
+const maxTagNameLength = 40
+
+// validateTagName 去除标签名称两端的空白并校验，返回处理后的名称
+func validateTagName(name string) (string, error) {
+	tagName := strings.TrimSpace(name)
+	if tagName == "" {
+		return "", newAPIError(http.StatusBadRequest, "invalid name")
+	}
+	if utf8.RuneCountInString(tagName) > maxTagNameLength {
+		return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("name too long, max %d characters", maxTagNameLength))
+	}
+	return tagName, nil
+}
+
+// NormalizeTagName 返回用于去重和查询的名称，转换为小写并使用 Unicode NFC 规范化，
+// 大小写或组合方式不同的名称视为同一个标签
+func NormalizeTagName(name string) string {
+	return norm.NFC.String(strings.ToLower(name))
 }
 
 // NewTagReqBody 创建标签的请求体
 type NewTagReqBody struct {
 	Name string `json:"name"`
+	// ExpiresAt 可选的过期时间，RFC3339 格式，需要晚于当前时间
+	ExpiresAt *time.Time `json:"expires_at"`
 }
 
-// OnNewTag 创建标签
-func OnNewTag(c *gin.Context) {
-	var reqBody NewTagReqBody
-	if bindErr := c.BindJSON(&reqBody); bindErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": bindErr.Error(),
-		})
-		return
+// GetOrCreateTagTx 在事务中查询名称为 tagName 的标签，不存在时创建，已经被软删除时恢复，过期时间使用 expiresAt。
+// tagName 需要先经过 validateTagName 校验，按 NormalizeTagName 规范化后去重。标签为本次新建或恢复时 changed 为 true，
+// 调用方需要在事务提交后上报到 ES 并发布创建事件
+func GetOrCreateTagTx(ctx context.Context, tx *sqlx.Tx, tagName string, expiresAt *time.Time) (tag *Tag, changed bool, err error) {
+	tenantID := TenantIDFromContext(ctx)
+	normalizedName := NormalizeTagName(tagName)
+
+	var queryTag Tag
+	queryErr := txGet(ctx, tx, &queryTag, "select "+tagColumns+" from tag_tbl where tenant_id = ? and name = ?", tenantID, normalizedName)
+	if queryErr != nil && queryErr != sql.ErrNoRows {
+		return nil, false, queryErr
 	}
 
-	// 判断传入的 tag 名称是否为空
-	tagName := strings.TrimSpace(reqBody.Name)
-	if tagName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": "invalid name",
-		})
-		return
+	// tag 已经存在
+	if queryErr == nil && queryTag.DeletedAt == nil {
+		return &queryTag, false, nil
 	}
 
-	var queryTag Tag
-	queryErr := mysqlDB.Get(&queryTag, "select id, name from tag_tbl where name = ?", tagName)
+	var tagID int
 	if queryErr == nil {
-		// tag 已经存在
-		c.JSON(http.StatusOK, gin.H{
-			"tag_id": queryTag.TagID,
+		// tag 已经被软删除，恢复后需要重新添加到 ES 索引，过期时间使用本次传入的值。
+		// 恢复时增加版本号，之前缓存的标签详情的 ETag 随之失效
+		if _, execErr := txExec(ctx, tx, "update tag_tbl set deleted_at = null, expires_at = ?, version = version + 1 where id = ?", expiresAt, queryTag.TagID); execErr != nil {
+			return nil, false, execErr
+		}
+
+		auditErr := InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagRestore,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   queryTag.TagID,
+			Metadata:   gin.H{"before": queryTag},
 		})
-		return
+		if auditErr != nil {
+			return nil, false, auditErr
+		}
+		tagID = queryTag.TagID
+	} else {
+		// tag 不存在，按规范化后的名称去重，展示时使用原始名称
+		result, execErr := txExec(ctx, tx, "insert into tag_tbl (tenant_id, name, display_name, expires_at) values (?, ?, ?, ?) on duplicate key update created_at = now()", tenantID, normalizedName, tagName, expiresAt)
+		if execErr != nil {
+			return nil, false, execErr
+		}
+
+		lastInsertID, err := result.LastInsertId()
+		if err != nil {
+			return nil, false, err
+		}
+		tagID = int(lastInsertID)
 	}
 
-	// 查询 mysql 出现错误
-	if queryErr != sql.ErrNoRows {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": queryErr.Error(),
-		})
-		return
+	// 读取数据库生成的时间字段
+	var newTag Tag
+	if queryErr := txGet(ctx, tx, &newTag, "select "+tagColumns+" from tag_tbl where id = ?", tagID); queryErr != nil {
+		return nil, false, queryErr
 	}
 
-	// tag 不存在，创建 tag
-	result, execErr := mysqlDB.Exec("insert into tag_tbl (name) values (?) on duplicate key update created_at = now()", tagName)
-	if execErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": execErr.Error(),
+	// 恢复的标签已经记录过审计日志
+	if queryErr == sql.ErrNoRows {
+		auditErr := InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagCreate,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   newTag.TagID,
+			Metadata:   gin.H{"after": newTag},
 		})
+		if auditErr != nil {
+			return nil, false, auditErr
+		}
+	}
+	return &newTag, true, nil
+}
+
+// OnNewTag 创建标签
+// @Summary 创建标签
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param Idempotency-Key header string false "幂等键，有效期内重复提交会返回第一次请求的响应"
+// @Param body body NewTagReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{tag_id=int}}
+// @Header 200 {string} X-ES-Index-Delayed "写入 ES 失败、稍后重新上报时为 true，此时暂时搜索不到该标签"
+// @Failure 400 {object} APIResponse
+// @Failure 409 {object} APIResponse "相同幂等键的请求还在处理中"
+// @Failure 413 {object} APIResponse
+// @Failure 422 {object} APIResponse "幂等键已经用于不同的请求体"
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag [post]
+func OnNewTag(c *gin.Context) {
+	var reqBody NewTagReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
 		return
 	}
 
-	tagID, err := result.LastInsertId()
+	tag, esIndexDelayed, err := CreateTag(c.Request.Context(), reqBody.Name, reqBody.ExpiresAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": err.Error(),
-		})
+		respondServerError(c, err)
 		return
 	}
 
-	// 添加到 ES 索引
-	newTag := &Tag{TagID: int(tagID), Name: tagName}
-	go ReportTagToES(newTag)
+	// 通过响应头告知调用方暂时搜索不到
+	if esIndexDelayed {
+		c.Header(esIndexDelayedHeader, "true")
+	}
+
+	respondOK(c, gin.H{
+		"tag_id": tag.TagID,
+	})
+}
+
+// CreateTag 校验名称和过期时间后创建标签，标签已经存在时返回已有的标签，已经被软删除时恢复，
+// 校验失败时返回 APIError。新建或恢复的标签同步添加到 ES 索引，失败时由 ESOutboxWorker 稍后重新上报，
+// 此时 esIndexDelayed 为 true。供 HTTP 和 GraphQL 接口共用
+func CreateTag(ctx context.Context, name string, expiresAt *time.Time) (tag *Tag, esIndexDelayed bool, err error) {
+	// 判断传入的 tag 名称是否合法
+	tagName, err := validateTagName(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, false, newAPIError(http.StatusBadRequest, "expires_at must be in the future")
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"tag_id": tagID,
+	changed := false
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		tag, changed, err = GetOrCreateTagTx(ctx, tx, tagName, expiresAt)
+		return err
 	})
+	if txErr != nil {
+		return nil, false, txErr
+	}
+
+	if changed {
+		esIndexDelayed = ReportTagToES(ctx, config.ESIndex, tag)
+		PublishTagCreated(tag)
+	}
+	return tag, esIndexDelayed, nil
 }
 
 // SearchTagReqBody 搜索标签的请求体
 type SearchTagReqBody struct {
 	Keyword string `json:"keyword"`
+	// Match 匹配方式，prefix 只匹配开头，infix 匹配任意位置，wildcard 表示 keyword 为通配符模式，
+	// 不传时使用 ES_SEARCH_MATCH
+	Match string `json:"match"`
+	// MinScore 只返回相关度不低于该值的结果，见 Tag.Score
+	MinScore float64 `json:"min_score"`
+	// Highlight 为 true 时返回 Tag.Highlighted，用于加粗名称中匹配的部分
+	Highlight bool `json:"highlight"`
+}
+
+// validateSearchKeyword 去除关键字两端的空白并校验词数，关键字为空或超过 maxSearchTerms 个词时返回 400
+func validateSearchKeyword(keyword string) (string, error) {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return "", newAPIError(http.StatusBadRequest, "invalid keyword")
+	}
+	if len(strings.Fields(keyword)) > maxSearchTerms {
+		return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("too many terms in keyword, max %d", maxSearchTerms))
+	}
+	return keyword, nil
+}
+
+// validateSearchTagsParams 校验搜索的关键字、匹配方式和多个词的组合方式，返回规范化后的关键字，HTTP 和 gRPC 接口共用
+func validateSearchTagsParams(keyword, match, mode string) (string, error) {
+	searchKeyword, err := validateSearchKeyword(keyword)
+	if err != nil {
+		return "", err
+	}
+	// 标签名称按规范化后的形式保存，关键字使用同样的处理
+	searchKeyword = NormalizeTagName(searchKeyword)
+	if mode != SearchModeOr && mode != SearchModeAnd {
+		return "", newAPIError(http.StatusBadRequest, "invalid mode")
+	}
+	if match == ESSearchMatchWildcard {
+		if err := validateWildcardPattern(searchKeyword); err != nil {
+			return "", err
+		}
+	} else if match != "" && !isValidESSearchMatch(match) {
+		return "", newAPIError(http.StatusBadRequest, "invalid match")
+	}
+	return searchKeyword, nil
 }
 
-// OnSearchTag 搜索标签
+// searchKeywordTooShort 关键字少于 SEARCH_MIN_KEYWORD_LENGTH 个字符时返回说明原因的 message 和 true，
+// 所有搜索入口在查询 ES 之前调用，为 true 时直接返回空的结果。
+// 单个字符的关键字匹配的标签过多，结果没有价值，还会给 ES 带来压力
+func searchKeywordTooShort(keyword string) (string, bool) {
+	if utf8.RuneCountInString(keyword) >= config.SearchMinKeywordLength {
+		return "", false
+	}
+	return fmt.Sprintf("keyword must be at least %d characters", config.SearchMinKeywordLength), true
+}
+
+// OnSearchTag 搜索标签，设置了 REDIS_ADDR 时结果在 Redis 中缓存 SEARCH_CACHE_TTL，标签写入或删除 ES 后删除缓存。
+// 去除两端空白后的关键字少于 SEARCH_MIN_KEYWORD_LENGTH 个字符时不查询 ES，返回空的 matches 和说明原因的 message
+// @Summary 搜索标签
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param ids query string false "只在这些标签中搜索，以逗号分隔，例如 1,2,3"
+// @Param limit query int false "每页数量，默认 10，最大 100"
+// @Param cursor query string false "上一页返回的 next_cursor"
+// @Param mode query string false "关键字包含多个以空白分隔的词时的组合方式，or 匹配任意一个词，and 匹配所有的词，默认 or"
+// @Param Cache-Control header string false "为 no-cache 时跳过缓存"
+// @Param body body SearchTagReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{matches=[]Tag,next_cursor=string,degraded=bool,message=string}}
+// @Header 200 {string} X-Cache "HIT 表示结果来自缓存，MISS 表示重新搜索"
+// @Failure 400 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/search [get]
 func OnSearchTag(c *gin.Context) {
 	var reqBody SearchTagReqBody
-	if bindErr := c.BindJSON(&reqBody); bindErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": bindErr.Error(),
-		})
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	mode := c.DefaultQuery("mode", SearchModeOr)
+	searchKeyword, err := validateSearchTagsParams(reqBody.Keyword, reqBody.Match, mode)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+	if reqBody.MinScore < 0 {
+		respondError(c, http.StatusBadRequest, "invalid min_score")
 		return
 	}
 
-	searchKeyword := strings.TrimSpace(reqBody.Keyword)
-	if searchKeyword == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": "invalid keyword",
+	if message, tooShort := searchKeywordTooShort(searchKeyword); tooShort {
+		respondOK(c, gin.H{
+			"matches":     []*Tag{},
+			"next_cursor": "",
+			"degraded":    false,
+			"message":     message,
 		})
 		return
 	}
 
-	tags, err := SearchTagsFromES(reqBody.Keyword)
+	// 通过 ?ids=1,2,3 限制搜索范围
+	tagIDs, err := parseIDList(c.Query("ids"))
 	if err != nil {
-		log.Printf("SearchTagsFromESErr: %s", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": fmt.Errorf("SearchTagsFromESErr: %s", err).Error(),
-		})
+		respondError(c, http.StatusBadRequest, "invalid ids")
+		return
+	}
+
+	limit, ok := parseLimitQuery(c, 10, 100)
+	if !ok {
+		return
+	}
+
+	// 通过 ?cursor= 继续上一页
+	var searchAfter []interface{}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if searchAfter, err = DecodeSearchCursor(cursor); err != nil {
+			respondError(c, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	opts := SearchTagsOptions{
+		TagIDs:      tagIDs,
+		Size:        limit,
+		SearchAfter: searchAfter,
+		Match:       reqBody.Match,
+		MinScore:    reqBody.MinScore,
+		Highlight:   reqBody.Highlight,
+		Mode:        mode,
+	}
+
+	// 传入 Cache-Control: no-cache 时跳过缓存重新搜索，结果仍然写入缓存
+	cacheKey := searchCacheKey(c.Request.Context(), searchKeyword, opts)
+	if !searchCacheDisabled(c) {
+		if cached := GetCachedSearch(c.Request.Context(), cacheKey); cached != nil {
+			c.Header(searchCacheHeader, "HIT")
+			respondOK(c, gin.H{
+				"matches":     cached.Matches,
+				"next_cursor": cached.NextCursor,
+				"degraded":    false,
+			})
+			return
+		}
+	}
+	c.Header(searchCacheHeader, "MISS")
+
+	result, degraded, err := SearchTags(c.Request.Context(), searchKeyword, opts)
+	if err != nil {
+		// 不把内部错误返回给客户端
+		log.Printf("SearchTagsErr: %s", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			respondError(c, http.StatusGatewayTimeout, "search timeout")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "search failed")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"matches": tags,
+	nextCursor := searchNextCursor(result, limit)
+
+	// 降级的结果不完整，不缓存
+	if !degraded {
+		SetCachedSearch(c.Request.Context(), cacheKey, &cachedSearchResult{Matches: result.Tags, NextCursor: nextCursor})
+	}
+
+	respondOK(c, gin.H{
+		"matches":     result.Tags,
+		"next_cursor": nextCursor,
+		"degraded":    degraded,
 	})
 }
 
 // EntityTag 实体关联的 Tag
 type EntityTag struct {
-	LinkID   int `db:"id" json:"-"`
-	EntityID int `db:"entity_id" json:"entity_id"`
-	TagID    int `db:"tag_id" json:"tag_id"`
+	LinkID     int    `db:"id" json:"-"`
+	TenantID   string `db:"tenant_id" json:"tenant_id"`
+	EntityType string `db:"entity_type" json:"entity_type"`
+	EntityID   int    `db:"entity_id" json:"entity_id"`
+	TagID      int    `db:"tag_id" json:"tag_id"`
+	// Metadata 关联的附加信息，没有时省略
+	Metadata LinkMetadata `db:"metadata" json:"metadata,omitempty"`
+	// Source 关联的来源，例如 api、manual、ml、import
+	Source string `db:"source" json:"source"`
+	// AddedBy 建立关联的操作人，未知时为空字符串
+	AddedBy string `db:"added_by" json:"added_by"`
+	// Weight 关联的相关度，取值范围为 [0, 1]，例如打标签模型的置信度，用于按标签查找实体时排序。
+	// 与只影响展示顺序的 position 无关
+	Weight float64 `db:"weight" json:"weight"`
+	// CreatedAt 建立关联的时间
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	// UpdatedAt 最后一次修改关联的附加信息、来源、权重或顺序的时间
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// DeletedAt 取消关联的时间，未取消时为 nil。取消后的关联在 LINK_RESTORE_WINDOW 内可以恢复
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+}
+
+// entityTagColumns 查询 entity_tag_tbl 时使用的字段列表，与 EntityTag 结构对应
+const entityTagColumns = "id, tenant_id, entity_type, entity_id, tag_id, metadata, source, added_by, weight, created_at, updated_at, deleted_at"
+
+// linkEntityResponse 关联标签到实体接口返回的数据，created 表示本次请求是否新建了关联，
+// tagCreated 表示本次请求是否新建或恢复了标签
+func linkEntityResponse(link *EntityTag, created, tagCreated bool) gin.H {
+	return gin.H{
+		"link_id":     link.LinkID,
+		"created":     created,
+		"tag_created": tagCreated,
+		"entity_type": link.EntityType,
+		"entity_id":   link.EntityID,
+		"tag_id":      link.TagID,
+		"source":      link.Source,
+		"added_by":    link.AddedBy,
+		"weight":      link.Weight,
+		"created_at":  link.CreatedAt,
+		"updated_at":  link.UpdatedAt,
+	}
 }
 
 // LinkEntityReqBody 关联标签到实体请求体
 type LinkEntityReqBody struct {
-	EntityID int `json:"entity_id"`
-	TagID    int `json:"tag_id"`
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	EntityID   int    `json:"entity_id"`
+	TagID      int    `json:"tag_id"`
+	// TagName 按名称指定标签，可以代替 tag_id，与 tag_id 同时传入时必须是同一个标签
+	TagName string `json:"tag_name"`
+	// CreateIfMissing 为 true 时 tag_name 对应的标签不存在则创建，已经被软删除则恢复，与关联在同一个事务中完成
+	CreateIfMissing bool `json:"create_if_missing"`
+	// Metadata 可选的关联附加信息，必须是 JSON 对象，关联已经存在时不会修改
+	Metadata LinkMetadata `json:"metadata"`
+	// Source 关联的来源，不传时为 api
+	Source string `json:"source"`
+	// AddedBy 建立关联的操作人，不传时使用 JWT 中的操作人
+	AddedBy string `json:"added_by"`
+	// OverwriteSource 关联已经存在时，为 true 才使用本次的 source 和 added_by 覆盖原来的值
+	OverwriteSource bool `json:"overwrite_source"`
+	// Weight 关联的相关度，取值范围为 [0, 1]，不传时为 1。关联已经存在时传入会直接更新
+	Weight *float64 `json:"weight"`
 }
 
-// OnLinkEntity 关联标签到实体请求体
+// OnLinkEntity 关联标签到实体请求体。新建关联时返回 201，关联已经存在时返回 200，created 为 false，
+// 返回的都是实际存在的关联。关联已经存在时默认保留原来的 source 和 added_by，
+// overwrite_source 为 true 时覆盖；传入的 weight 与原来的值不同时直接更新。
+// 标签可以通过 tag_name 指定，create_if_missing 为 true 时与创建标签接口使用相同的校验创建标签，
+// tag_created 表示标签是否为本次新建或恢复
+// @Summary 关联标签到实体
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param Idempotency-Key header string false "幂等键，有效期内重复提交会返回第一次请求的响应"
+// @Param body body LinkEntityReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{link_id=int,created=bool,tag_created=bool,entity_type=string,entity_id=int,tag_id=int,source=string,added_by=string,weight=number,created_at=string,updated_at=string}} "关联已经存在"
+// @Success 201 {object} APIResponse{data=object{link_id=int,created=bool,tag_created=bool,entity_type=string,entity_id=int,tag_id=int,source=string,added_by=string,weight=number,created_at=string,updated_at=string}} "新建了关联"
+// @Header 201 {string} X-ES-Index-Delayed "新建的标签写入 ES 失败、稍后重新上报时为 true"
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 409 {object} APIResponse "相同幂等键的请求还在处理中"
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Failure 422 {object} APIResponse{error=APIError{detail=object{count=int,limit=int}}} "超过实体的标签数量上限，或幂等键已经用于不同的请求体"
+// @Router /api/tag/link_entity [post]
 func OnLinkEntity(c *gin.Context) {
 	var reqBody LinkEntityReqBody
-	if bindErr := c.BindJSON(&reqBody); bindErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": bindErr.Error(),
-		})
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
 		return
 	}
 
-	if reqBody.EntityID == 0 || reqBody.TagID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": "request params error",
-		})
+	result, err := LinkEntity(c.Request.Context(), &reqBody)
+	if err != nil {
+		respondServerError(c, err)
 		return
 	}
 
-	// 查询是否已经关联过
-	var entityTag EntityTag
-	queryErr := mysqlDB.Get(
-		&entityTag,
-		"select id, entity_id, tag_id from entity_tag_tbl where entity_id = ? and tag_id = ?",
-		reqBody.EntityID, reqBody.TagID,
-	)
-
-	if queryErr == nil {
-		// 已经存在关联
-		c.JSON(http.StatusOK, gin.H{
-			"link_id": entityTag.LinkID,
-		})
+	if result.ESIndexDelayed {
+		c.Header(esIndexDelayedHeader, "true")
+	}
+	if !result.Created {
+		respondOK(c, linkEntityResponse(result.Link, false, result.TagCreated))
 		return
 	}
+	respondCreated(c, linkEntityResponse(result.Link, true, result.TagCreated))
+}
 
-	if queryErr != sql.ErrNoRows {
-		// 查询错误
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": queryErr.Error(),
-		})
-		return
+// LinkEntityOutcome 关联标签到实体的结果
+type LinkEntityOutcome struct {
+	// Link 实际存在的关联
+	Link *EntityTag
+	// Created 本次是否新建了关联
+	Created bool
+	// TagCreated 本次是否通过 tag_name 新建或恢复了标签
+	TagCreated bool
+	// ESIndexDelayed 新建的标签写入 ES 失败，稍后重新上报
+	ESIndexDelayed bool
+}
+
+// LinkEntity 校验请求并关联标签到实体，校验失败时返回 APIError，供 HTTP 和 GraphQL 接口共用，行为见 OnLinkEntity
+func LinkEntity(ctx context.Context, reqBody *LinkEntityReqBody) (*LinkEntityOutcome, error) {
+	if reqBody.EntityID == 0 || (reqBody.TagID == 0 && reqBody.TagName == "") {
+		return nil, newAPIError(http.StatusBadRequest, "request params error")
 	}
 
-	// 查询 Tag 信息
-	var tag Tag
-	queryErr = mysqlDB.Get(
-		&tag,
-		"select id, name from tag_tbl where id = ?",
-		reqBody.TagID,
-	)
-	if queryErr != nil {
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateLinkMetadata(reqBody.Metadata); err != nil {
+		return nil, err
+	}
+
+	source, err := validateLinkSource(reqBody.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	addedBy, err := validateLinkAddedBy(ctx, reqBody.AddedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	weight, err := validateLinkWeight(reqBody.Weight)
+	if err != nil {
+		return nil, err
+	}
+
+	// tagID 为 0 时标签不存在，在关联的事务中创建
+	tagID := reqBody.TagID
+	tagName := ""
+	if reqBody.TagName != "" {
+		// 与创建标签接口使用相同的校验，两种方式创建的标签名称一致
+		if tagName, err = validateTagName(reqBody.TagName); err != nil {
+			return nil, err
+		}
+
+		tagID, err = ResolveTagID(ctx, reqBody.TagID, tagName)
+		if err != nil {
+			var apiErr *APIError
+			if !reqBody.CreateIfMissing || !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+				return nil, err
+			}
+			// 需要创建的标签不可能是已有的 tag_id
+			if reqBody.TagID > 0 {
+				return nil, newAPIError(http.StatusBadRequest, "tag_id does not match tag_name")
+			}
+		}
+	}
+
+	tenantID := TenantIDFromContext(ctx)
+
+	// 按名称创建标签时不需要预先检查，标签已经被软删除时可能保留了原来的关联，由下面的 insert 处理
+	if tagID > 0 {
+		// 查询是否已经关联过
+		var entityTag EntityTag
+		queryErr := dbGet(
+			ctx,
+			&entityTag,
+			"select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and tag_id = ? and deleted_at is null",
+			tenantID, entityType, reqBody.EntityID, tagID,
+		)
+
+		if queryErr == nil {
+			// 已经存在关联，只有指定了 overwrite_source 才修改来源，传入了新的权重时直接更新
+			var update LinkUpdate
+			if reqBody.OverwriteSource && (entityTag.Source != source || entityTag.AddedBy != addedBy) {
+				update.Source, update.AddedBy = &source, &addedBy
+			}
+			if reqBody.Weight != nil && entityTag.Weight != weight {
+				update.Weight = &weight
+			}
+
+			link := &entityTag
+			if update.Source != nil || update.Weight != nil {
+				if link, err = UpdateLink(ctx, entityType, reqBody.EntityID, tagID, update); err != nil {
+					return nil, err
+				}
+			}
+
+			return &LinkEntityOutcome{Link: link}, nil
+		}
+
 		if queryErr != sql.ErrNoRows {
 			// 查询错误
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  http.StatusInternalServerError,
-				"message": queryErr.Error(),
-			})
-			return
+			return nil, queryErr
+		}
+
+		// 查询 Tag 是否存在，热门标签通常可以从缓存中读取
+		_, queryErr = GetTagByID(ctx, tagID)
+		if queryErr != nil {
+			if queryErr != sql.ErrNoRows {
+				// 查询错误
+				return nil, queryErr
+			}
+
+			// Tag 不存在
+			return nil, newAPIError(http.StatusNotFound, "tag not found")
+		}
+	}
+
+	// 插入关联记录，锁住实体后检查关联的标签数量，避免并发关联超过上限。
+	// 需要创建标签时在同一个事务中创建，关联失败时不会留下新建的标签
+	var link EntityTag
+	var tag *Tag
+	created, tagCreated := false, false
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		if tagID == 0 {
+			var err error
+			if tag, tagCreated, err = GetOrCreateTagTx(ctx, tx, tagName, nil); err != nil {
+				return err
+			}
+			tagID = tag.TagID
+		}
+
+		if err := lockEntityTx(ctx, tx, entityType, reqBody.EntityID); err != nil {
+			return err
+		}
+		if err := checkEntityTagLimitTx(ctx, tx, entityType, reqBody.EntityID, 1); err != nil {
+			return err
+		}
+
+		// 新关联的标签排在最后
+		position, err := nextEntityTagPositionTx(ctx, tx, entityType, reqBody.EntityID)
+		if err != nil {
+			return err
+		}
+
+		// 关联取消后还没有被清理时恢复原来的关联，保留原来的附加信息和 created_at，不使用本次请求的值
+		restored, err := restoreDeletedLinksTx(ctx, tx, entityType, []entityTagPlacement{{EntityID: reqBody.EntityID, TagID: tagID, Position: position}})
+		if err != nil {
+			return err
+		}
+		created = restored == 1
+
+		if !created {
+			execResult, execErr := txExec(
+				ctx, tx,
+				"insert into entity_tag_tbl (tenant_id, entity_type, entity_id, tag_id, metadata, position, source, added_by, weight) values (?, ?, ?, ?, ?, ?, ?, ?, ?) on duplicate key update id = id",
+				tenantID, entityType, reqBody.EntityID, tagID, reqBody.Metadata, position, source, addedBy, weight,
+			)
+			if execErr != nil {
+				return execErr
+			}
+
+			// 影响的行数为 0 时关联在查询之后已经被并发请求创建，此时 LastInsertId 不是该关联的 ID，
+			// 两种情况都重新查询实际的关联
+			affected, err := execResult.RowsAffected()
+			if err != nil {
+				return err
+			}
+			created = affected == 1
+		}
+		queryErr := txGet(
+			ctx, tx, &link,
+			"select "+entityTagColumns+" from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and tag_id = ?",
+			tenantID, entityType, reqBody.EntityID, tagID,
+		)
+		if queryErr != nil || !created {
+			return queryErr
 		}
 
-		// Tag 不存在
-		c.JSON(http.StatusNotFound, gin.H{
-			"status":  http.StatusNotFound,
-			"message": "tag not found",
+		if err := recordEntityTagChangesTx(ctx, tx, EntityTagChangeLink, entityType, entityTagRefs(reqBody.EntityID, []int{tagID})); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionEntityLink,
+			EntityType: AuditEntityTypeEntity,
+			EntityID:   reqBody.EntityID,
+			Metadata:   gin.H{"entity_type": entityType, "tag_ids": []int{tagID}, "metadata": reqBody.Metadata, "source": source, "weight": weight},
 		})
+	})
+	if txErr != nil {
+		// 插入失败
+		return nil, txErr
+	}
+
+	result := &LinkEntityOutcome{Link: &link, Created: created, TagCreated: tagCreated}
+
+	// 与创建标签接口一样同步添加到 ES 索引
+	if tagCreated {
+		result.ESIndexDelayed = ReportTagToES(ctx, config.ESIndex, tag)
+		PublishTagCreated(tag)
+	}
+	return result, nil
+}
+
+// EntityTagReqBody 查询实体关联的标签列表的请求体
+type EntityTagReqBody struct {
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	EntityID   int    `json:"entity_id"`
+	// Source 只返回该来源的关联，例如 manual 只返回人工关联的标签，不传时返回全部
+	Source string `json:"source"`
+	// Order 排序方式，position 按展示顺序，weight 按关联的权重从高到低，不传时为 position
+	Order string `json:"order"`
+}
+
+// OnEntityTags 查询实体关联的标签列表。返回响应体摘要的弱 ETag，关联、权重、顺序和标签字段的变化都会改变 ETag，
+// If-None-Match 相同时返回 304
+// @Summary 查询实体关联的标签列表
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param If-None-Match header string false "之前返回的 ETag"
+// @Param prefix query string false "只返回名称以该前缀开头的标签"
+// @Param body body EntityTagReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,tags=[]LinkedTag}}
+// @Success 304 "关联的标签没有变化"
+// @Header 200 {string} ETag "响应体摘要的弱 ETag"
+// @Failure 400 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/entity_tags [get]
+func OnEntityTags(c *gin.Context) {
+	var reqBody EntityTagReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
 		return
 	}
 
-	// 插入关联记录
-	execResult, execErr := mysqlDB.Exec(
-		"insert into entity_tag_tbl (entity_id, tag_id) values (?, ?) on duplicate key update created_at = now()",
-		reqBody.EntityID, reqBody.TagID,
-	)
-	if execErr != nil {
-		// 插入失败
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": execErr.Error(),
-		})
+	if reqBody.EntityID == 0 {
+		respondError(c, http.StatusBadRequest, "request params error")
 		return
 	}
 
-	linkID, err := execResult.LastInsertId()
+	entityType, err := validateEntityType(reqBody.EntityType)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": err.Error(),
-		})
+		respondServerError(c, err)
+		return
+	}
+
+	opts := EntityTagsOptions{Source: reqBody.Source, Order: reqBody.Order, Prefix: strings.TrimSpace(c.Query("prefix"))}
+	if err := validateEntityTagsOptions(opts); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tags, err := GetEntityTags(c.Request.Context(), entityType, reqBody.EntityID, opts)
+	if err != nil {
+		respondServerError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"link_id": int(linkID),
+	respondOKWithETag(c, gin.H{
+		"entity_type": entityType,
+		"tags":        tags,
 	})
 }
 
-// EntityTagReqBody 查询实体关联的标签列表的请求体
-type EntityTagReqBody struct {
-	EntityID int `json:"entity_id"`
+// maxEntityTagsBatch 批量查询实体关联的标签时每次请求最多可以传入的实体数量
+const maxEntityTagsBatch = 200
+
+// EntityTagsBatchReqBody 批量查询实体关联的标签列表的请求体
+type EntityTagsBatchReqBody struct {
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	EntityIDs  []int  `json:"entity_ids"`
+	// Source 只返回该来源的关联，不传时返回全部
+	Source string `json:"source"`
+	// Order 排序方式，position 或 weight，不传时为 position
+	Order string `json:"order"`
 }
 
-// OnEntityTags 查询实体关联的标签列表
-func OnEntityTags(c *gin.Context) {
-	var reqBody EntityTagReqBody
-	if bindErr := c.BindJSON(&reqBody); bindErr != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": bindErr.Error(),
-		})
+// OnEntityTagsBatch 批量查询多个实体关联的标签列表，用于列表页一次获取所有实体的标签，
+// 每个实体的标签顺序与查询单个实体时相同，没有关联标签的实体对应空数组
+// @Summary 批量查询实体关联的标签列表
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body EntityTagsBatchReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,entities=map[string][]LinkedTag}}
+// @Failure 400 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/entity_tags/batch [post]
+func OnEntityTagsBatch(c *gin.Context) {
+	var reqBody EntityTagsBatchReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
 		return
 	}
 
-	if reqBody.EntityID == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": "request params error",
-		})
+	if len(reqBody.EntityIDs) == 0 {
+		respondError(c, http.StatusBadRequest, "entity_ids is required")
+		return
+	}
+	if len(reqBody.EntityIDs) > maxEntityTagsBatch {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("too many entity_ids, max %d", maxEntityTagsBatch))
 		return
 	}
+	for _, entityID := range reqBody.EntityIDs {
+		if entityID <= 0 {
+			respondError(c, http.StatusBadRequest, "invalid entity_ids")
+			return
+		}
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	opts := EntityTagsOptions{Source: reqBody.Source, Order: reqBody.Order}
+	if err := validateEntityTagsOptions(opts); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	entitiesTags, err := GetEntitiesTags(c.Request.Context(), entityType, reqBody.EntityIDs, opts)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"entity_type": entityType,
+		"entities":    entitiesTags,
+	})
+}
+
+// LinkedTag 实体关联的标签，Metadata 为关联的附加信息，没有时省略，Source、AddedBy 和 Weight 为关联的来源、操作人和权重
+type LinkedTag struct {
+	*Tag
+	Metadata LinkMetadata `json:"metadata,omitempty"`
+	Source   string       `json:"source"`
+	AddedBy  string       `json:"added_by"`
+	Weight   float64      `json:"weight"`
+}
+
+// 实体关联的标签列表的排序方式
+const (
+	// EntityTagsOrderPosition 按展示顺序 position 排列
+	EntityTagsOrderPosition = "position"
+	// EntityTagsOrderWeight 按关联的权重从高到低排列，权重相同时按 position
+	EntityTagsOrderWeight = "weight"
+)
+
+// EntityTagsOptions 查询实体关联的标签列表的选项
+type EntityTagsOptions struct {
+	// Source 不为空时只返回该来源的关联
+	Source string
+	// Order 排序方式，为空时按 position
+	Order string
+	// Prefix 不为空时只返回名称以该前缀开头的标签，比较前按 NormalizeTagName 规范化
+	Prefix string
+}
+
+// validateEntityTagsOptions 校验来源和排序方式，不合法时返回 400 APIError
+func validateEntityTagsOptions(opts EntityTagsOptions) error {
+	if opts.Source != "" && !linkSourcePattern.MatchString(opts.Source) {
+		return newAPIError(http.StatusBadRequest, "invalid source")
+	}
+	if opts.Order != "" && opts.Order != EntityTagsOrderPosition && opts.Order != EntityTagsOrderWeight {
+		return newAPIError(http.StatusBadRequest, "invalid order")
+	}
+	if utf8.RuneCountInString(opts.Prefix) > maxTagNameLength {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("prefix too long, max %d characters", maxTagNameLength))
+	}
+	return nil
+}
+
+// GetEntityTags 查询实体关联的未删除标签和关联的附加信息，默认按 position 排列，position 相同时按关联的先后顺序
+func GetEntityTags(ctx context.Context, entityType string, entityID int, opts EntityTagsOptions) ([]*LinkedTag, error) {
+	entitiesTags, err := GetEntitiesTags(ctx, entityType, []int{entityID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return entitiesTags[entityID], nil
+}
+
+// GetEntitiesTags 使用两次查询获取多个实体关联的未删除标签，返回实体 ID 到标签列表的映射，
+// 每个实体的标签顺序与 GetEntityTags 相同，没有关联标签的实体对应空列表
+func GetEntitiesTags(ctx context.Context, entityType string, entityIDs []int, opts EntityTagsOptions) (map[int][]*LinkedTag, error) {
+	tenantID := TenantIDFromContext(ctx)
+
+	result := make(map[int][]*LinkedTag, len(entityIDs))
+	for _, entityID := range entityIDs {
+		result[entityID] = []*LinkedTag{}
+	}
+	if len(entityIDs) == 0 {
+		return result, nil
+	}
+
+	query := "select " + entityTagColumns + " from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id in (?) and deleted_at is null"
+	args := []interface{}{tenantID, entityType, entityIDs}
+	if opts.Source != "" {
+		query += " and source = ?"
+		args = append(args, opts.Source)
+	}
+	if opts.Order == EntityTagsOrderWeight {
+		query += " order by entity_id, weight desc, position, id"
+	} else {
+		query += " order by entity_id, position, id"
+	}
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
 
 	entityTags := []*EntityTag{}
-	selectErr := mysqlDB.Select(&entityTags, "select id, entity_id, tag_id from entity_tag_tbl where entity_id = ? order by id", reqBody.EntityID)
+	selectErr := dbSelect(ctx, &entityTags, query, args...)
 	if selectErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": selectErr.Error(),
-		})
-		return
+		return nil, selectErr
 	}
 
 	if len(entityTags) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"tags": []*Tag{},
-		})
-		return
+		return result, nil
 	}
 
+	// 多个实体关联同一个标签时只查询一次
 	tagIDs := make([]int, 0, len(entityTags))
-	tagIndex := make(map[int]int, len(entityTags))
-	for index, entityTag := range entityTags {
-		tagIndex[entityTag.TagID] = index
-		tagIDs = append(tagIDs, entityTag.TagID)
+	seenTagIDs := make(map[int]bool, len(entityTags))
+	for _, entityTag := range entityTags {
+		if !seenTagIDs[entityTag.TagID] {
+			seenTagIDs[entityTag.TagID] = true
+			tagIDs = append(tagIDs, entityTag.TagID)
+		}
+	}
+
+	queryTags := "select " + tagColumns + " from tag_tbl where tenant_id = ? and id in (?) and deleted_at is null"
+	args = []interface{}{tenantID, tagIDs}
+	if opts.Prefix != "" {
+		queryTags += " and name like ?"
+		args = append(args, likeEscaper.Replace(NormalizeTagName(opts.Prefix))+"%")
 	}
 
-	queryTags, args, err := sqlx.In("select id, name from tag_tbl where id in (?)", tagIDs)
+	queryTags, args, err = sqlx.In(queryTags, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": err.Error(),
-		})
-		return
+		return nil, err
 	}
 
 	tags := []*Tag{}
-	selectErr = mysqlDB.Select(&tags, queryTags, args...)
+	selectErr = dbSelect(ctx, &tags, queryTags, args...)
 	if selectErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  http.StatusInternalServerError,
-			"message": selectErr.Error(),
-		})
-		return
+		return nil, selectErr
 	}
 
-	sort.Slice(tags, func(i, j int) bool {
-		return tagIndex[tags[i].TagID] < tagIndex[tags[j].TagID]
-	})
+	tagsByID := make(map[int]*Tag, len(tags))
+	for _, tag := range tags {
+		tagsByID[tag.TagID] = tag
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"tags": tags,
-	})
+	// 关联已经按实体和排序方式排好，跳过关联到已删除标签或名称不匹配前缀的记录
+	for _, entityTag := range entityTags {
+		tag, ok := tagsByID[entityTag.TagID]
+		if !ok {
+			continue
+		}
+		result[entityTag.EntityID] = append(result[entityTag.EntityID], &LinkedTag{
+			Tag:      tag,
+			Metadata: entityTag.Metadata,
+			Source:   entityTag.Source,
+			AddedBy:  entityTag.AddedBy,
+			Weight:   entityTag.Weight,
+		})
+	}
+	return result, nil
 }
 
-func main() {
+// NewRouter 创建路由
+func NewRouter() *gin.Engine {
 	r := gin.Default()
+	r.Use(otelgin.Middleware(tracingServiceName))
+	// 跨域预检请求没有对应的路由，需要在全局处理
+	r.Use(CORSMiddleware(config.CORS))
+
+	// 业务接口都需要通过 X-Tenant-Id 请求头指定租户，请求体在绑定之前统一检查大小和 JSON 嵌套层数
+	api := r.Group("/api", MaxBytesMiddleware(config.MaxBodyBytes), JSONDepthMiddleware(config.MaxJSONDepth), TenantMiddleware())
+
+	// 查询接口不需要认证
+	api.GET("/tag/search", OnSearchTag)
+	api.GET("/tag/stream", OnTagStream)
+	api.GET("/tag/suggest", OnSuggestTags)
+	api.GET("/tag/entity_tags", OnEntityTags)
+	api.POST("/tag/entity_tags/batch", OnEntityTagsBatch)
+	api.GET("/tag/entity_tags/stream", OnEntityTagsStream)
+	api.GET("/entity_tags/changes", OnEntityTagChanges)
+	api.GET("/entity_tags/diff", OnEntityTagsDiff)
+	api.GET("/entity_tags/export", OnExportEntityTags)
+	api.GET("/tag/entity_count", OnEntityTagCount)
+	api.POST("/tag/entity_count_batch", OnEntityTagCountBatch)
+	api.GET("/tags", OnListTags)
+	api.GET("/tags/export", OnExportTags)
+	api.GET("/tag/by_name", OnGetTagByName)
+	api.GET("/tag/:id", OnGetTag)
+	api.GET("/tag/:id/history", OnTagHistory)
+	api.GET("/tag/:id/metadata", OnListTagMetadata)
+	api.GET("/tag/:id/link_audit", OnTagLinkAudit)
+	api.GET("/tag/:id/entities", OnTagEntities)
+	api.GET("/tag/:id/entities/count", OnTagEntitiesCount)
+	api.GET("/tag/:id/entities/recent", OnRecentTagEntities)
+	api.GET("/tag/:id/related", OnRelatedTags)
+	api.POST("/tags/batch_get", OnGetTagsBatch)
+	api.POST("/entities/search_by_tags", OnSearchEntitiesByTags)
+	api.GET("/entity/:id/tag_suggestions", OnEntityTagSuggestions)
+	api.GET("/entity/:id/tag_audit", OnEntityTagAudit)
+	// 查询不需要认证，修改在 resolver 中检查角色
+	api.POST("/graphql", OptionalJWTMiddleware(config.JWTSecret), OnGraphQL)
+
+	// 修改数据的接口需要通过 JWT 认证，并且拥有对应的角色
+	auth := JWTMiddleware(config.JWTSecret)
+	write := api.Group("", auth, RequireRole(RoleTagWrite))
+	write.POST("/tag", IdempotencyMiddleware(), OnNewTag)
+	write.POST("/tag/link_entity", IdempotencyMiddleware(), OnLinkEntity)
+	write.POST("/tag/link_entity/batch", OnLinkEntityBatch)
+	write.PUT("/entity/:id/tags", OnPutEntityTags)
+	write.POST("/entity_tag/:link_id/restore", OnRestoreEntityLink)
+	write.PUT("/entity/:id/tags/:tag_id/metadata", OnPutLinkMetadata)
+	write.PUT("/entity/:id/tags/order", OnPutEntityTagsOrder)
+	write.PATCH("/tag/:id", OnPatchTag)
+	write.POST("/tag/:id/rename", OnRenameTag)
+	write.POST("/tag/:id/synonyms", OnAddTagSynonym)
+	write.DELETE("/tag/:id/synonyms/:synonym", OnDeleteTagSynonym)
+	write.PUT("/tag/:id/metadata/:key", OnPutTagMetadata)
+	write.DELETE("/tag/:id/metadata/:key", OnDeleteTagMetadata)
+
+	// 删除标签、清空实体的标签和批量取消关联需要 tag:delete 角色
+	api.DELETE("/tag/:id", auth, RequireRole(RoleTagDelete), OnDeleteTag)
+	api.DELETE("/tag", auth, RequireRole(RoleTagDelete), OnDeleteTagByName)
+	api.DELETE("/entity/:id/tags", auth, RequireRole(RoleTagDelete), OnDeleteEntityTags)
+	api.POST("/tag/batch_unlink", auth, RequireRole(RoleTagDelete), OnBatchUnlinkTag)
+
+	// 运维接口
+	api.POST("/admin/reindex", auth, RequireRole(RoleAdmin), OnReindexTags)
+	api.POST("/admin/tag/merge", auth, RequireRole(RoleAdmin), OnMergeTags)
+	api.POST("/admin/entity_tags/purge", auth, RequireRole(RoleAdmin), OnPurgeDeletedLinks)
+	api.POST("/admin/entity_tags/orphans", auth, RequireRole(RoleAdmin), OnScanOrphanLinks)
+	api.GET("/admin/tag/export", auth, RequireRole(RoleAdmin), OnAdminExportTags)
+
+	// 导入和批量接口使用单独的请求体大小上限
+	r.POST("/api/tag/import", MaxBytesMiddleware(ImportMaxBodyBytes), TenantMiddleware(), auth, RequireRole(RoleTagWrite), OnImportTagsCSV)
+	r.POST("/api/tags/import", MaxBytesMiddleware(ImportMaxBodyBytes), TenantMiddleware(), auth, RequireRole(RoleTagWrite), OnImportTags)
+	r.POST("/api/entity_tags/import", MaxBytesMiddleware(EntityTagsImportMaxBodyBytes), TenantMiddleware(), auth, RequireRole(RoleTagWrite), OnImportEntityTags)
+	r.POST("/api/tag/:id/link_entities", MaxBytesMiddleware(BulkMaxBodyBytes), JSONDepthMiddleware(config.MaxJSONDepth), TenantMiddleware(), auth, RequireRole(RoleTagWrite), OnLinkEntitiesToTag)
+
+	r.GET("/api/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/readyz", OnReadyz)
+
+	return r
+}
+
+// @title Tag API
+// @version 1.0
+// @description 基于 Go + MySQL + ES 实现的 Tag API 服务，所有接口都使用统一的 APIResponse 响应结构
+// @host localhost:9800
+// @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+func main() {
+	migrateUp := flag.Bool("migrate", false, "启动服务前执行未完成的数据库迁移")
+	migrateDown := flag.Int("migrate-down", 0, "回滚最近的 N 个数据库迁移后退出，仅用于开发环境")
+	flag.Parse()
+
+	// 读取配置
+	conf, err := LoadConfig()
+	if err != nil {
+		panic(err)
+	}
+	config = conf
+
+	if *migrateDown > 0 {
+		if err := MigrateDown(*migrateDown); err != nil {
+			log.Fatalf("MigrateDownErr: %s", err)
+		}
+		return
+	}
+
+	// 迁移需要在创建连接池之前完成
+	if *migrateUp {
+		if err := MigrateUp(); err != nil {
+			log.Fatalf("MigrateUpErr: %s", err)
+		}
+	}
+
+	shutdownTracing, err := SetupTracing(context.Background())
+	if err != nil {
+		log.Fatalf("SetupTracingErr: %s", err)
+	}
 
-	r.POST("/api/tag", OnNewTag)
-	r.GET("/api/tag/search", OnSearchTag)
-	r.POST("/api/tag/link_entity", OnLinkEntity)
-	r.GET("/api/tag/entity_tags", OnEntityTags)
+	setupClients()
 
-	r.Run(":9800")
+	// 迁移只能把已有的名称转换为小写，NFC 规范化在连接池创建之后完成
+	if *migrateUp {
+		report, err := NormalizeLegacyTagNames(context.Background())
+		if err != nil {
+			log.Fatalf("NormalizeLegacyTagNamesErr: %s", err)
+		}
+		log.Printf("NormalizeLegacyTagNamesOk: scanned %d, updated %d, history updated %d, collisions %d",
+			report.Scanned, report.Updated, report.HistoryUpdated, len(report.Collisions))
+	}
+
+	StartIdempotencyPurger(time.Hour)
+	StartTagExpiryWorker(time.Minute)
+	StartLinkPurgeWorker(10 * time.Minute)
+	outboxWorker := StartESOutboxWorker(esOutboxInterval)
+	StartTagStreamHub()
+
+	pprofSrv := StartPprofServer(config.PprofAddr)
+	grpcSrv := StartGRPCServer(config.GRPCAddr)
+
+	srv := &http.Server{Addr: ":9800", Handler: NewRouter()}
+	srv.RegisterOnShutdown(CloseSuggestStreams)
+	srv.RegisterOnShutdown(CloseEntityTagsStreams)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("ListenErr: %s", err)
+		}
+	}()
+
+	// 收到退出信号后停止接收新请求，等待进行中的请求完成，并向 WebSocket 连接发送关闭帧
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	sig := <-quit
+	log.Printf("Shutdown: %s", sig)
+
+	// 所有步骤共用同一个截止时间
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("ShutdownErr: %s", err)
+	} else {
+		log.Printf("ShutdownHTTPServerOk")
+	}
+	if grpcSrv != nil {
+		if err := StopGRPCServer(ctx, grpcSrv); err != nil {
+			log.Printf("[WARN] ShutdownGRPCServerTimeout: %s", err)
+		} else {
+			log.Printf("ShutdownGRPCServerOk")
+		}
+	}
+	if pprofSrv != nil {
+		// 正在采集的 profile 可能持续较长时间，不等待完成
+		pprofSrv.Close()
+	}
+	CloseTagStreams(ctx)
+	log.Printf("ShutdownTagStreamsOk")
+	// 请求都已经结束，不会再写入新的 outbox 记录
+	if err := outboxWorker.Stop(ctx); err != nil {
+		log.Printf("[WARN] ShutdownESOutboxTimeout: %s", err)
+	} else {
+		log.Printf("ShutdownESOutboxOk")
+	}
+	closeRedis()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("ShutdownTracingErr: %s", err)
+	}
+	log.Printf("ShutdownOk")
 }
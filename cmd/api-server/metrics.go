@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// esIndexRetries 写入标签文档时重试的次数
+var esIndexRetries = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "tag_server_es_index_retries_total",
+	Help: "The number of retried Elasticsearch index requests.",
+})
+
+// esIndexFailures 重试后仍然写入失败的标签文档数量，这些标签在 ESOutboxWorker 重新上报成功之前搜索不到
+var esIndexFailures = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "tag_server_es_index_failures_total",
+	Help: "The number of tag documents that failed to be indexed after all retries.",
+})
+
+// RegisterESMetrics 注册 ES 写入相关的指标
+func RegisterESMetrics() {
+	prometheus.MustRegister(esIndexRetries, esIndexFailures)
+}
+
+// tagCacheHits 按 ID 查询标签时命中进程内缓存的次数
+var tagCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "tag_server_tag_cache_hits_total",
+	Help: "The number of tag lookups served from the in-process tag cache.",
+})
+
+// tagCacheMisses 按 ID 查询标签时没有命中进程内缓存的次数，包括缓存过期
+var tagCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "tag_server_tag_cache_misses_total",
+	Help: "The number of tag lookups that missed the in-process tag cache.",
+})
+
+// RegisterTagCacheMetrics 注册标签缓存相关的指标
+func RegisterTagCacheMetrics() {
+	prometheus.MustRegister(
+		tagCacheHits,
+		tagCacheMisses,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "tag_server_tag_cache_entries",
+			Help: "The number of tags currently held in the in-process tag cache.",
+		}, func() float64 {
+			tagCache.Lock()
+			defer tagCache.Unlock()
+			return float64(tagCache.lru.Len())
+		}),
+	)
+}
+
+// RegisterDBMetrics 注册 MySQL 连接池相关的指标
+func RegisterDBMetrics(db *sqlx.DB) {
+	prometheus.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "tag_server_db_open_connections",
+			Help: "The number of established connections both in use and idle.",
+		}, func() float64 {
+			return float64(db.Stats().OpenConnections)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "tag_server_db_in_use_connections",
+			Help: "The number of connections currently in use.",
+		}, func() float64 {
+			return float64(db.Stats().InUse)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "tag_server_db_idle_connections",
+			Help: "The number of idle connections.",
+		}, func() float64 {
+			return float64(db.Stats().Idle)
+		}),
+	)
+}
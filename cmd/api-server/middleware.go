@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultMaxBodyBytes 默认的请求体大小上限 (1 MB)，可以通过 MAX_BODY_BYTES 修改
+	DefaultMaxBodyBytes int64 = 1 << 20
+	// DefaultMaxJSONDepth 默认的 JSON 请求体最大嵌套层数，可以通过 MAX_JSON_DEPTH 修改
+	DefaultMaxJSONDepth = 32
+	// BulkMaxBodyBytes 批量接口的请求体大小上限 (10 MB)
+	BulkMaxBodyBytes int64 = 10 << 20
+	// ImportMaxBodyBytes 导入 CSV 文件的请求体大小上限 (5 MB)
+	ImportMaxBodyBytes int64 = 5 << 20
+	// EntityTagsImportMaxBodyBytes 导入实体关联的请求体大小上限 (200 MB)，约可以容纳数百万行，请求体按行流式处理
+	EntityTagsImportMaxBodyBytes int64 = 200 << 20
+)
+
+// MaxBytesMiddleware 限制请求体的大小，超出 limit 时返回 413
+func MaxBytesMiddleware(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			respondError(c, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// JSONDepthMiddleware 读取整个请求体并检查 JSON 的嵌套层数，超过 maxDepth 时返回 400，maxDepth 为 0 时不检查。
+// 需要放在 MaxBytesMiddleware 之后，读取的请求体大小受其限制。检查只统计字符串之外的括号，
+// 不校验 JSON 是否合法，非法的请求体仍由绑定请求体时报错
+func JSONDepthMiddleware(maxDepth int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxDepth <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, bindErrorStatus(err), err.Error())
+			return
+		}
+		if jsonDepthExceeds(body, maxDepth) {
+			respondError(c, http.StatusBadRequest, "request body nested too deeply")
+			return
+		}
+
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+// jsonDepthExceeds 返回 data 中 JSON 对象和数组的嵌套层数是否超过 maxDepth，字符串中的括号不计入
+func jsonDepthExceeds(data []byte, maxDepth int) bool {
+	depth := 0
+	inString, escaped := false, false
+	for _, b := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return true
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return false
+}
+
+// isRequestBodyTooLarge 判断读取请求体时的错误是否为超出 http.MaxBytesReader 的上限
+func isRequestBodyTooLarge(err error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(err, &mbe)
+}
+
+// bindErrorStatus 根据绑定请求体时的错误返回对应的 HTTP 状态码
+func bindErrorStatus(err error) int {
+	if isRequestBodyTooLarge(err) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
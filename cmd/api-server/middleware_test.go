@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBindErrorStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"max bytes", &http.MaxBytesError{Limit: 10}, http.StatusRequestEntityTooLarge},
+		{"wrapped max bytes", fmt.Errorf("read body: %w", &http.MaxBytesError{Limit: 10}), http.StatusRequestEntityTooLarge},
+		{"same message", errors.New("http: request body too large"), http.StatusBadRequest},
+		{"syntax error", errors.New("unexpected EOF"), http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := bindErrorStatus(tc.err); got != tc.want {
+				t.Errorf("status = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMaxBytesMiddlewareStreamingBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.POST("/bind", MaxBytesMiddleware(16), func(c *gin.Context) {
+		var reqBody map[string]string
+		if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+			respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+			return
+		}
+		respondOK(c, reqBody)
+	})
+	r.POST("/depth", MaxBytesMiddleware(16), JSONDepthMiddleware(DefaultMaxJSONDepth), func(c *gin.Context) {
+		respondOK(c, nil)
+	})
+
+	for _, path := range []string{"/bind", "/depth"} {
+		t.Run(path, func(t *testing.T) {
+			// 没有 Content-Length 的请求体只能在读取时由 http.MaxBytesReader 发现超出上限
+			body := ioutil.NopCloser(strings.NewReader(`{"name": "` + strings.Repeat("a", 64) + `"}`))
+			req := httptest.NewRequest(http.MethodPost, path, body)
+			req.ContentLength = -1
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("status = %d, want %d, body: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body.String())
+			}
+		})
+	}
+}
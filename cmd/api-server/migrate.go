@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratemysql "github.com/golang-migrate/migrate/v4/database/mysql"
+	_ "github.com/golang-migrate/migrate/v4/source/file" // file source driver
+)
+
+// newMigrate 使用单独的数据库连接创建迁移实例，迁移文件从 config.MigrationsDir 读取
+func newMigrate() (*migrate.Migrate, error) {
+	db, err := sql.Open("mysql", mysqlDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := migratemysql.WithInstance(db, &migratemysql.Config{})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return migrate.NewWithDatabaseInstance("file://"+config.MigrationsDir, "mysql", driver)
+}
+
+// MigrateUp 执行所有未完成的数据库迁移
+func MigrateUp() error {
+	m, err := newMigrate()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+
+	logMigrateVersion(m)
+	return nil
+}
+
+// MigrateDown 回滚最近的 steps 个数据库迁移
+func MigrateDown(steps int) error {
+	m, err := newMigrate()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Steps(-steps); err != nil {
+		return err
+	}
+
+	logMigrateVersion(m)
+	return nil
+}
+
+// logMigrateVersion 输出当前的迁移版本
+func logMigrateVersion(m *migrate.Migrate) {
+	version, dirty, err := m.Version()
+	if err == migrate.ErrNilVersion {
+		log.Printf("MigrateOk: no version")
+		return
+	}
+	if err != nil {
+		log.Printf("MigrateVersionErr: %s", err)
+		return
+	}
+	log.Printf("MigrateOk: version %d, dirty %t", version, dirty)
+}
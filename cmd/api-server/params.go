@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseIDParam 解析路径中的 ID 参数，不合法时返回 400 并返回 false
+func parseIDParam(c *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
+
+// parseIntQuery 解析整数类型的查询参数，未传入时返回 defaultValue，不合法时返回 400 并返回 false
+func parseIntQuery(c *gin.Context, name string, defaultValue int) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		respondError(c, http.StatusBadRequest, "invalid "+name)
+		return 0, false
+	}
+	return n, true
+}
+
+// parseLimitQuery 解析分页大小参数 limit，超过 maxLimit 时返回 400
+func parseLimitQuery(c *gin.Context, defaultLimit, maxLimit int) (int, bool) {
+	limit, ok := parseIntQuery(c, "limit", defaultLimit)
+	if !ok {
+		return 0, false
+	}
+	if limit == 0 || limit > maxLimit {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+		return 0, false
+	}
+	return limit, true
+}
+
+// parseIDList 解析以逗号分隔的 ID 列表，例如 1,2,3，value 为空时返回 nil
+func parseIDList(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	ids := make([]int, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id: %s", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
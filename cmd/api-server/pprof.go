@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/pprof"
+)
+
+// NewPprofHandler 返回挂载了 /debug/pprof 下标准 pprof 接口的 handler，
+// 使用单独的 ServeMux，不会暴露 http.DefaultServeMux 上注册的其它接口
+func NewPprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// StartPprofServer 在 addr 上启动 pprof 服务，与 API 使用不同的端口，不经过租户和认证中间件。
+// addr 为空时不启动并返回 nil
+func StartPprofServer(addr string) *http.Server {
+	if addr == "" {
+		return nil
+	}
+
+	srv := &http.Server{Addr: addr, Handler: NewPprofHandler()}
+	go func() {
+		log.Printf("PprofListen: %s", addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("PprofListenErr: %s", err)
+		}
+	}()
+	return srv
+}
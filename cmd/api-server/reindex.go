@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/gin-gonic/gin"
+)
+
+// reindexBatchSize 重建索引时每批从 MySQL 读取并批量写入 ES 的标签数量
+const reindexBatchSize = 500
+
+// ReindexProgress 重建索引的进度，流式输出时每批输出一行，最后一行的 done 为 true
+type ReindexProgress struct {
+	Indexed    int   `json:"indexed"`
+	Failed     int   `json:"failed"`
+	LastTagID  int   `json:"last_tag_id"`
+	DurationMS int64 `json:"duration_ms"`
+	Done       bool  `json:"done"`
+}
+
+// ReindexTags 把当前租户所有未删除的标签及其同义词按 ID 顺序分批写入 ES 的 index 索引，index 不存在时按 esTagIndexBody 创建。
+// 每完成一批调用一次 onBatch。单个文档写入失败只计入 Failed，ES 请求本身失败时中断并返回错误
+func ReindexTags(ctx context.Context, index string, onBatch func(*ReindexProgress)) (*ReindexProgress, error) {
+	start := time.Now()
+	progress := &ReindexProgress{}
+
+	if err := EnsureESTagIndex(ctx, index); err != nil {
+		return progress, err
+	}
+
+	for {
+		var tags []*Tag
+		queryErr := dbSelect(
+			ctx, &tags,
+			"select "+tagColumns+" from tag_tbl where tenant_id = ? and id > ? and deleted_at is null order by id limit ?",
+			TenantIDFromContext(ctx), progress.LastTagID, reindexBatchSize,
+		)
+		if queryErr != nil {
+			return progress, queryErr
+		}
+		if len(tags) == 0 {
+			break
+		}
+		if err := LoadTagSynonyms(ctx, tags); err != nil {
+			return progress, err
+		}
+		if err := LoadTagMetadata(ctx, tags); err != nil {
+			return progress, err
+		}
+
+		failed, bulkErr := bulkIndexTags(ctx, index, tags)
+		if bulkErr != nil {
+			return progress, bulkErr
+		}
+
+		progress.Indexed += len(tags) - len(failed)
+		progress.Failed += len(failed)
+		progress.LastTagID = tags[len(tags)-1].TagID
+		progress.DurationMS = time.Since(start).Milliseconds()
+		if onBatch != nil {
+			onBatch(progress)
+		}
+
+		if len(tags) < reindexBatchSize {
+			break
+		}
+	}
+
+	// 批量写入时不逐批刷新，全部写完后刷新一次，使新文档可以被搜索到
+	refreshCtx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	resp, err := esClient.Indices.Refresh(
+		esClient.Indices.Refresh.WithContext(refreshCtx),
+		esClient.Indices.Refresh.WithIndex(index),
+	)
+	if err != nil {
+		return progress, err
+	}
+	resp.Body.Close()
+
+	progress.DurationMS = time.Since(start).Milliseconds()
+	progress.Done = true
+	return progress, nil
+}
+
+// esBulkResponse ES bulk 接口的响应中用到的部分，只发送 index 操作
+type esBulkResponse struct {
+	// Errors 为 true 时至少有一个文档写入失败
+	Errors bool `json:"errors"`
+	Items  []struct {
+		Index struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"index"`
+	} `json:"items"`
+}
+
+// bulkIndexTags 使用 bulk 接口把 tags 写入 ES 的 index 索引，返回写入失败的标签 ID
+func bulkIndexTags(ctx context.Context, index string, tags []*Tag) ([]int, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, tag := range tags {
+		action := map[string]map[string]string{"index": {"_id": strconv.Itoa(tag.TagID)}}
+		if err := encoder.Encode(action); err != nil {
+			return nil, err
+		}
+		if err := encoder.Encode(tag); err != nil {
+			return nil, err
+		}
+	}
+
+	req := esapi.BulkRequest{
+		Index: index,
+		Body:  &body,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+
+	resp, err := req.Do(ctx, esClient)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return nil, errors.New(resp.String())
+	}
+
+	var bulkResp esBulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&bulkResp); err != nil {
+		return nil, err
+	}
+
+	// errors 为 false 时所有文档都写入成功，不需要逐个检查
+	if !bulkResp.Errors {
+		return nil, nil
+	}
+
+	failed := []int{}
+	for _, item := range bulkResp.Items {
+		if item.Index.Status >= http.StatusBadRequest {
+			tagID, _ := strconv.Atoi(item.Index.ID)
+			failed = append(failed, tagID)
+			reason := ""
+			if item.Index.Error != nil {
+				reason = item.Index.Error.Reason
+			}
+			log.Printf("ESBulkIndexItemErr: id=%s status=%d reason=%s", item.Index.ID, item.Index.Status, reason)
+		}
+	}
+	return failed, nil
+}
+
+// OnReindexTags 把当前租户所有未删除的标签从 MySQL 重新写入 ES，用于索引被删除或重建之后恢复数据，
+// 也用于写入使用新 mapping 的索引，传入 alias 时完成后把别名切换到该索引。索引由所有租户共享，
+// 需要在最后一个租户写入新索引时才传入 alias。
+// 请求头 Accept 为 application/x-ndjson 时每完成一批输出一行进度，否则完成后返回汇总结果
+// @Summary 重建标签的 ES 索引
+// @Tags admin
+// @Produce json
+// @Produce application/x-ndjson
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param index query string false "写入的索引，默认为 ES_INDEX，不存在时自动创建"
+// @Param alias query string false "完成后切换到 index 的别名，例如 ES_SEARCH_INDEX"
+// @Security BearerAuth
+// @Success 200 {object} APIResponse{data=ReindexProgress}
+// @Failure 400 {object} APIResponse
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/admin/reindex [post]
+func OnReindexTags(c *gin.Context) {
+	index := c.DefaultQuery("index", config.ESIndex)
+	alias := c.Query("alias")
+	if alias == index {
+		respondError(c, http.StatusBadRequest, "alias must be different from index")
+		return
+	}
+
+	// 重建的时间不固定，不限制整体耗时，每批的 MySQL 查询和 ES 请求仍然使用 config.QueryTimeout
+	ctx := c.Request.Context()
+
+	if !strings.Contains(c.GetHeader("Accept"), "application/x-ndjson") {
+		progress, err := ReindexTags(ctx, index, nil)
+		if err == nil && alias != "" {
+			err = SwitchESAlias(ctx, alias, index)
+		}
+		if err != nil {
+			log.Printf("ReindexTagsErr: %s", err)
+			respondServerError(c, err)
+			return
+		}
+
+		respondOK(c, progress)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	// 响应已经开始写出，之后的错误作为最后一行输出
+	encoder := json.NewEncoder(c.Writer)
+	progress, err := ReindexTags(ctx, index, func(progress *ReindexProgress) {
+		if err := encoder.Encode(progress); err != nil {
+			log.Printf("ReindexTagsErr: %s", err)
+			return
+		}
+		c.Writer.Flush()
+	})
+	if err == nil && alias != "" {
+		err = SwitchESAlias(ctx, alias, index)
+	}
+	if err != nil {
+		log.Printf("ReindexTagsErr: %s", err)
+		encoder.Encode(gin.H{"error": fmt.Sprintf("reindex failed after tag %d", progress.LastTagID)})
+		c.Writer.Flush()
+		return
+	}
+
+	encoder.Encode(progress)
+	c.Writer.Flush()
+}
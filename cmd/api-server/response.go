@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIError 接口错误信息
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Detail 便于客户端处理错误的附加信息，例如版本冲突时的最新数据
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+// Error 实现 error 接口，便于在内部逻辑中直接返回 APIError
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// newAPIError 创建指定状态码的 APIError
+func newAPIError(code int, msg string) *APIError {
+	return &APIError{Code: code, Message: msg}
+}
+
+// APIResponse 统一的响应结构，data 和 error 只会有一个不为 null
+type APIResponse struct {
+	Data  interface{} `json:"data"`
+	Error *APIError   `json:"error"`
+}
+
+// respondError 返回错误响应并终止后续的处理
+func respondError(c *gin.Context, code int, msg string) {
+	c.AbortWithStatusJSON(code, APIResponse{
+		Error: &APIError{Code: code, Message: msg},
+	})
+}
+
+// respondOK 返回成功响应
+func respondOK(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, APIResponse{Data: data})
+}
+
+// respondCreated 返回新建资源的成功响应，状态码为 201
+func respondCreated(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusCreated, APIResponse{Data: data})
+}
+
+// respondServerError 返回服务端错误，调用 MySQL 或 ES 超时时返回 504，其余返回 500。
+// err 为 APIError 时使用其中的状态码和错误信息
+func respondServerError(c *gin.Context, err error) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		c.AbortWithStatusJSON(apiErr.Code, APIResponse{Error: apiErr})
+		return
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		respondError(c, http.StatusGatewayTimeout, err.Error())
+		return
+	}
+	respondError(c, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// searchCacheHeader 响应头，HIT 表示结果来自 Redis 缓存，MISS 表示查询了 ES 或 MySQL
+const searchCacheHeader = "X-Cache"
+
+// searchCacheKeyPrefix 搜索结果缓存的键前缀，完整的键为 tag_search:<租户>:<关键字>:<搜索选项摘要>
+const searchCacheKeyPrefix = "tag_search:"
+
+// searchCacheScanCount 删除缓存时每次 SCAN 返回的键数量提示
+const searchCacheScanCount = 500
+
+// redisClient 缓存搜索结果的 Redis 客户端，未设置 REDIS_ADDR 或 SEARCH_CACHE_TTL 为 0 时为 nil，此时不缓存
+var redisClient *redis.Client
+
+// setupRedis 初始化 Redis 客户端。Redis 只用于缓存，启动时不检查连接，不可用时按未命中处理
+func setupRedis() {
+	if config.RedisAddr == "" || config.SearchCacheTTL == 0 {
+		return
+	}
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     config.RedisAddr,
+		Password: config.RedisPassword,
+	})
+}
+
+// cachedSearchResult 缓存的搜索结果，与搜索接口返回的数据相同
+type cachedSearchResult struct {
+	Matches    []*Tag `json:"matches"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// searchCacheKey 返回搜索结果缓存的键。除了关键字，翻页、匹配方式等选项也会改变结果，以摘要的形式放在键中
+func searchCacheKey(ctx context.Context, keyword string, opts SearchTagsOptions) string {
+	optsJSON, _ := json.Marshal(opts)
+	h := fnv.New64a()
+	h.Write(optsJSON)
+	return fmt.Sprintf("%s%s:%s:%x", searchCacheKeyPrefix, TenantIDFromContext(ctx), keyword, h.Sum64())
+}
+
+// searchCacheDisabled 判断请求是否通过 Cache-Control: no-cache 要求跳过缓存
+func searchCacheDisabled(c *gin.Context) bool {
+	for _, directive := range strings.Split(c.GetHeader("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCachedSearch 读取缓存的搜索结果，未启用缓存、未命中或 Redis 出错时返回 nil，Redis 出错不影响搜索
+func GetCachedSearch(ctx context.Context, key string) *cachedSearchResult {
+	if redisClient == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+
+	data, err := redisClient.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("[WARN] SearchCacheGetErr: %s", err)
+		}
+		return nil
+	}
+
+	var result cachedSearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Printf("[WARN] SearchCacheDecodeErr: %s", err)
+		return nil
+	}
+	return &result
+}
+
+// SetCachedSearch 缓存搜索结果 config.SearchCacheTTL，未启用缓存时不做任何事
+func SetCachedSearch(ctx context.Context, key string, result *cachedSearchResult) {
+	if redisClient == nil {
+		return
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("[WARN] SearchCacheEncodeErr: %s", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.QueryTimeout)
+	defer cancel()
+	if err := redisClient.Set(ctx, key, data, config.SearchCacheTTL).Err(); err != nil {
+		log.Printf("[WARN] SearchCacheSetErr: %s", err)
+	}
+}
+
+// InvalidateSearchCache 使用 SCAN 和 DEL 删除租户所有缓存的搜索结果，在标签写入或删除 ES 之后调用，
+// 之后的搜索可以看到新的结果。删除失败时缓存在 SEARCH_CACHE_TTL 后过期。
+// 不受调用方 context 取消影响，可以通过 go 语句在后台调用
+func InvalidateSearchCache(tenantID string) {
+	if redisClient == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*config.QueryTimeout)
+	defer cancel()
+
+	// 租户 ID 只包含字母、数字、下划线和中划线，不需要转义 MATCH 的通配符
+	iter := redisClient.Scan(ctx, 0, searchCacheKeyPrefix+tenantID+":*", searchCacheScanCount).Iterator()
+	keys := make([]string, 0, searchCacheScanCount)
+	deleted := 0
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) < searchCacheScanCount {
+			continue
+		}
+		if err := redisClient.Del(ctx, keys...).Err(); err != nil {
+			log.Printf("[WARN] SearchCacheInvalidateErr: tenant=%s %s", tenantID, err)
+			return
+		}
+		deleted += len(keys)
+		keys = keys[:0]
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("[WARN] SearchCacheInvalidateErr: tenant=%s %s", tenantID, err)
+		return
+	}
+	if len(keys) > 0 {
+		if err := redisClient.Del(ctx, keys...).Err(); err != nil {
+			log.Printf("[WARN] SearchCacheInvalidateErr: tenant=%s %s", tenantID, err)
+			return
+		}
+		deleted += len(keys)
+	}
+
+	if deleted > 0 {
+		log.Printf("SearchCacheInvalidateOk: tenant=%s keys=%d", tenantID, deleted)
+	}
+}
+
+// closeRedis 关闭 Redis 客户端
+func closeRedis() {
+	if redisClient == nil {
+		return
+	}
+	if err := redisClient.Close(); err != nil {
+		log.Printf("RedisCloseErr: %s", err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// likeEscaper 转义 LIKE 中的通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// wildcardToLike 把 ES wildcard 查询的 * 和 ? 转换为 LIKE 的 % 和 _
+var wildcardToLike = strings.NewReplacer(`*`, `%`, `?`, `_`)
+
+// SearchTagsFromMySQL 在 ES 不可用时通过 MySQL LIKE 搜索名称包含关键字的标签，opts.Match 为 wildcard 时
+// 关键字按通配符模式匹配整个名称。只返回第一页，忽略 opts.SearchAfter，结果中没有 LastSort。
+// opts.Highlight 为 true 时 Highlighted 为转义后的名称，不标记匹配的部分
+func SearchTagsFromMySQL(ctx context.Context, keyword string, opts SearchTagsOptions) (*SearchTagsResult, error) {
+	size := opts.Size
+	if size <= 0 {
+		size = 10
+	}
+
+	// 和 ES 一样每个词同时匹配名称和同义词，多个词按 opts.Mode 组合
+	tenantID := TenantIDFromContext(ctx)
+	var conditions []string
+	var args []interface{}
+	if opts.Match == ESSearchMatchWildcard {
+		pattern := wildcardToLike.Replace(likeEscaper.Replace(keyword))
+		conditions = append(conditions, "(name like ? or id in (select tag_id from tag_synonym_tbl where tenant_id = ? and synonym like ?))")
+		args = append(args, pattern, tenantID, pattern)
+	} else {
+		for _, term := range strings.Fields(keyword) {
+			pattern := "%" + likeEscaper.Replace(term) + "%"
+			conditions = append(conditions, "(name like ? or id in (select tag_id from tag_synonym_tbl where tenant_id = ? and synonym like ?))")
+			args = append(args, pattern, tenantID, pattern)
+		}
+	}
+	operator := " or "
+	if opts.Mode == SearchModeAnd {
+		operator = " and "
+	}
+
+	query := "select " + tagColumns + " from tag_tbl where tenant_id = ? and deleted_at is null and (" + strings.Join(conditions, operator) + ")"
+	args = append([]interface{}{tenantID}, args...)
+	if len(opts.TagIDs) > 0 {
+		query += " and id in (?)"
+		args = append(args, opts.TagIDs)
+	}
+	query += " order by id limit ?"
+	args = append(args, size)
+
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []*Tag{}
+	if err := dbSelect(ctx, &tags, query, args...); err != nil {
+		return nil, err
+	}
+	if opts.Highlight {
+		for _, tag := range tags {
+			tag.Highlighted = html.EscapeString(tag.Name)
+		}
+	}
+
+	return &SearchTagsResult{Tags: tags}, nil
+}
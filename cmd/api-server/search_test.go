@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// duplicateHitsResponse 别名同时指向新旧两个索引时的搜索结果，标签 1 和 2 各出现两次，分数不同
+const duplicateHitsResponse = `{
+	"hits": {
+		"total": {"value": 6, "relation": "eq"},
+		"hits": [
+			{"_score": 3.5, "_source": {"tag_id": 1, "name": "go"}, "sort": [3.5, 1], "highlight": {"name": ["<em>go</em>"]}},
+			{"_score": 3.0, "_source": {"tag_id": 2, "name": "golang"}, "sort": [3.0, 2], "highlight": {"name": ["<em>go</em>lang"]}},
+			{"_score": 2.5, "_source": {"tag_id": 1, "name": "go"}, "sort": [2.5, 1]},
+			{"_score": 2.0, "_source": {"tag_id": 3, "name": "gopher"}, "sort": [2.0, 3]},
+			{"_score": 4.0, "_source": {"tag_id": 2, "name": "golang"}, "sort": [4.0, 2]},
+			{"_score": 1.0, "_source": {"tag_id": 4, "name": "go&rust"}, "sort": [1.0, 4]}
+		]
+	}
+}`
+
+// decodeSearchResponse 与 searchTagsFromES 一样使用 json.Number 解析 ES 的响应
+func decodeSearchResponse(t *testing.T, body string) *esSearchResponse {
+	t.Helper()
+
+	var searchResp esSearchResponse
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&searchResp); err != nil {
+		t.Fatalf("decode search response: %s", err)
+	}
+	return &searchResp
+}
+
+func TestSearchTagsResultFromHitsDeduplicates(t *testing.T) {
+	result, err := searchTagsResultFromHits(decodeSearchResponse(t, duplicateHitsResponse), "name")
+	if err != nil {
+		t.Fatalf("searchTagsResultFromHits: %s", err)
+	}
+
+	// 保留每个标签第一次出现的位置，分数取最高的一次
+	cases := []struct {
+		tagID       int
+		score       float64
+		highlighted string
+	}{
+		{1, 3.5, "<em>go</em>"},
+		{2, 4.0, "golang"},
+		{3, 2.0, "gopher"},
+		{4, 1.0, "go&amp;rust"},
+	}
+	if len(result.Tags) != len(cases) {
+		t.Fatalf("got %d tags, want %d", len(result.Tags), len(cases))
+	}
+	for i, tc := range cases {
+		tag := result.Tags[i]
+		if tag.TagID != tc.tagID || tag.Score != tc.score || tag.Highlighted != tc.highlighted {
+			t.Errorf("tags[%d] = {%d %v %q}, want {%d %v %q}", i, tag.TagID, tag.Score, tag.Highlighted, tc.tagID, tc.score, tc.highlighted)
+		}
+	}
+
+	// 命中数量和 LastSort 按去重之前的结果计算
+	if result.Hits != 6 {
+		t.Errorf("hits = %d, want 6", result.Hits)
+	}
+	if want := []interface{}{json.Number("1.0"), json.Number("4")}; !reflect.DeepEqual(result.LastSort, want) {
+		t.Errorf("last sort = %v, want %v", result.LastSort, want)
+	}
+	if result.Total.Value != 6 || result.Total.Relation != "eq" {
+		t.Errorf("total = %+v, want {6 eq}", result.Total)
+	}
+}
+
+func TestSearchTagsResultFromHitsWithoutHighlight(t *testing.T) {
+	result, err := searchTagsResultFromHits(decodeSearchResponse(t, duplicateHitsResponse), "")
+	if err != nil {
+		t.Fatalf("searchTagsResultFromHits: %s", err)
+	}
+	for _, tag := range result.Tags {
+		if tag.Highlighted != "" {
+			t.Errorf("tag %d highlighted = %q, want empty", tag.TagID, tag.Highlighted)
+		}
+	}
+}
+
+func TestSearchTagsResultFromHitsWithoutSource(t *testing.T) {
+	body := `{"hits": {"total": {"value": 1, "relation": "eq"}, "hits": [{"_score": 1.0, "sort": [1.0, 1]}]}}`
+	if _, err := searchTagsResultFromHits(decodeSearchResponse(t, body), ""); err == nil {
+		t.Errorf("expected error for hit without _source")
+	}
+}
+
+func TestSearchNextCursor(t *testing.T) {
+	result, err := searchTagsResultFromHits(decodeSearchResponse(t, duplicateHitsResponse), "")
+	if err != nil {
+		t.Fatalf("searchTagsResultFromHits: %s", err)
+	}
+
+	// 去重后只有 4 个标签，但是 ES 返回了一整页命中，仍然需要返回下一页的游标
+	cursor := searchNextCursor(result, 6)
+	if cursor == "" {
+		t.Fatalf("next cursor is empty for a full page of hits")
+	}
+	sortValues, err := DecodeSearchCursor(cursor)
+	if err != nil {
+		t.Fatalf("DecodeSearchCursor: %s", err)
+	}
+	if want := []interface{}{json.Number("1.0"), json.Number("4")}; !reflect.DeepEqual(sortValues, want) {
+		t.Errorf("cursor sort values = %v, want %v", sortValues, want)
+	}
+
+	if cursor := searchNextCursor(result, 10); cursor != "" {
+		t.Errorf("next cursor = %q, want empty when hits are fewer than limit", cursor)
+	}
+	if cursor := searchNextCursor(&SearchTagsResult{}, 0); cursor != "" {
+		t.Errorf("next cursor = %q, want empty without results", cursor)
+	}
+}
+
+func TestSearchKeywordTooShort(t *testing.T) {
+	prevConfig := config
+	t.Cleanup(func() { config = prevConfig })
+
+	cases := []struct {
+		name      string
+		minLength int
+		keyword   string
+		want      bool
+	}{
+		{"single character", 2, "g", true},
+		{"at minimum", 2, "go", false},
+		{"counts runes not bytes", 2, "中", true},
+		{"multibyte at minimum", 2, "中文", false},
+		{"no limit", 0, "g", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config = &Config{SearchMinKeywordLength: tc.minLength}
+
+			message, tooShort := searchKeywordTooShort(tc.keyword)
+			if tooShort != tc.want {
+				t.Fatalf("tooShort = %v, want %v", tooShort, tc.want)
+			}
+			if tooShort && message != fmt.Sprintf("keyword must be at least %d characters", tc.minLength) {
+				t.Fatalf("message = %q", message)
+			}
+			if !tooShort && message != "" {
+				t.Fatalf("message = %q, want empty", message)
+			}
+		})
+	}
+}
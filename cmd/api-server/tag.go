@@ -0,0 +1,580 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// SoftDeleteTag 软删除标签并从 ES 索引中移除，标签不存在或已被删除时返回 404 错误
+func SoftDeleteTag(ctx context.Context, tagID int) error {
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var tag Tag
+		queryErr := txGet(ctx, tx, &tag, "select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ? and deleted_at is null for update", TenantIDFromContext(ctx), tagID)
+		if queryErr == sql.ErrNoRows {
+			return newAPIError(http.StatusNotFound, "tag not found")
+		}
+		if queryErr != nil {
+			return queryErr
+		}
+
+		if _, execErr := txExec(ctx, tx, "update tag_tbl set deleted_at = now() where id = ?", tagID); execErr != nil {
+			return execErr
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagDelete,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   tagID,
+			Metadata:   gin.H{"before": tag},
+		})
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	InvalidateCachedTags(ctx, tagID)
+	go DeleteTagFromES(config.ESIndex, TenantIDFromContext(ctx), tagID)
+	return nil
+}
+
+// OnGetTag 查询标签详情，include_deleted=true 时可以查询已软删除的标签，便于恢复。
+// 版本号同时通过 ETag 响应头返回，修改标签时可以原样放在 If-Match 请求头中，
+// 请求头 If-None-Match 与当前的 ETag 相同时返回 304。
+// include_synonyms=true 时同时返回标签的同义词。标签的元数据总是返回，没有元数据时省略
+// @Summary 查询标签详情
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param include_deleted query bool false "是否包含已软删除的标签"
+// @Param include_synonyms query bool false "是否返回同义词"
+// @Success 200 {object} APIResponse{data=object{tag=Tag}}
+// @Param If-None-Match header string false "之前返回的 ETag"
+// @Success 304 "标签没有变化"
+// @Header 200 {string} ETag "标签的版本号，包含同义词或已删除的标签时为弱 ETag"
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/{id} [get]
+func OnGetTag(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	// 已删除的标签不在缓存中，包含已删除的标签时直接查询 MySQL
+	tag := &Tag{}
+	var queryErr error
+	if c.Query("include_deleted") == "true" {
+		queryErr = dbGet(c.Request.Context(), tag, "select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ?", TenantIDFromContext(c.Request.Context()), tagID)
+	} else {
+		tag, queryErr = GetTagByID(c.Request.Context(), tagID)
+	}
+	if queryErr == sql.ErrNoRows {
+		respondError(c, http.StatusNotFound, "tag not found")
+		return
+	}
+	if queryErr != nil {
+		respondServerError(c, queryErr)
+		return
+	}
+
+	if c.Query("include_synonyms") == "true" {
+		if err := LoadTagSynonyms(c.Request.Context(), []*Tag{tag}); err != nil {
+			respondServerError(c, err)
+			return
+		}
+	}
+	if err := LoadTagMetadata(c.Request.Context(), []*Tag{tag}); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	if respondNotModified(c, tagETag(tag)) {
+		return
+	}
+	respondOK(c, gin.H{
+		"tag": tag,
+	})
+}
+
+// 标签列表的排序方式
+const (
+	// TagListOrderID 按 ID 从小到大排列，通过 after_id 翻页
+	TagListOrderID = "id"
+	// TagListOrderCreatedAtDesc 按创建时间从新到旧排列，创建时间相同时按 ID 从大到小，
+	// 通过 before_created_at 和 before_id 翻页
+	TagListOrderCreatedAtDesc = "created_at_desc"
+)
+
+// OnListTags 分页列出未删除的标签，默认按 ID 顺序，order=created_at_desc 时按创建时间从新到旧，
+// 返回响应体摘要的弱 ETag，If-None-Match 相同时返回 304
+// @Summary 标签列表
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param order query string false "排序方式，id 或 created_at_desc，默认 id"
+// @Param after_id query int false "order 为 id 时上一页返回的 next_after_id"
+// @Param before_created_at query string false "order 为 created_at_desc 时上一页返回的 next_before_created_at"
+// @Param before_id query int false "order 为 created_at_desc 时上一页返回的 next_before_id，需要和 before_created_at 一起传入"
+// @Param limit query int false "每页数量，默认 20，最大 100"
+// @Param If-None-Match header string false "之前返回的 ETag"
+// @Success 200 {object} APIResponse{data=object{tags=[]Tag,next_after_id=int,next_before_created_at=string,next_before_id=int}}
+// @Success 304 "标签列表没有变化"
+// @Header 200 {string} ETag "响应体摘要的弱 ETag"
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tags [get]
+func OnListTags(c *gin.Context) {
+	afterID, ok := parseIntQuery(c, "after_id", 0)
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(c, 20, 100)
+	if !ok {
+		return
+	}
+
+	switch order := c.DefaultQuery("order", TagListOrderID); order {
+	case TagListOrderID:
+	case TagListOrderCreatedAtDesc:
+		listTagsByCreatedAt(c, limit)
+		return
+	default:
+		respondError(c, http.StatusBadRequest, "invalid order")
+		return
+	}
+
+	tags := []*Tag{}
+	selectErr := dbSelect(
+		c.Request.Context(),
+		&tags,
+		"select "+tagColumns+" from tag_tbl where tenant_id = ? and id > ? and deleted_at is null order by id limit ?",
+		TenantIDFromContext(c.Request.Context()), afterID, limit,
+	)
+	if selectErr != nil {
+		respondServerError(c, selectErr)
+		return
+	}
+
+	// 没有更多数据时 next_after_id 为 0
+	nextAfterID := 0
+	if len(tags) == limit {
+		nextAfterID = tags[len(tags)-1].TagID
+	}
+
+	respondOKWithETag(c, gin.H{
+		"tags":          tags,
+		"next_after_id": nextAfterID,
+	})
+}
+
+// listTagsByCreatedAt 按创建时间从新到旧分页返回未删除的标签，游标由创建时间和 ID 组成，两者需要同时传入
+func listTagsByCreatedAt(c *gin.Context, limit int) {
+	beforeID, ok := parseIntQuery(c, "before_id", 0)
+	if !ok {
+		return
+	}
+
+	query := "select " + tagColumns + " from tag_tbl where tenant_id = ? and deleted_at is null"
+	args := []interface{}{TenantIDFromContext(c.Request.Context())}
+	beforeCreatedAt := c.Query("before_created_at")
+	if (beforeCreatedAt == "") != (beforeID == 0) {
+		respondError(c, http.StatusBadRequest, "before_created_at and before_id must be used together")
+		return
+	}
+	if beforeCreatedAt != "" {
+		before, err := time.Parse(time.RFC3339, beforeCreatedAt)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid before_created_at")
+			return
+		}
+		query += " and (created_at < ? or (created_at = ? and id < ?))"
+		args = append(args, before.UTC(), before.UTC(), beforeID)
+	}
+	query += " order by created_at desc, id desc limit ?"
+	args = append(args, limit)
+
+	tags := []*Tag{}
+	if selectErr := dbSelect(c.Request.Context(), &tags, query, args...); selectErr != nil {
+		respondServerError(c, selectErr)
+		return
+	}
+
+	// 没有更多数据时 next_before_created_at 为空字符串，next_before_id 为 0
+	nextBeforeCreatedAt, nextBeforeID := "", 0
+	if len(tags) == limit {
+		last := tags[len(tags)-1]
+		nextBeforeCreatedAt, nextBeforeID = last.CreatedAt.UTC().Format(time.RFC3339), last.TagID
+	}
+
+	respondOKWithETag(c, gin.H{
+		"tags":                   tags,
+		"next_before_created_at": nextBeforeCreatedAt,
+		"next_before_id":         nextBeforeID,
+	})
+}
+
+// maxBatchGetTags 批量查询标签时每次请求最多可以传入的标签 ID 数量
+const maxBatchGetTags = 500
+
+// GetTagsBatchReqBody 批量查询标签的请求体
+type GetTagsBatchReqBody struct {
+	TagIDs []int `json:"tag_ids"`
+}
+
+// GetTagsByIDs 查询当前租户未删除的标签，返回的切片与 tagIDs 一一对应，不存在或已删除的标签为 nil，
+// 同时按传入顺序返回这些不存在的标签 ID，重复的 ID 只列出一次。进程内缓存中已有的标签不再查询 MySQL
+func GetTagsByIDs(ctx context.Context, tagIDs []int) ([]*Tag, []int, error) {
+	result := make([]*Tag, len(tagIDs))
+	missing := []int{}
+	if len(tagIDs) == 0 {
+		return result, missing, nil
+	}
+
+	tenantID := TenantIDFromContext(ctx)
+	tagsByID := make(map[int]*Tag, len(tagIDs))
+	uncachedIDs := []int{}
+	for _, tagID := range tagIDs {
+		if _, ok := tagsByID[tagID]; ok {
+			continue
+		}
+		if tag := getCachedTag(tenantID, tagID); tag != nil {
+			tagsByID[tagID] = tag
+		} else {
+			uncachedIDs = append(uncachedIDs, tagID)
+		}
+	}
+
+	if len(uncachedIDs) > 0 {
+		query, args, err := sqlx.In(
+			"select "+tagColumns+" from tag_tbl where tenant_id = ? and id in (?) and deleted_at is null",
+			tenantID, uncachedIDs,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tags := []*Tag{}
+		if err := dbSelect(ctx, &tags, query, args...); err != nil {
+			return nil, nil, err
+		}
+		setCachedTags(tags...)
+
+		for _, tag := range tags {
+			tagsByID[tag.TagID] = tag
+		}
+	}
+
+	seenMissing := make(map[int]bool)
+	for index, tagID := range tagIDs {
+		if tag, ok := tagsByID[tagID]; ok {
+			result[index] = tag
+			continue
+		}
+		if !seenMissing[tagID] {
+			seenMissing[tagID] = true
+			missing = append(missing, tagID)
+		}
+	}
+	return result, missing, nil
+}
+
+// OnGetTagsBatch 根据 ID 批量查询标签，tags 与请求中的 tag_ids 一一对应，不存在或已删除的标签为 null，
+// 这些 ID 同时列在 missing 中
+// @Summary 批量查询标签
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body GetTagsBatchReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{tags=[]Tag,missing=[]int}}
+// @Failure 400 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tags/batch_get [post]
+func OnGetTagsBatch(c *gin.Context) {
+	var reqBody GetTagsBatchReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	if len(reqBody.TagIDs) > maxBatchGetTags {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("too many tag_ids, max %d", maxBatchGetTags))
+		return
+	}
+	for _, tagID := range reqBody.TagIDs {
+		if tagID <= 0 {
+			respondError(c, http.StatusBadRequest, "invalid tag_ids")
+			return
+		}
+	}
+
+	tags, missing, err := GetTagsByIDs(c.Request.Context(), reqBody.TagIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"tags":    tags,
+		"missing": missing,
+	})
+}
+
+// OnDeleteTag 软删除标签
+// @Summary 删除标签
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Success 200 {object} APIResponse{data=object{tag_id=int}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/{id} [delete]
+func OnDeleteTag(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := SoftDeleteTag(c.Request.Context(), tagID); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"tag_id": tagID,
+	})
+}
+
+// OnDeleteTagByName 根据名称软删除标签，删除逻辑与 OnDeleteTag 相同
+// @Summary 根据名称删除标签
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param name query string true "标签名称"
+// @Success 200 {object} APIResponse{data=object{tag_id=int}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag [delete]
+func OnDeleteTagByName(c *gin.Context) {
+	tagName, err := validateTagName(c.Query("name"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tag, queryErr := GetTagByName(c.Request.Context(), tagName)
+	if queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			respondError(c, http.StatusNotFound, "tag not found")
+			return
+		}
+		respondServerError(c, queryErr)
+		return
+	}
+
+	// 查询之后标签可能被并发删除，SoftDeleteTag 会在事务中再次确认并返回 404
+	if err := SoftDeleteTag(c.Request.Context(), tag.TagID); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"tag_id": tag.TagID,
+	})
+}
+
+// PatchTagReqBody 部分更新标签的请求体，未传入的字段保持不变
+type PatchTagReqBody struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Color       *string `json:"color"`
+	Category    *string `json:"category"`
+	// Version 客户端读取到的版本号，也可以通过 If-Match 请求头传入
+	Version   *int   `json:"version"`
+	ChangedBy string `json:"changed_by"`
+}
+
+// tagFieldMaxLength 可更新字段的最大长度，与 tag_tbl 中对应字段的长度一致
+var tagFieldMaxLength = map[string]int{
+	"description": 255,
+	"color":       16,
+	"category":    40,
+}
+
+// parseVersion 读取客户端传入的版本号，优先使用请求体中的 version，其次使用 If-Match 请求头，
+// 都没有传入或不合法时返回 400
+func parseVersion(c *gin.Context, bodyVersion *int) (int, bool) {
+	version := 0
+	if bodyVersion != nil {
+		version = *bodyVersion
+	} else if ifMatch := strings.Trim(c.GetHeader("If-Match"), `"`); ifMatch != "" {
+		n, err := strconv.Atoi(ifMatch)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid If-Match")
+			return 0, false
+		}
+		version = n
+	}
+	if version <= 0 {
+		respondError(c, http.StatusBadRequest, "version is required")
+		return 0, false
+	}
+	return version, true
+}
+
+// OnPatchTag 部分更新标签，通过 version 字段实现乐观锁，版本不一致时返回 409 和最新的标签
+// @Summary 部分更新标签
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param If-Match header string false "标签的版本号，也可以通过请求体中的 version 传入"
+// @Param body body PatchTagReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{tag=Tag}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 409 {object} APIResponse{error=APIError{detail=object{tag=Tag}}}
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/{id} [patch]
+func OnPatchTag(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody PatchTagReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	version, ok := parseVersion(c, reqBody.Version)
+	if !ok {
+		return
+	}
+
+	// 修改名称需要和改名接口一样的校验
+	newName := ""
+	if reqBody.Name != nil {
+		name, err := validateTagName(*reqBody.Name)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+		newName = name
+	}
+
+	// 只更新传入的字段
+	fields := map[string]*string{
+		"description": reqBody.Description,
+		"color":       reqBody.Color,
+		"category":    reqBody.Category,
+	}
+	sets := []string{}
+	args := []interface{}{}
+	for _, field := range []string{"description", "color", "category"} {
+		value := fields[field]
+		if value == nil {
+			continue
+		}
+		if utf8.RuneCountInString(*value) > tagFieldMaxLength[field] {
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("%s too long, max %d characters", field, tagFieldMaxLength[field]))
+			return
+		}
+		sets = append(sets, field+" = ?")
+		args = append(args, *value)
+	}
+
+	var tag Tag
+	ctx := c.Request.Context()
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		queryErr := txGet(ctx, tx, &tag, "select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ? and deleted_at is null for update", TenantIDFromContext(ctx), tagID)
+		if queryErr == sql.ErrNoRows {
+			return newAPIError(http.StatusNotFound, "tag not found")
+		}
+		if queryErr != nil {
+			return queryErr
+		}
+
+		if tag.Version != version {
+			apiErr := newAPIError(http.StatusConflict, "version conflict")
+			apiErr.Detail = gin.H{"tag": tag}
+			return apiErr
+		}
+		before := tag
+
+		if newName != "" {
+			if _, renameErr := RenameTagTx(ctx, tx, tagID, newName, reqBody.ChangedBy); renameErr != nil {
+				return renameErr
+			}
+		}
+
+		sets = append(sets, "version = version + 1")
+		args = append(args, tagID, version)
+		result, execErr := txExec(ctx, tx, "update tag_tbl set "+strings.Join(sets, ", ")+" where id = ? and version = ?", args...)
+		if execErr != nil {
+			return execErr
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return newAPIError(http.StatusConflict, "version conflict")
+		}
+
+		if queryErr := txGet(ctx, tx, &tag, "select "+tagColumns+" from tag_tbl where id = ?", tagID); queryErr != nil {
+			return queryErr
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagUpdate,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   tagID,
+			Metadata:   gin.H{"before": before, "after": tag},
+		})
+	})
+	if txErr != nil {
+		respondServerError(c, txErr)
+		return
+	}
+
+	InvalidateCachedTags(ctx, tagID)
+	// 更新 ES 索引
+	go ReportTagToES(ctx, config.ESIndex, &tag)
+
+	respondOK(c, gin.H{
+		"tag": tag,
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// tagCacheKey 标签缓存的键，不同租户的标签 ID 不会重复，仍然带上租户避免跨租户读取
+type tagCacheKey struct {
+	TenantID string
+	TagID    int
+}
+
+// tagCacheEntry 缓存的标签，Tag 只保存 tag_tbl 中的字段
+type tagCacheEntry struct {
+	Key      tagCacheKey
+	Tag      Tag
+	CachedAt time.Time
+}
+
+// tagCache 按 ID 查询标签的进程内 LRU 缓存，最多保存 config.TagCacheSize 个未删除的标签，
+// 超过时淘汰最久没有读取的标签。修改、删除和合并标签后由 InvalidateCachedTags 删除，
+// 其它实例上的修改只能等待 config.TagCacheTTL 过期
+var tagCache = struct {
+	sync.Mutex
+	// lru 最近读取的标签在前面，元素的值为 *tagCacheEntry
+	lru     *list.List
+	entries map[tagCacheKey]*list.Element
+}{lru: list.New(), entries: make(map[tagCacheKey]*list.Element)}
+
+// tagCacheEnabled 判断是否启用了标签缓存
+func tagCacheEnabled() bool {
+	return config.TagCacheSize > 0 && config.TagCacheTTL > 0
+}
+
+// getCachedTag 读取缓存的标签，返回副本，调用方可以修改。未启用、未命中或已过期时返回 nil
+func getCachedTag(tenantID string, tagID int) *Tag {
+	if !tagCacheEnabled() {
+		return nil
+	}
+
+	key := tagCacheKey{TenantID: tenantID, TagID: tagID}
+	tagCache.Lock()
+	defer tagCache.Unlock()
+
+	elem, ok := tagCache.entries[key]
+	if !ok {
+		tagCacheMisses.Inc()
+		return nil
+	}
+	entry := elem.Value.(*tagCacheEntry)
+	if time.Since(entry.CachedAt) >= config.TagCacheTTL {
+		tagCache.lru.Remove(elem)
+		delete(tagCache.entries, key)
+		tagCacheMisses.Inc()
+		return nil
+	}
+
+	tagCache.lru.MoveToFront(elem)
+	tagCacheHits.Inc()
+	tag := entry.Tag
+	return &tag
+}
+
+// setCachedTags 缓存从 MySQL 读取的未删除标签，搜索相关度、同义词、元数据等不属于 tag_tbl 的字段不会被缓存
+func setCachedTags(tags ...*Tag) {
+	if !tagCacheEnabled() {
+		return
+	}
+
+	now := time.Now()
+	tagCache.Lock()
+	defer tagCache.Unlock()
+
+	for _, tag := range tags {
+		if tag == nil || tag.DeletedAt != nil {
+			continue
+		}
+
+		entry := &tagCacheEntry{Key: tagCacheKey{TenantID: tag.TenantID, TagID: tag.TagID}, Tag: *tag, CachedAt: now}
+		entry.Tag.Score, entry.Tag.Synonyms, entry.Tag.Metadata, entry.Tag.Highlighted = 0, nil, nil, ""
+
+		if elem, ok := tagCache.entries[entry.Key]; ok {
+			elem.Value = entry
+			tagCache.lru.MoveToFront(elem)
+			continue
+		}
+		tagCache.entries[entry.Key] = tagCache.lru.PushFront(entry)
+
+		for tagCache.lru.Len() > config.TagCacheSize {
+			oldest := tagCache.lru.Back()
+			tagCache.lru.Remove(oldest)
+			delete(tagCache.entries, oldest.Value.(*tagCacheEntry).Key)
+		}
+	}
+}
+
+// InvalidateCachedTags 删除当前租户 tagIDs 的缓存，需要在修改标签的事务提交之后调用，
+// 否则并发的查询可能在提交前把旧的标签重新写入缓存
+func InvalidateCachedTags(ctx context.Context, tagIDs ...int) {
+	if !tagCacheEnabled() {
+		return
+	}
+
+	tenantID := TenantIDFromContext(ctx)
+	tagCache.Lock()
+	defer tagCache.Unlock()
+
+	for _, tagID := range tagIDs {
+		key := tagCacheKey{TenantID: tenantID, TagID: tagID}
+		if elem, ok := tagCache.entries[key]; ok {
+			tagCache.lru.Remove(elem)
+			delete(tagCache.entries, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tagExpiryBatchSize 每次从 tag_tbl 中读取的过期标签数量
+const tagExpiryBatchSize = 500
+
+// tagExpiryActorID 过期清理写入审计日志时使用的操作人
+const tagExpiryActorID = "system:tag-expiry"
+
+var (
+	// tagExpiryLastRun 最近一次过期清理完成的时间
+	tagExpiryLastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tag_server_tag_expiry_last_run_timestamp_seconds",
+		Help: "Unix time of the last completed tag expiry run.",
+	})
+	// tagExpiryLastDeleted 最近一次过期清理删除的标签数量
+	tagExpiryLastDeleted = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tag_server_tag_expiry_last_deleted",
+		Help: "The number of tags soft-deleted by the last tag expiry run.",
+	})
+)
+
+// expiredTag 已经过期但还没有删除的标签
+type expiredTag struct {
+	TagID    int    `db:"id"`
+	TenantID string `db:"tenant_id"`
+}
+
+// ExpireTags 软删除所有 expires_at 已经过去的标签，删除逻辑与 SoftDeleteTag 相同，返回删除的数量。
+// 某个标签删除失败时记录日志并结束本次清理，下次运行时重试
+func ExpireTags(ctx context.Context) (int, error) {
+	deleted := 0
+	for {
+		tags := []*expiredTag{}
+		queryErr := dbSelect(
+			ctx, &tags,
+			"select id, tenant_id from tag_tbl where expires_at < now() and deleted_at is null order by expires_at limit ?",
+			tagExpiryBatchSize,
+		)
+		if queryErr != nil {
+			return deleted, queryErr
+		}
+
+		for _, tag := range tags {
+			tagCtx := WithActorID(WithTenantID(ctx, tag.TenantID), tagExpiryActorID)
+			err := SoftDeleteTag(tagCtx, tag.TagID)
+
+			// 查询之后被并发删除的标签不计入
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+				continue
+			}
+			if err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+
+		if len(tags) < tagExpiryBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// StartTagExpiryWorker 每隔 interval 清理一次过期的标签，并注册清理相关的指标
+func StartTagExpiryWorker(interval time.Duration) {
+	prometheus.MustRegister(tagExpiryLastRun, tagExpiryLastDeleted)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deleted, err := ExpireTags(context.Background())
+			if err != nil {
+				log.Printf("ExpireTagsErr: %s", err)
+			}
+			if deleted > 0 {
+				log.Printf("ExpireTagsOk: %d", deleted)
+			}
+
+			tagExpiryLastRun.SetToCurrentTime()
+			tagExpiryLastDeleted.Set(float64(deleted))
+		}
+	}()
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// exportFlushRows 导出标签时每写出多少行刷新一次响应
+const exportFlushRows = 500
+
+// 管理员导出标签的格式
+const (
+	// TagExportFormatJSON 每行一个 JSON 对象，与 GET /api/tags/export 相同
+	TagExportFormatJSON = "json"
+	// TagExportFormatCSV 第一行为表头的 CSV
+	TagExportFormatCSV = "csv"
+)
+
+// tagExportCSVHeader CSV 格式导出标签时的表头，与 tagExportCSVRecord 的字段顺序一致
+var tagExportCSVHeader = []string{
+	"tag_id", "name", "display_name", "description", "color", "category", "version",
+	"created_at", "updated_at", "deleted_at", "expires_at",
+}
+
+// formatExportTime 把时间格式化为 RFC3339，为 nil 时返回空字符串
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// tagExportCSVRecord 返回标签在 CSV 中的一行
+func tagExportCSVRecord(tag *Tag) []string {
+	return []string{
+		strconv.Itoa(tag.TagID), tag.Name, tag.DisplayName, tag.Description, tag.Color, tag.Category, strconv.Itoa(tag.Version),
+		formatExportTime(&tag.CreatedAt), formatExportTime(&tag.UpdatedAt), formatExportTime(tag.DeletedAt), formatExportTime(tag.ExpiresAt),
+	}
+}
+
+// parseExportSince 解析 since 查询参数，未传入时返回零值，格式错误时返回 400 并返回 false
+func parseExportSince(c *gin.Context) (time.Time, bool) {
+	value := c.Query("since")
+	if value == "" {
+		return time.Time{}, true
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid since")
+		return time.Time{}, false
+	}
+	return since, true
+}
+
+// queryExportTags 按 ID 顺序逐行读取当前租户在 since 之后创建的所有标签（包括已软删除的标签），
+// 不会一次加载到内存中。导出的时间不固定，不使用 config.QueryTimeout，请求取消时查询随之结束
+func queryExportTags(ctx context.Context, since time.Time) (*sqlx.Rows, error) {
+	return mysqlDB.QueryxContext(
+		ctx,
+		"select "+tagColumns+" from tag_tbl where tenant_id = ? and created_at > ? order by id",
+		TenantIDFromContext(ctx), since,
+	)
+}
+
+// streamExportTags 逐行读取 rows 中的标签并通过 write 写出，每 exportFlushRows 行以及结束时调用一次 flush。
+// 响应已经开始写出，之后的错误只能记录日志并中断输出
+func streamExportTags(rows *sqlx.Rows, write func(tag *Tag) error, flush func() error) {
+	count := 0
+	for rows.Next() {
+		var tag Tag
+		if err := rows.StructScan(&tag); err != nil {
+			log.Printf("ExportTagsErr: %s", err)
+			return
+		}
+
+		if err := write(&tag); err != nil {
+			log.Printf("ExportTagsErr: %s", err)
+			return
+		}
+
+		count++
+		if count%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				log.Printf("ExportTagsErr: %s", err)
+				return
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ExportTagsErr: %s", err)
+		return
+	}
+
+	if err := flush(); err != nil {
+		log.Printf("ExportTagsErr: %s", err)
+	}
+}
+
+// streamExportTagsNDJSON 以 NDJSON 格式写出 rows 中的标签
+func streamExportTagsNDJSON(c *gin.Context, rows *sqlx.Rows) {
+	encoder := json.NewEncoder(c.Writer)
+	streamExportTags(
+		rows,
+		func(tag *Tag) error { return encoder.Encode(tag) },
+		func() error {
+			c.Writer.Flush()
+			return nil
+		},
+	)
+}
+
+// OnExportTags 以 NDJSON 格式流式导出当前租户的所有标签（包括已软删除的标签），
+// since 为 RFC3339 时间，传入时只导出在该时间之后创建的标签
+// @Summary 导出标签
+// @Tags tag
+// @Produce application/x-ndjson
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param since query string false "只导出在该时间之后创建的标签，RFC3339 格式，例如 2020-06-01T00:00:00Z"
+// @Success 200 {object} Tag "每行一个标签"
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Router /api/tags/export [get]
+func OnExportTags(c *gin.Context) {
+	since, ok := parseExportSince(c)
+	if !ok {
+		return
+	}
+
+	rows, err := queryExportTags(c.Request.Context(), since)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	streamExportTagsNDJSON(c, rows)
+}
+
+// OnAdminExportTags 以附件的形式导出当前租户的所有标签（包括已软删除的标签），用于灾难恢复和审计。
+// format 为 json 时每行一个 JSON 对象，格式与 GET /api/tags/export 相同，可以通过 NDJSON 导入接口恢复；
+// 为 csv 时第一行为表头，时间为 RFC3339 格式，没有的时间为空。两种格式都逐行读取和写出，内存占用与标签数量无关
+// @Summary 导出标签备份
+// @Tags admin
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param format query string false "导出格式，json 或 csv，默认 json"
+// @Param since query string false "只导出在该时间之后创建的标签，RFC3339 格式，例如 2020-06-01T00:00:00Z"
+// @Success 200 {file} file "tags.ndjson 或 tags.csv"
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/admin/tag/export [get]
+func OnAdminExportTags(c *gin.Context) {
+	format := c.DefaultQuery("format", TagExportFormatJSON)
+	if format != TagExportFormatJSON && format != TagExportFormatCSV {
+		respondError(c, http.StatusBadRequest, "invalid format")
+		return
+	}
+
+	since, ok := parseExportSince(c)
+	if !ok {
+		return
+	}
+
+	rows, err := queryExportTags(c.Request.Context(), since)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+	defer rows.Close()
+
+	if format == TagExportFormatJSON {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="tags.ndjson"`)
+		c.Status(http.StatusOK)
+		streamExportTagsNDJSON(c, rows)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="tags.csv"`)
+	c.Status(http.StatusOK)
+
+	csvWriter := csv.NewWriter(c.Writer)
+	if err := csvWriter.Write(tagExportCSVHeader); err != nil {
+		log.Printf("ExportTagsErr: %s", err)
+		return
+	}
+	streamExportTags(
+		rows,
+		func(tag *Tag) error { return csvWriter.Write(tagExportCSVRecord(tag)) },
+		func() error {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		},
+	)
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// importBatchSize 导入标签时每批写入的行数
+const importBatchSize = 500
+
+// ImportRowError 导入时某一行的错误
+type ImportRowError struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+// Error 实现 error 接口，逐行读取导入数据时用于返回格式错误的行
+func (e *ImportRowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Reason)
+}
+
+// ImportTagsResult 导入标签的结果
+type ImportTagsResult struct {
+	Inserted          int               `json:"inserted"`
+	SkippedDuplicates int               `json:"skipped_duplicates"`
+	Errors            []*ImportRowError `json:"errors"`
+}
+
+// BulkInsertTagNames 批量创建标签，规范化后已经存在的名称会被跳过，返回新创建的标签
+func BulkInsertTagNames(ctx context.Context, names []string) ([]*Tag, error) {
+	if len(names) == 0 {
+		return []*Tag{}, nil
+	}
+
+	// 规范化后相同的名称只保留第一个作为展示名称
+	displayNames := make(map[string]string, len(names))
+	normalizedNames := make([]string, 0, len(names))
+	for _, name := range names {
+		normalizedName := NormalizeTagName(name)
+		if _, ok := displayNames[normalizedName]; !ok {
+			displayNames[normalizedName] = name
+			normalizedNames = append(normalizedNames, normalizedName)
+		}
+	}
+
+	// 找出已经存在的名称
+	tenantID := TenantIDFromContext(ctx)
+	existNames := []string{}
+	query, args, err := sqlx.In("select name from tag_tbl where tenant_id = ? and name in (?)", tenantID, normalizedNames)
+	if err != nil {
+		return nil, err
+	}
+	if err := dbSelect(ctx, &existNames, query, args...); err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(existNames))
+	for _, name := range existNames {
+		exists[name] = true
+	}
+
+	newNames := make([]string, 0, len(normalizedNames))
+	for _, name := range normalizedNames {
+		if !exists[name] {
+			newNames = append(newNames, name)
+		}
+	}
+	if len(newNames) == 0 {
+		return []*Tag{}, nil
+	}
+
+	// 使用一条多行 insert 写入，并发创建的同名标签会被忽略
+	placeholders := make([]string, 0, len(newNames))
+	insertArgs := make([]interface{}, 0, len(newNames))
+	for _, name := range newNames {
+		placeholders = append(placeholders, "(?, ?, ?)")
+		insertArgs = append(insertArgs, tenantID, name, displayNames[name])
+	}
+	if _, err := dbExec(ctx, "insert ignore into tag_tbl (tenant_id, name, display_name) values "+strings.Join(placeholders, ", "), insertArgs...); err != nil {
+		return nil, err
+	}
+
+	tags := []*Tag{}
+	query, args, err = sqlx.In("select "+tagColumns+" from tag_tbl where tenant_id = ? and name in (?) and deleted_at is null", tenantID, newNames)
+	if err != nil {
+		return nil, err
+	}
+	if err := dbSelect(ctx, &tags, query, args...); err != nil {
+		return nil, err
+	}
+
+	if len(tags) > 0 {
+		tagIDs := make([]int, 0, len(tags))
+		for _, tag := range tags {
+			tagIDs = append(tagIDs, tag.TagID)
+		}
+		auditErr := InsertAuditLog(ctx, &AuditLog{
+			Action:     AuditActionTagImport,
+			EntityType: AuditEntityTypeTag,
+			Metadata:   gin.H{"created": tagIDs},
+		})
+		if auditErr != nil {
+			return nil, auditErr
+		}
+	}
+
+	return tags, nil
+}
+
+// importTagBatch 写入一批标签，并更新导入结果
+func importTagBatch(ctx context.Context, names []string, result *ImportTagsResult) error {
+	tags, err := BulkInsertTagNames(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	result.Inserted += len(tags)
+	result.SkippedDuplicates += len(names) - len(tags)
+
+	// 添加到 ES 索引
+	go func() {
+		for _, tag := range tags {
+			ReportTagToES(ctx, config.ESIndex, tag)
+		}
+	}()
+
+	return nil
+}
+
+// OnImportTagsCSV 通过 CSV 文件导入标签，文件通过 multipart/form-data 的 file 字段上传，
+// 每行第一列为标签名称，第一行为 name 时视为表头
+// @Summary 通过 CSV 导入标签
+// @Tags tag
+// @Accept mpfd
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param file formData file true "CSV 文件，第一列为标签名称"
+// @Success 200 {object} APIResponse{data=ImportTagsResult}
+// @Failure 400 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/import [post]
+func OnImportTagsCSV(c *gin.Context) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 逐个读取 part，找到文件字段后流式解析，不把整个文件读入内存
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			respondError(c, http.StatusBadRequest, "file is required")
+			return
+		}
+		if err != nil {
+			respondError(c, bindErrorStatus(err), err.Error())
+			return
+		}
+
+		if part.FormName() != "file" {
+			continue
+		}
+
+		result, err := importTagsFromCSV(c.Request.Context(), part)
+		if err != nil {
+			respondServerError(c, err)
+			return
+		}
+
+		respondOK(c, result)
+		return
+	}
+}
+
+// importTagsFromCSV 解析 CSV 并分批写入标签
+func importTagsFromCSV(ctx context.Context, r io.Reader) (*ImportTagsResult, error) {
+	result := &ImportTagsResult{Errors: []*ImportRowError{}}
+
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	seen := make(map[string]bool)
+	batch := make([]string, 0, importBatchSize)
+	row := 0
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.Errors = append(result.Errors, &ImportRowError{Row: parseErr.Line, Reason: parseErr.Err.Error()})
+				continue
+			}
+			if isRequestBodyTooLarge(err) {
+				return nil, newAPIError(http.StatusRequestEntityTooLarge, err.Error())
+			}
+			return nil, newAPIError(http.StatusBadRequest, err.Error())
+		}
+
+		// 跳过表头
+		if row == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "name") {
+			continue
+		}
+
+		name, err := validateTagName(record[0])
+		if err != nil {
+			result.Errors = append(result.Errors, &ImportRowError{Row: row, Reason: err.Error()})
+			continue
+		}
+
+		// 文件内重复的名称，大小写或组合方式不同也视为重复
+		normalizedName := NormalizeTagName(name)
+		if seen[normalizedName] {
+			result.SkippedDuplicates++
+			continue
+		}
+		seen[normalizedName] = true
+
+		batch = append(batch, name)
+		if len(batch) == importBatchSize {
+			if err := importTagBatch(ctx, batch, result); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := importTagBatch(ctx, batch, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ImportTagLine NDJSON 导入时每行的内容，与导出的格式兼容，未传入的可选字段保持不变
+type ImportTagLine struct {
+	TagID       int     `json:"tag_id"`
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	Color       *string `json:"color"`
+	Category    *string `json:"category"`
+}
+
+// ImportNDJSONResult NDJSON 导入的结果，Errors 中的 row 为行号
+type ImportNDJSONResult struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Errors  []*ImportRowError `json:"errors"`
+}
+
+// importLine 带行号的导入行
+type importLine struct {
+	Row  int
+	Line *ImportTagLine
+}
+
+// optionalTagFields 返回导入行中传入的可选字段，key 为字段名
+func (l *ImportTagLine) optionalTagFields() map[string]*string {
+	return map[string]*string{
+		"description": l.Description,
+		"color":       l.Color,
+		"category":    l.Category,
+	}
+}
+
+// validateImportTagLine 校验导入行，返回处理后的名称
+func validateImportTagLine(line *ImportTagLine) (string, error) {
+	if line.TagID < 0 {
+		return "", newAPIError(http.StatusBadRequest, "invalid tag_id")
+	}
+
+	name, err := validateTagName(line.Name)
+	if err != nil {
+		return "", err
+	}
+
+	for field, value := range line.optionalTagFields() {
+		if value != nil && utf8.RuneCountInString(*value) > tagFieldMaxLength[field] {
+			return "", newAPIError(http.StatusBadRequest, fmt.Sprintf("%s too long, max %d characters", field, tagFieldMaxLength[field]))
+		}
+	}
+	return name, nil
+}
+
+// upsertImportLineTx 在事务中写入一行，existing 为同名或同 ID 的已有标签，不存在时为 nil。
+// 返回写入的标签 ID 以及是否为新创建
+func upsertImportLineTx(ctx context.Context, tx *sqlx.Tx, line *ImportTagLine, existing *Tag) (int, bool, error) {
+	tenantID := TenantIDFromContext(ctx)
+	fields := line.optionalTagFields()
+
+	if existing == nil {
+		columns := []string{"tenant_id", "name", "display_name"}
+		args := []interface{}{tenantID, NormalizeTagName(line.Name), line.Name}
+		if line.TagID > 0 {
+			columns = append(columns, "id")
+			args = append(args, line.TagID)
+		}
+		for _, field := range []string{"description", "color", "category"} {
+			if fields[field] != nil {
+				columns = append(columns, field)
+				args = append(args, *fields[field])
+			}
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		result, execErr := txExec(ctx, tx, "insert into tag_tbl ("+strings.Join(columns, ", ")+") values ("+placeholders+")", args...)
+		if isDuplicateKeyErr(execErr) {
+			return 0, false, newAPIError(http.StatusConflict, "tag already exists")
+		}
+		if execErr != nil {
+			return 0, false, execErr
+		}
+
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return 0, false, err
+		}
+		return int(tagID), true, nil
+	}
+
+	sets := []string{"name = ?", "display_name = ?"}
+	args := []interface{}{NormalizeTagName(line.Name), line.Name}
+	for _, field := range []string{"description", "color", "category"} {
+		if fields[field] != nil {
+			sets = append(sets, field+" = ?")
+			args = append(args, *fields[field])
+		}
+	}
+	sets = append(sets, "version = version + 1")
+	args = append(args, tenantID, existing.TagID)
+
+	_, execErr := txExec(ctx, tx, "update tag_tbl set "+strings.Join(sets, ", ")+" where tenant_id = ? and id = ?", args...)
+	if isDuplicateKeyErr(execErr) {
+		return 0, false, newAPIError(http.StatusConflict, "tag name already exists")
+	}
+	if execErr != nil {
+		return 0, false, execErr
+	}
+	return existing.TagID, false, nil
+}
+
+// importNDJSONBatch 在一个事务中写入一批导入行，并更新导入结果。
+// 带 tag_id 的行按 ID 更新或以该 ID 创建，不带 tag_id 的行按名称更新或创建
+func importNDJSONBatch(ctx context.Context, lines []*importLine, result *ImportNDJSONResult) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	tagIDs := []int{}
+	names := []string{}
+	for _, line := range lines {
+		if line.Line.TagID > 0 {
+			tagIDs = append(tagIDs, line.Line.TagID)
+		} else {
+			names = append(names, NormalizeTagName(line.Line.Name))
+		}
+	}
+
+	createdIDs, updatedIDs := []int{}, []int{}
+	rowErrors := []*ImportRowError{}
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		// 预先查询本批次涉及的已有标签，标签 ID 全局唯一，需要检查是否属于当前租户
+		byID := map[int]*Tag{}
+		if len(tagIDs) > 0 {
+			query, args, err := sqlx.In("select "+tagColumns+" from tag_tbl where id in (?)", tagIDs)
+			if err != nil {
+				return err
+			}
+			tags := []*Tag{}
+			if err := txSelect(ctx, tx, &tags, query, args...); err != nil {
+				return err
+			}
+			for _, tag := range tags {
+				byID[tag.TagID] = tag
+			}
+		}
+
+		byName := map[string]*Tag{}
+		if len(names) > 0 {
+			query, args, err := sqlx.In("select "+tagColumns+" from tag_tbl where tenant_id = ? and name in (?)", TenantIDFromContext(ctx), names)
+			if err != nil {
+				return err
+			}
+			tags := []*Tag{}
+			if err := txSelect(ctx, tx, &tags, query, args...); err != nil {
+				return err
+			}
+			for _, tag := range tags {
+				byName[tag.Name] = tag
+			}
+		}
+
+		for _, line := range lines {
+			var existing *Tag
+			if line.Line.TagID > 0 {
+				existing = byID[line.Line.TagID]
+				if existing != nil && existing.TenantID != TenantIDFromContext(ctx) {
+					rowErrors = append(rowErrors, &ImportRowError{Row: line.Row, Reason: "tag_id conflict"})
+					continue
+				}
+			} else {
+				existing = byName[NormalizeTagName(line.Line.Name)]
+			}
+
+			// 单条语句失败只会回滚该语句，不影响事务中的其它行
+			tagID, isNew, err := upsertImportLineTx(ctx, tx, line.Line, existing)
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				rowErrors = append(rowErrors, &ImportRowError{Row: line.Row, Reason: apiErr.Message})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if isNew {
+				createdIDs = append(createdIDs, tagID)
+				// 同一批次中后面的同名行更新这一行
+				if line.Line.TagID == 0 {
+					byName[NormalizeTagName(line.Line.Name)] = &Tag{TagID: tagID, TenantID: TenantIDFromContext(ctx), Name: NormalizeTagName(line.Line.Name)}
+				}
+			} else {
+				updatedIDs = append(updatedIDs, tagID)
+			}
+		}
+
+		if len(createdIDs) == 0 && len(updatedIDs) == 0 {
+			return nil
+		}
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagImport,
+			EntityType: AuditEntityTypeTag,
+			Metadata:   gin.H{"created": createdIDs, "updated": updatedIDs},
+		})
+	})
+	if txErr != nil {
+		return txErr
+	}
+
+	InvalidateCachedTags(ctx, updatedIDs...)
+	result.Created += len(createdIDs)
+	result.Updated += len(updatedIDs)
+	result.Failed += len(rowErrors)
+	result.Errors = append(result.Errors, rowErrors...)
+
+	touchedIDs := append(createdIDs, updatedIDs...)
+	if len(touchedIDs) == 0 {
+		return nil
+	}
+
+	// 读取写入后的标签，更新 ES 索引
+	tags := []*Tag{}
+	query, args, err := sqlx.In("select "+tagColumns+" from tag_tbl where id in (?) and deleted_at is null", touchedIDs)
+	if err != nil {
+		return err
+	}
+	if err := dbSelect(ctx, &tags, query, args...); err != nil {
+		return err
+	}
+
+	go func() {
+		for _, tag := range tags {
+			ReportTagToES(ctx, config.ESIndex, tag)
+		}
+	}()
+
+	return nil
+}
+
+// OnImportTags 导入 NDJSON 格式的标签，每行为 {"tag_id": 1, "name": "..."}，tag_id 可选，
+// 可以直接导入导出接口生成的文件。格式错误或写入失败的行会被跳过并记录行号
+// @Summary 通过 NDJSON 导入标签
+// @Tags tag
+// @Accept application/x-ndjson
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body ImportTagLine true "每行一个标签"
+// @Success 200 {object} APIResponse{data=ImportNDJSONResult}
+// @Failure 400 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tags/import [post]
+func OnImportTags(c *gin.Context) {
+	result, err := importTagsFromNDJSON(c.Request.Context(), c.Request.Body)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, result)
+}
+
+// importTagsFromNDJSON 逐行解析 NDJSON 并分批写入标签
+func importTagsFromNDJSON(ctx context.Context, r io.Reader) (*ImportNDJSONResult, error) {
+	result := &ImportNDJSONResult{Errors: []*ImportRowError{}}
+
+	scanner := bufio.NewScanner(r)
+	batch := make([]*importLine, 0, importBatchSize)
+	row := 0
+	for scanner.Scan() {
+		row++
+
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		line := &ImportTagLine{}
+		if err := json.Unmarshal(text, line); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, &ImportRowError{Row: row, Reason: "invalid json"})
+			continue
+		}
+
+		name, err := validateImportTagLine(line)
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, &ImportRowError{Row: row, Reason: err.Error()})
+			continue
+		}
+		line.Name = name
+
+		batch = append(batch, &importLine{Row: row, Line: line})
+		if len(batch) == importBatchSize {
+			if err := importNDJSONBatch(ctx, batch, result); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if isRequestBodyTooLarge(err) {
+			return nil, newAPIError(http.StatusRequestEntityTooLarge, err.Error())
+		}
+		return nil, newAPIError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := importNDJSONBatch(ctx, batch, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxLinkEntities 一次请求最多可以关联的实体数量
+const maxLinkEntities = 100000
+
+// linkEntitiesChunkSize 每个事务写入的关联数量，避免大批量请求长时间持有一个大事务
+const linkEntitiesChunkSize = 500
+
+// LinkEntitiesReqBody 把一个标签关联到多个实体的请求体
+type LinkEntitiesReqBody struct {
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	EntityIDs  []int  `json:"entity_ids"`
+}
+
+// LinkEntityError 单个实体的错误
+type LinkEntityError struct {
+	EntityID int    `json:"entity_id"`
+	Error    string `json:"error"`
+}
+
+// LinkEntitiesResult 把一个标签关联到多个实体的结果
+type LinkEntitiesResult struct {
+	TagID      int    `json:"tag_id"`
+	EntityType string `json:"entity_type"`
+	// Created 新写入的关联数量
+	Created int `json:"created"`
+	// Existed 已经存在的关联数量
+	Existed int                `json:"existed"`
+	Errors  []*LinkEntityError `json:"errors"`
+}
+
+// entityTagPosition 实体关联的最大 position
+type entityTagPosition struct {
+	EntityID int `db:"entity_id"`
+	Position int `db:"position"`
+}
+
+// insertTagEntityLinksTx 在事务中使用一条多行 insert 把标签关联到 entityIDs，返回新写入和恢复的关联数量。
+// 新关联排在每个实体已有标签的后面，来源为 api，操作人为 JWT 中的操作人，取消后还没有被清理的关联会被恢复。
+// 调用前需要通过 lockEntitiesTx 锁住这些实体
+func insertTagEntityLinksTx(ctx context.Context, tx *sqlx.Tx, entityType string, tagID int, entityIDs []int) (int, error) {
+	query, args, err := sqlx.In(
+		"select entity_id, max(position) as position from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id in (?) and deleted_at is null group by entity_id",
+		TenantIDFromContext(ctx), entityType, entityIDs,
+	)
+	if err != nil {
+		return 0, err
+	}
+	maxPositions := []*entityTagPosition{}
+	if err := txSelect(ctx, tx, &maxPositions, query, args...); err != nil {
+		return 0, err
+	}
+	nextPosition := make(map[int]int, len(maxPositions))
+	for _, p := range maxPositions {
+		nextPosition[p.EntityID] = p.Position
+	}
+
+	// 实体已经锁住，不在 linked 中的实体都会新建关联
+	query, args, err = sqlx.In(
+		"select entity_id from entity_tag_tbl where tenant_id = ? and entity_type = ? and tag_id = ? and entity_id in (?) and deleted_at is null",
+		TenantIDFromContext(ctx), entityType, tagID, entityIDs,
+	)
+	if err != nil {
+		return 0, err
+	}
+	linkedEntityIDs := []int{}
+	if err := txSelect(ctx, tx, &linkedEntityIDs, query, args...); err != nil {
+		return 0, err
+	}
+	linked := make(map[int]bool, len(linkedEntityIDs))
+	for _, entityID := range linkedEntityIDs {
+		linked[entityID] = true
+	}
+	added := make([]int, 0, len(entityIDs)-len(linkedEntityIDs))
+	for _, entityID := range entityIDs {
+		if !linked[entityID] {
+			added = append(added, entityID)
+		}
+	}
+
+	placements := make([]entityTagPlacement, 0, len(added))
+	for _, entityID := range added {
+		placements = append(placements, entityTagPlacement{EntityID: entityID, TagID: tagID, Position: nextPosition[entityID] + 1})
+	}
+	restored, err := restoreDeletedLinksTx(ctx, tx, entityType, placements)
+	if err != nil {
+		return 0, err
+	}
+
+	placeholders := make([]string, 0, len(entityIDs))
+	insertArgs := make([]interface{}, 0, len(entityIDs)*6)
+	for _, entityID := range entityIDs {
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?)")
+		insertArgs = append(insertArgs, TenantIDFromContext(ctx), entityType, entityID, tagID, nextPosition[entityID]+1, ActorIDFromContext(ctx))
+	}
+
+	// insert ignore 跳过已经存在和刚恢复的关联，影响的行数即为新写入的数量，并发写入同一关联时也不会重复计数
+	execResult, execErr := txExec(
+		ctx, tx,
+		"insert ignore into entity_tag_tbl (tenant_id, entity_type, entity_id, tag_id, position, added_by) values "+strings.Join(placeholders, ", "),
+		insertArgs...,
+	)
+	if execErr != nil {
+		return 0, execErr
+	}
+
+	created, err := execResult.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return restored + int(created), recordEntityTagChangesTx(ctx, tx, EntityTagChangeLink, entityType, tagEntityRefs(tagID, added))
+}
+
+// entityTagCount 实体关联的标签数量
+type entityTagCount struct {
+	EntityID int `db:"entity_id"`
+	Count    int `db:"count"`
+}
+
+// selectEntitiesAtTagLimitTx 在事务中查询 entityIDs 里还没有关联 tagID、关联的标签数量已经达到
+// config.MaxTagsPerEntity 的实体，已删除的标签不计入。调用前需要通过 lockEntitiesTx 锁住这些实体
+func selectEntitiesAtTagLimitTx(ctx context.Context, tx *sqlx.Tx, entityType string, tagID int, entityIDs []int) ([]*entityTagCount, error) {
+	query, args, err := sqlx.In(
+		`select et.entity_id, count(*) as count from entity_tag_tbl et
+		join tag_tbl t on t.id = et.tag_id and t.deleted_at is null
+		where et.tenant_id = ? and et.entity_type = ? and et.entity_id in (?) and et.deleted_at is null
+		group by et.entity_id having count(*) >= ? and sum(et.tag_id = ?) = 0`,
+		TenantIDFromContext(ctx), entityType, entityIDs, config.MaxTagsPerEntity, tagID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := []*entityTagCount{}
+	if err := txSelect(ctx, tx, &counts, query, args...); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// LinkTagEntities 把标签关联到 entityIDs 中的所有实体，每 linkEntitiesChunkSize 个实体提交一次事务。
+// 中途失败时之前的批次已经提交，重新请求时这些关联会计入 Existed。
+// 关联后会超过 MAX_TAGS_PER_ENTITY 的实体记录在 Errors 中，不影响其它实体
+func LinkTagEntities(ctx context.Context, tagID int, entityType string, entityIDs []int) (*LinkEntitiesResult, error) {
+	result := &LinkEntitiesResult{TagID: tagID, EntityType: entityType, Errors: []*LinkEntityError{}}
+
+	// 标签只需要校验一次
+	if _, queryErr := GetTagByID(ctx, tagID); queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			return nil, newAPIError(http.StatusNotFound, "tag not found")
+		}
+		return nil, queryErr
+	}
+
+	validIDs := make([]int, 0, len(entityIDs))
+	seen := make(map[int]bool, len(entityIDs))
+	for _, entityID := range entityIDs {
+		if entityID <= 0 {
+			result.Errors = append(result.Errors, &LinkEntityError{EntityID: entityID, Error: "invalid entity_id"})
+			continue
+		}
+		if seen[entityID] {
+			continue
+		}
+		seen[entityID] = true
+		validIDs = append(validIDs, entityID)
+	}
+
+	for start := 0; start < len(validIDs); start += linkEntitiesChunkSize {
+		end := start + linkEntitiesChunkSize
+		if end > len(validIDs) {
+			end = len(validIDs)
+		}
+		chunk := validIDs[start:end]
+
+		var created int
+		var inserting []int
+		var chunkErrors []*LinkEntityError
+		txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+			inserting, chunkErrors = chunk, nil
+
+			// 锁住这一批实体后再统计关联的标签数量和 position，避免和单个关联的请求并发时超过上限或得到相同的 position
+			if err := lockEntitiesTx(ctx, tx, entityType, chunk); err != nil {
+				return err
+			}
+			if config.MaxTagsPerEntity > 0 {
+				counts, err := selectEntitiesAtTagLimitTx(ctx, tx, entityType, tagID, chunk)
+				if err != nil {
+					return err
+				}
+				if len(counts) > 0 {
+					full := make(map[int]bool, len(counts))
+					for _, count := range counts {
+						full[count.EntityID] = true
+						chunkErrors = append(chunkErrors, &LinkEntityError{
+							EntityID: count.EntityID,
+							Error:    fmt.Sprintf("entity tag limit exceeded, count %d, limit %d", count.Count, config.MaxTagsPerEntity),
+						})
+					}
+					inserting = make([]int, 0, len(chunk)-len(counts))
+					for _, entityID := range chunk {
+						if !full[entityID] {
+							inserting = append(inserting, entityID)
+						}
+					}
+				}
+			}
+			if len(inserting) == 0 {
+				return nil
+			}
+
+			var err error
+			if created, err = insertTagEntityLinksTx(ctx, tx, entityType, tagID, inserting); err != nil {
+				return err
+			}
+			if created == 0 {
+				return nil
+			}
+
+			return InsertAuditLogTx(ctx, tx, &AuditLog{
+				Action:     AuditActionTagLinkEntities,
+				EntityType: AuditEntityTypeTag,
+				EntityID:   tagID,
+				Metadata:   gin.H{"entity_type": entityType, "entity_ids": inserting, "created": created},
+			})
+		})
+		if txErr != nil {
+			return nil, txErr
+		}
+
+		result.Created += created
+		result.Existed += len(inserting) - created
+		result.Errors = append(result.Errors, chunkErrors...)
+	}
+
+	return result, nil
+}
+
+// OnLinkEntitiesToTag 把一个标签关联到多个实体，entity_ids 最多 maxLinkEntities 个，
+// 不合法的 entity_id 记录在 errors 中，不影响其它实体
+// @Summary 把标签关联到多个实体
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param body body LinkEntitiesReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=LinkEntitiesResult}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/{id}/link_entities [post]
+func OnLinkEntitiesToTag(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody LinkEntitiesReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	if len(reqBody.EntityIDs) == 0 {
+		respondError(c, http.StatusBadRequest, "entity_ids is required")
+		return
+	}
+	if len(reqBody.EntityIDs) > maxLinkEntities {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("too many entity_ids, max %d", maxLinkEntities))
+		return
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	result, err := LinkTagEntities(c.Request.Context(), tagID, entityType, reqBody.EntityIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, result)
+}
+
+// maxBatchUnlinkEntities 批量取消关联时每次请求最多可以传入的实体数量，所有实体在一个事务中处理
+const maxBatchUnlinkEntities = 1000
+
+// BatchUnlinkTagReqBody 批量取消标签与实体关联的请求体
+type BatchUnlinkTagReqBody struct {
+	TagID int `json:"tag_id"`
+	// TagName 按名称指定标签，可以代替 tag_id，与 tag_id 同时传入时必须是同一个标签
+	TagName string `json:"tag_name"`
+	// EntityType 实体类型，不传时使用 DEFAULT_ENTITY_TYPE
+	EntityType string `json:"entity_type"`
+	EntityIDs  []int  `json:"entity_ids"`
+}
+
+// UnlinkTagEntities 在一个事务中取消标签与 entityIDs 中实体的关联，返回实际取消的实体 ID 和关联 ID，没有关联的实体会被忽略
+func UnlinkTagEntities(ctx context.Context, tagID int, entityType string, entityIDs []int) ([]int, []int, error) {
+	if _, queryErr := GetTagByID(ctx, tagID); queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			return nil, nil, newAPIError(http.StatusNotFound, "tag not found")
+		}
+		return nil, nil, queryErr
+	}
+
+	tenantID := TenantIDFromContext(ctx)
+	removed, removedLinkIDs := []int{}, []int{}
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		query, args, err := sqlx.In(
+			"select entity_id from entity_tag_tbl where tenant_id = ? and entity_type = ? and tag_id = ? and entity_id in (?) and deleted_at is null order by entity_id for update",
+			tenantID, entityType, tagID, entityIDs,
+		)
+		if err != nil {
+			return err
+		}
+		if selectErr := txSelect(ctx, tx, &removed, query, args...); selectErr != nil {
+			return selectErr
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+
+		if removedLinkIDs, err = softDeleteEntityLinksTx(ctx, tx, entityType, tagEntityRefs(tagID, removed)); err != nil {
+			return err
+		}
+		if err := recordEntityTagChangesTx(ctx, tx, EntityTagChangeUnlink, entityType, tagEntityRefs(tagID, removed)); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagUnlinkEntities,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   tagID,
+			Metadata:   gin.H{"entity_type": entityType, "entity_ids": removed},
+		})
+	})
+	if txErr != nil {
+		return nil, nil, txErr
+	}
+	return removed, removedLinkIDs, nil
+}
+
+// OnBatchUnlinkTag 批量取消标签与实体的关联，用于下线标签前从实体上摘除。
+// 标签可以通过 tag_id 或 tag_name 指定，返回的 link_ids 在 LINK_RESTORE_WINDOW 内可以恢复
+// @Summary 批量取消标签与实体的关联
+// @Tags entity
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body BatchUnlinkTagReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{tag_id=int,entity_type=string,removed=int,link_ids=[]int}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/batch_unlink [post]
+func OnBatchUnlinkTag(c *gin.Context) {
+	var reqBody BatchUnlinkTagReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	if len(reqBody.EntityIDs) == 0 {
+		respondError(c, http.StatusBadRequest, "entity_ids is required")
+		return
+	}
+	if len(reqBody.EntityIDs) > maxBatchUnlinkEntities {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("too many entity_ids, max %d", maxBatchUnlinkEntities))
+		return
+	}
+	for _, entityID := range reqBody.EntityIDs {
+		if entityID <= 0 {
+			respondError(c, http.StatusBadRequest, "invalid entity_ids")
+			return
+		}
+	}
+
+	entityType, err := validateEntityType(reqBody.EntityType)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tagID, err := ResolveTagID(c.Request.Context(), reqBody.TagID, reqBody.TagName)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	removed, removedLinkIDs, err := UnlinkTagEntities(c.Request.Context(), tagID, entityType, reqBody.EntityIDs)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"tag_id":      tagID,
+		"entity_type": entityType,
+		"removed":     len(removed),
+		"link_ids":    removedLinkIDs,
+	})
+}
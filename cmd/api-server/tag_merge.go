@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// MergeTagsReqBody 合并标签的请求体
+type MergeTagsReqBody struct {
+	SourceTagID int `json:"source_tag_id"`
+	TargetTagID int `json:"target_tag_id"`
+}
+
+// MergeTagsResult 合并标签的结果
+type MergeTagsResult struct {
+	SourceTagID int `json:"source_tag_id"`
+	TargetTagID int `json:"target_tag_id"`
+	// Reassigned 从源标签转移到目标标签的关联数量
+	Reassigned int64 `json:"reassigned"`
+	// Duplicates 实体已经关联了目标标签，直接删除的关联数量
+	Duplicates int64 `json:"duplicates"`
+}
+
+// MergeTags 把源标签的所有实体关联和同义词转移到目标标签，实体已经关联了目标标签时删除重复的关联，
+// 然后软删除源标签。所有修改在一个事务中完成，提交后从 ES 删除源标签并重新上报目标标签
+func MergeTags(ctx context.Context, sourceTagID, targetTagID int) (*MergeTagsResult, error) {
+	if sourceTagID <= 0 || targetTagID <= 0 {
+		return nil, newAPIError(http.StatusBadRequest, "invalid tag_id")
+	}
+	if sourceTagID == targetTagID {
+		return nil, newAPIError(http.StatusBadRequest, "source_tag_id and target_tag_id must be different")
+	}
+
+	tenantID := TenantIDFromContext(ctx)
+	result := &MergeTagsResult{SourceTagID: sourceTagID, TargetTagID: targetTagID}
+	var target Tag
+
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		// 按 ID 顺序锁定两个标签，避免并发合并时死锁
+		query, args, err := sqlx.In(
+			"select "+tagColumns+" from tag_tbl where tenant_id = ? and id in (?) and deleted_at is null order by id for update",
+			tenantID, []int{sourceTagID, targetTagID},
+		)
+		if err != nil {
+			return err
+		}
+
+		var tags []*Tag
+		if queryErr := txSelect(ctx, tx, &tags, query, args...); queryErr != nil {
+			return queryErr
+		}
+
+		var source *Tag
+		for _, tag := range tags {
+			switch tag.TagID {
+			case sourceTagID:
+				source = tag
+			case targetTagID:
+				target = *tag
+			}
+		}
+		if source == nil {
+			return newAPIError(http.StatusNotFound, "source tag not found")
+		}
+		if target.TagID == 0 {
+			return newAPIError(http.StatusNotFound, "target tag not found")
+		}
+
+		// 源标签的关联都会被删除，没有关联目标标签的实体会新建与目标标签的关联，在修改之前记录这些变更
+		_, execErr := txExec(
+			ctx, tx,
+			`insert into entity_tag_change_tbl (tenant_id, entity_type, entity_id, tag_id, change_type)
+			select tenant_id, entity_type, entity_id, ?, ? from entity_tag_tbl where tenant_id = ? and tag_id = ? and deleted_at is null`,
+			sourceTagID, EntityTagChangeUnlink, tenantID, sourceTagID,
+		)
+		if execErr != nil {
+			return execErr
+		}
+		_, execErr = txExec(
+			ctx, tx,
+			`insert into entity_tag_change_tbl (tenant_id, entity_type, entity_id, tag_id, change_type)
+			select s.tenant_id, s.entity_type, s.entity_id, ?, ? from entity_tag_tbl s
+			where s.tenant_id = ? and s.tag_id = ? and s.deleted_at is null and not exists (
+				select 1 from entity_tag_tbl t
+				where t.tenant_id = s.tenant_id and t.entity_type = s.entity_type and t.entity_id = s.entity_id and t.tag_id = ? and t.deleted_at is null
+			)`,
+			targetTagID, EntityTagChangeLink, tenantID, sourceTagID, targetTagID,
+		)
+		if execErr != nil {
+			return execErr
+		}
+
+		// 同样为每个实体写入审计记录，转移到目标标签的关联保留原来的来源
+		actorID := ActorIDFromContext(ctx)
+		_, execErr = txExec(
+			ctx, tx,
+			`insert into entity_tag_audit_tbl (tenant_id, entity_type, entity_id, tag_id, action, actor_id, source)
+			select tenant_id, entity_type, entity_id, tag_id, ?, ?, source from entity_tag_tbl where tenant_id = ? and tag_id = ? and deleted_at is null order by id`,
+			EntityTagChangeUnlink, actorID, tenantID, sourceTagID,
+		)
+		if execErr != nil {
+			return execErr
+		}
+		_, execErr = txExec(
+			ctx, tx,
+			`insert into entity_tag_audit_tbl (tenant_id, entity_type, entity_id, tag_id, action, actor_id, source)
+			select s.tenant_id, s.entity_type, s.entity_id, ?, ?, ?, s.source from entity_tag_tbl s
+			where s.tenant_id = ? and s.tag_id = ? and s.deleted_at is null and not exists (
+				select 1 from entity_tag_tbl t
+				where t.tenant_id = s.tenant_id and t.entity_type = s.entity_type and t.entity_id = s.entity_id and t.tag_id = ? and t.deleted_at is null
+			) order by s.id`,
+			targetTagID, EntityTagChangeLink, actorID, tenantID, sourceTagID, targetTagID,
+		)
+		if execErr != nil {
+			return execErr
+		}
+
+		// 实体与目标标签的关联已经取消、与源标签的关联还存在时，删除取消的关联，源标签的关联转移后不会违反唯一键
+		_, execErr = txExec(
+			ctx, tx,
+			`delete t from entity_tag_tbl t
+			join entity_tag_tbl s on s.tenant_id = t.tenant_id and s.entity_type = t.entity_type and s.entity_id = t.entity_id and s.tag_id = ? and s.deleted_at is null
+			where t.tenant_id = ? and t.tag_id = ? and t.deleted_at is not null`,
+			sourceTagID, tenantID, targetTagID,
+		)
+		if execErr != nil {
+			return execErr
+		}
+
+		// 实体已经关联了目标标签时，update ignore 会跳过违反唯一键的行，这些行随后删除。
+		// 与源标签取消的关联同样转移到目标标签，在可以恢复的时间内仍然可以恢复
+		execResult, execErr := txExec(
+			ctx, tx,
+			"update ignore entity_tag_tbl set tag_id = ? where tenant_id = ? and tag_id = ?",
+			targetTagID, tenantID, sourceTagID,
+		)
+		if execErr != nil {
+			return execErr
+		}
+		if result.Reassigned, err = execResult.RowsAffected(); err != nil {
+			return err
+		}
+
+		execResult, execErr = txExec(ctx, tx, "delete from entity_tag_tbl where tenant_id = ? and tag_id = ?", tenantID, sourceTagID)
+		if execErr != nil {
+			return execErr
+		}
+		if result.Duplicates, err = execResult.RowsAffected(); err != nil {
+			return err
+		}
+
+		// 同义词在租户内唯一，可以直接转移到目标标签
+		if _, execErr := txExec(ctx, tx, "update tag_synonym_tbl set tag_id = ? where tag_id = ?", targetTagID, sourceTagID); execErr != nil {
+			return execErr
+		}
+
+		if _, execErr := txExec(ctx, tx, "update tag_tbl set deleted_at = now() where id = ?", sourceTagID); execErr != nil {
+			return execErr
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagMerge,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   sourceTagID,
+			Metadata: gin.H{
+				"before":        source,
+				"target_tag_id": targetTagID,
+				"reassigned":    result.Reassigned,
+				"duplicates":    result.Duplicates,
+			},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	// 目标标签的实体数量变为两个标签之和，不等待缓存过期
+	InvalidateTagEntityCounts(ctx, sourceTagID, targetTagID)
+	InvalidateCachedTags(ctx, sourceTagID)
+
+	go DeleteTagFromES(config.ESIndex, TenantIDFromContext(ctx), sourceTagID)
+	go ReportTagToES(ctx, config.ESIndex, &target)
+	return result, nil
+}
+
+// OnMergeTags 合并两个标签，源标签的实体关联转移到目标标签后删除源标签
+// @Summary 合并标签
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param body body MergeTagsReqBody true "请求体"
+// @Security BearerAuth
+// @Success 200 {object} APIResponse{data=MergeTagsResult}
+// @Failure 400 {object} APIResponse
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/admin/tag/merge [post]
+func OnMergeTags(c *gin.Context) {
+	var reqBody MergeTagsReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	result, err := MergeTags(c.Request.Context(), reqBody.SourceTagID, reqBody.TargetTagID)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, result)
+}
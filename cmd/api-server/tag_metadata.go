@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxTagMetadataKeys 每个标签最多的元数据键数量
+const maxTagMetadataKeys = 20
+
+// maxTagMetadataValueLength 元数据值的最大字符数
+const maxTagMetadataValueLength = 4096
+
+// tagMetadataKeyPattern 元数据键只能包含字母、数字、下划线、点和连字符，与 tag_metadata_tbl.key 字段的长度一致
+var tagMetadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// tagMetadataRow tag_metadata_tbl 中的一行，只包含加载元数据需要的字段
+type tagMetadataRow struct {
+	TagID int    `db:"tag_id"`
+	Key   string `db:"key"`
+	Value string `db:"value"`
+}
+
+// LoadTagMetadata 查询 tags 的元数据并填充到 Metadata 中，没有元数据的标签为空 map。
+// 标签 ID 全局唯一，不需要按租户过滤，可以在没有租户信息的后台任务中调用
+func LoadTagMetadata(ctx context.Context, tags []*Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	tagsByID := make(map[int]*Tag, len(tags))
+	tagIDs := make([]int, 0, len(tags))
+	for _, tag := range tags {
+		tag.Metadata = map[string]string{}
+		tagsByID[tag.TagID] = tag
+		tagIDs = append(tagIDs, tag.TagID)
+	}
+
+	query, args, err := sqlx.In("select tag_id, `key`, value from tag_metadata_tbl where tag_id in (?)", tagIDs)
+	if err != nil {
+		return err
+	}
+
+	var rows []*tagMetadataRow
+	if err := dbSelect(ctx, &rows, query, args...); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		tagsByID[row.TagID].Metadata[row.Key] = row.Value
+	}
+	return nil
+}
+
+// bumpTagVersionTx 在事务中增加标签的版本号并重新读取标签。元数据属于标签的内容，
+// 修改后版本号和 ETag 随之变化，并发的 PATCH 会得到版本冲突
+func bumpTagVersionTx(ctx context.Context, tx *sqlx.Tx, tag *Tag) error {
+	if _, execErr := txExec(ctx, tx, "update tag_tbl set version = version + 1 where id = ?", tag.TagID); execErr != nil {
+		return execErr
+	}
+	return txGet(ctx, tx, tag, "select "+tagColumns+" from tag_tbl where id = ?", tag.TagID)
+}
+
+// SetTagMetadata 设置标签元数据中 key 的值，键已经存在时覆盖，返回带有全部元数据的标签。
+// 标签已经有 maxTagMetadataKeys 个键时添加新的键返回 422；值没有变化时不做修改
+func SetTagMetadata(ctx context.Context, tagID int, key, value string) (*Tag, error) {
+	var tag *Tag
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		if tag, err = lockTagForSynonymTx(ctx, tx, tagID); err != nil {
+			return err
+		}
+
+		var before string
+		queryErr := txGet(ctx, tx, &before, "select value from tag_metadata_tbl where tag_id = ? and `key` = ?", tagID, key)
+		if queryErr != nil && queryErr != sql.ErrNoRows {
+			return queryErr
+		}
+		if queryErr == nil && before == value {
+			return nil
+		}
+
+		if queryErr == sql.ErrNoRows {
+			var count int
+			if queryErr := txGet(ctx, tx, &count, "select count(*) from tag_metadata_tbl where tag_id = ?", tagID); queryErr != nil {
+				return queryErr
+			}
+			if count >= maxTagMetadataKeys {
+				return newAPIError(http.StatusUnprocessableEntity, fmt.Sprintf("too many metadata keys, max %d", maxTagMetadataKeys))
+			}
+		}
+
+		_, execErr := txExec(
+			ctx, tx,
+			"insert into tag_metadata_tbl (tag_id, `key`, value) values (?, ?, ?) on duplicate key update value = values(value)",
+			tagID, key, value,
+		)
+		if execErr != nil {
+			return execErr
+		}
+		if err := bumpTagVersionTx(ctx, tx, tag); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagSetMetadata,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   tagID,
+			Metadata:   gin.H{"key": key, "value": value},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	if err := LoadTagMetadata(ctx, []*Tag{tag}); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// RemoveTagMetadata 删除标签元数据中的 key，返回带有剩余元数据的标签，键不存在时返回 404
+func RemoveTagMetadata(ctx context.Context, tagID int, key string) (*Tag, error) {
+	var tag *Tag
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		if tag, err = lockTagForSynonymTx(ctx, tx, tagID); err != nil {
+			return err
+		}
+
+		result, execErr := txExec(ctx, tx, "delete from tag_metadata_tbl where tag_id = ? and `key` = ?", tagID, key)
+		if execErr != nil {
+			return execErr
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return newAPIError(http.StatusNotFound, "metadata key not found")
+		}
+		if err := bumpTagVersionTx(ctx, tx, tag); err != nil {
+			return err
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagDeleteMetadata,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   tagID,
+			Metadata:   gin.H{"key": key},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	if err := LoadTagMetadata(ctx, []*Tag{tag}); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// PutTagMetadataReqBody 设置标签元数据的请求体
+type PutTagMetadataReqBody struct {
+	// Value 元数据的值，最多 4096 个字符
+	Value *string `json:"value"`
+}
+
+// OnPutTagMetadata 设置标签的一个元数据，例如颜色代码、图标地址、展示优先级，修改后标签的版本号加 1
+// @Summary 设置标签元数据
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param key path string true "元数据的键，只能包含字母、数字、下划线、点和连字符，最多 64 个字符"
+// @Param body body PutTagMetadataReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{tag=Tag}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 413 {object} APIResponse
+// @Failure 422 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/{id}/metadata/{key} [put]
+func OnPutTagMetadata(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	key := c.Param("key")
+	if !tagMetadataKeyPattern.MatchString(key) {
+		respondError(c, http.StatusBadRequest, "invalid key")
+		return
+	}
+
+	var reqBody PutTagMetadataReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+	if reqBody.Value == nil {
+		respondError(c, http.StatusBadRequest, "invalid value")
+		return
+	}
+	if utf8.RuneCountInString(*reqBody.Value) > maxTagMetadataValueLength {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("value too long, max %d characters", maxTagMetadataValueLength))
+		return
+	}
+
+	ctx := c.Request.Context()
+	tag, err := SetTagMetadata(ctx, tagID, key, *reqBody.Value)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	InvalidateCachedTags(ctx, tagID)
+	// 更新 ES 索引中的元数据
+	go ReportTagToES(ctx, config.ESIndex, tag)
+
+	respondOK(c, gin.H{
+		"tag": tag,
+	})
+}
+
+// OnListTagMetadata 查询标签的全部元数据
+// @Summary 查询标签元数据
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Success 200 {object} APIResponse{data=object{metadata=map[string]string}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/{id}/metadata [get]
+func OnListTagMetadata(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	tag, queryErr := GetTagByID(c.Request.Context(), tagID)
+	if queryErr == sql.ErrNoRows {
+		respondError(c, http.StatusNotFound, "tag not found")
+		return
+	}
+	if queryErr != nil {
+		respondServerError(c, queryErr)
+		return
+	}
+
+	if err := LoadTagMetadata(c.Request.Context(), []*Tag{tag}); err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"metadata": tag.Metadata,
+	})
+}
+
+// OnDeleteTagMetadata 删除标签的一个元数据，修改后标签的版本号加 1
+// @Summary 删除标签元数据
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param key path string true "元数据的键"
+// @Success 200 {object} APIResponse{data=object{tag=Tag}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/{id}/metadata/{key} [delete]
+func OnDeleteTagMetadata(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	tag, err := RemoveTagMetadata(ctx, tagID, c.Param("key"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	InvalidateCachedTags(ctx, tagID)
+	// 更新 ES 索引中的元数据
+	go ReportTagToES(ctx, config.ESIndex, tag)
+
+	respondOK(c, gin.H{
+		"tag": tag,
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// tagNameBackfillBatchSize 规范化已有标签名称时每次读取的行数，按主键范围分批，不会长时间锁住 tag_tbl
+const tagNameBackfillBatchSize = 500
+
+// errTagNameNormalized 规范化名称后写入 es_outbox_tbl 的原因，ES 中的文档需要使用新的名称
+var errTagNameNormalized = errors.New("tag name normalized")
+
+// legacyTagName 规范化名称时读取的标签字段
+type legacyTagName struct {
+	TagID       int    `db:"id"`
+	TenantID    string `db:"tenant_id"`
+	Name        string `db:"name"`
+	DisplayName string `db:"display_name"`
+}
+
+// legacyTagNameHistory 规范化改名记录时读取的字段
+type legacyTagNameHistory struct {
+	ID                int    `db:"id"`
+	OldName           string `db:"old_name"`
+	OldNormalizedName string `db:"old_normalized_name"`
+}
+
+// TagNameCollision 规范化后与同一租户的其它标签重名，没有修改的标签
+type TagNameCollision struct {
+	TenantID string `json:"tenant_id"`
+	TagID    int    `json:"tag_id"`
+	// Name 规范化后的名称
+	Name string `json:"name"`
+	// ConflictTagID 已经使用该名称的标签，包括已删除的标签
+	ConflictTagID int `json:"conflict_tag_id"`
+}
+
+// NormalizeTagNamesReport 规范化已有标签名称的结果
+type NormalizeTagNamesReport struct {
+	// Scanned 检查的标签数量
+	Scanned int `json:"scanned"`
+	// Updated 改写了 name 的标签数量
+	Updated int `json:"updated"`
+	// HistoryUpdated 改写了 old_normalized_name 的改名记录数量
+	HistoryUpdated int `json:"history_updated"`
+	// Collisions 因为重名没有修改的标签
+	Collisions []*TagNameCollision `json:"collisions"`
+}
+
+// NormalizeLegacyTagNames 把所有租户标签的 name 改写为 NormalizeTagName(display_name)，并修正改名记录的 old_normalized_name。
+// 迁移只能在 SQL 中把名称转换为小写，NFC 规范化需要在这里完成。规范化后与其它标签重名时跳过并记录在 Collisions 中，
+// 改写后的标签写入 es_outbox_tbl 重新上报。已经规范化的数据不会被修改，可以重复执行
+func NormalizeLegacyTagNames(ctx context.Context) (*NormalizeTagNamesReport, error) {
+	report := &NormalizeTagNamesReport{Collisions: []*TagNameCollision{}}
+
+	lastID := 0
+	for {
+		tags := []*legacyTagName{}
+		selectErr := dbSelect(
+			ctx, &tags,
+			"select id, tenant_id, name, display_name from tag_tbl where id > ? order by id limit ?",
+			lastID, tagNameBackfillBatchSize,
+		)
+		if selectErr != nil {
+			return nil, selectErr
+		}
+		if len(tags) == 0 {
+			break
+		}
+
+		for _, tag := range tags {
+			lastID = tag.TagID
+			report.Scanned++
+
+			if err := normalizeLegacyTagName(ctx, tag, report); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	lastID = 0
+	for {
+		history := []*legacyTagNameHistory{}
+		selectErr := dbSelect(
+			ctx, &history,
+			"select id, old_name, old_normalized_name from tag_name_history_tbl where id > ? order by id limit ?",
+			lastID, tagNameBackfillBatchSize,
+		)
+		if selectErr != nil {
+			return nil, selectErr
+		}
+		if len(history) == 0 {
+			break
+		}
+
+		for _, record := range history {
+			lastID = record.ID
+
+			normalizedName := NormalizeTagName(record.OldName)
+			if normalizedName == record.OldNormalizedName {
+				continue
+			}
+			if _, execErr := dbExec(ctx, "update tag_name_history_tbl set old_normalized_name = ? where id = ?", normalizedName, record.ID); execErr != nil {
+				return nil, execErr
+			}
+			report.HistoryUpdated++
+		}
+	}
+
+	return report, nil
+}
+
+// normalizeLegacyTagName 改写一个标签的 name，重名时记录到 report.Collisions
+func normalizeLegacyTagName(ctx context.Context, tag *legacyTagName, report *NormalizeTagNamesReport) error {
+	normalizedName := NormalizeTagName(tag.DisplayName)
+	if tag.DisplayName == "" || normalizedName == tag.Name {
+		return nil
+	}
+
+	// 带上读取到的 name，标签在读取之后已经被改名时不修改
+	execResult, execErr := dbExec(ctx, "update tag_tbl set name = ? where id = ? and name = ?", normalizedName, tag.TagID, tag.Name)
+	if isDuplicateKeyErr(execErr) {
+		collision := &TagNameCollision{TenantID: tag.TenantID, TagID: tag.TagID, Name: normalizedName}
+		queryErr := dbGet(ctx, &collision.ConflictTagID, "select id from tag_tbl where tenant_id = ? and name = ?", tag.TenantID, normalizedName)
+		if queryErr != nil && queryErr != sql.ErrNoRows {
+			return queryErr
+		}
+		log.Printf("NormalizeTagNameCollision: tenant %s, tag %d, name %q, conflict tag %d", tag.TenantID, tag.TagID, normalizedName, collision.ConflictTagID)
+		report.Collisions = append(report.Collisions, collision)
+		return nil
+	}
+	if execErr != nil {
+		return execErr
+	}
+	if affected, err := execResult.RowsAffected(); err != nil || affected == 0 {
+		return err
+	}
+	report.Updated++
+
+	return EnqueueESOutbox(ctx, config.ESIndex, &Tag{TagID: tag.TagID, TenantID: tag.TenantID}, errTagNameNormalized)
+}
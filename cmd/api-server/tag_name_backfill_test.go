@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestNormalizeLegacyTagNames(t *testing.T) {
+	mock := setupMockDB(t)
+	config.ESIndex = "tags"
+
+	// 分解形式的 é（e 加组合重音符）在迁移中只能转换为小写，NFC 规范化后与组合形式相同
+	decomposed := "Cafe\u0301"
+	composed := "caf\u00e9"
+	lowered := "cafe\u0301"
+
+	tagRowColumns := []string{"id", "tenant_id", "name", "display_name"}
+	mock.ExpectQuery(regexp.QuoteMeta("select id, tenant_id, name, display_name from tag_tbl where id > ? order by id limit ?")).
+		WithArgs(0, tagNameBackfillBatchSize).
+		WillReturnRows(sqlmock.NewRows(tagRowColumns).
+			AddRow(1, "t1", "go", "Go").
+			AddRow(2, "t1", lowered, decomposed).
+			AddRow(3, "t1", composed, "Caf\u00e9").
+			AddRow(4, "t2", lowered, decomposed))
+
+	// 标签 2 规范化后与标签 3 重名
+	mock.ExpectExec(regexp.QuoteMeta("update tag_tbl set name = ? where id = ? and name = ?")).
+		WithArgs(composed, 2, lowered).
+		WillReturnError(&mysql.MySQLError{Number: mysqlErrDupEntry, Message: "Duplicate entry"})
+	mock.ExpectQuery(regexp.QuoteMeta("select id from tag_tbl where tenant_id = ? and name = ?")).
+		WithArgs("t1", composed).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	// 其它租户中没有重名的标签
+	mock.ExpectExec(regexp.QuoteMeta("update tag_tbl set name = ? where id = ? and name = ?")).
+		WithArgs(composed, 4, lowered).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("insert into es_outbox_tbl")).
+		WithArgs("t2", 4, "tags", errTagNameNormalized.Error()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectQuery(regexp.QuoteMeta("select id, tenant_id, name, display_name from tag_tbl where id > ? order by id limit ?")).
+		WithArgs(4, tagNameBackfillBatchSize).
+		WillReturnRows(sqlmock.NewRows(tagRowColumns))
+
+	historyColumns := []string{"id", "old_name", "old_normalized_name"}
+	mock.ExpectQuery(regexp.QuoteMeta("select id, old_name, old_normalized_name from tag_name_history_tbl where id > ? order by id limit ?")).
+		WithArgs(0, tagNameBackfillBatchSize).
+		WillReturnRows(sqlmock.NewRows(historyColumns).
+			AddRow(10, "Golang", "golang").
+			AddRow(11, decomposed, lowered))
+	mock.ExpectExec(regexp.QuoteMeta("update tag_name_history_tbl set old_normalized_name = ? where id = ?")).
+		WithArgs(composed, 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("select id, old_name, old_normalized_name from tag_name_history_tbl where id > ? order by id limit ?")).
+		WithArgs(11, tagNameBackfillBatchSize).
+		WillReturnRows(sqlmock.NewRows(historyColumns))
+
+	report, err := NormalizeLegacyTagNames(context.Background())
+	if err != nil {
+		t.Fatalf("NormalizeLegacyTagNames: %s", err)
+	}
+
+	if report.Scanned != 4 || report.Updated != 1 || report.HistoryUpdated != 1 {
+		t.Errorf("report = {scanned %d, updated %d, history updated %d}, want {4, 1, 1}", report.Scanned, report.Updated, report.HistoryUpdated)
+	}
+	if len(report.Collisions) != 1 {
+		t.Fatalf("got %d collisions, want 1", len(report.Collisions))
+	}
+	want := TagNameCollision{TenantID: "t1", TagID: 2, Name: composed, ConflictTagID: 3}
+	if *report.Collisions[0] != want {
+		t.Errorf("collision = %+v, want %+v", *report.Collisions[0], want)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %s", err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// relatedTagsSampleSize 计算相关标签时最多采样的实体数量，关联了大量实体的标签只统计最近关联的这部分实体
+const relatedTagsSampleSize = 10000
+
+// maxRelatedTagsLimit 相关标签最多返回的数量，缓存中保存的也是前 maxRelatedTagsLimit 个
+const maxRelatedTagsLimit = 50
+
+// relatedTagsCacheSweepSize 缓存条目超过该数量时，写入前先清理过期的条目
+const relatedTagsCacheSweepSize = 10000
+
+// RelatedTag 和指定标签同时出现在实体上的标签
+type RelatedTag struct {
+	TagID       int    `db:"tag_id" json:"tag_id"`
+	Name        string `db:"name" json:"name"`
+	DisplayName string `db:"display_name" json:"display_name"`
+	// Count 采样的实体中同时关联了两个标签的实体数量
+	Count int `db:"count" json:"count"`
+}
+
+// relatedTagsCacheEntry 缓存的相关标签
+type relatedTagsCacheEntry struct {
+	Tags []*RelatedTag
+	// Sampled 关联的实体数量超过 relatedTagsSampleSize，只统计了其中一部分
+	Sampled  bool
+	CachedAt time.Time
+}
+
+// relatedTagsCache 相关标签的进程内缓存，统计需要扫描标签关联的实体，有效期内不重复计算
+var relatedTagsCache = struct {
+	sync.Mutex
+	entries map[string]*relatedTagsCacheEntry
+}{entries: make(map[string]*relatedTagsCacheEntry)}
+
+// RelatedTags 统计和 tagID 同时关联在实体上的其它标签，按同时出现的实体数量从多到少返回前 maxRelatedTagsLimit 个，
+// 不包含 tagID 本身和已删除的标签。config.RelatedTagsCacheTTL 内会返回缓存的结果
+func RelatedTags(ctx context.Context, tagID int) (*relatedTagsCacheEntry, error) {
+	tenantID := TenantIDFromContext(ctx)
+	key := fmt.Sprintf("%s:%d", tenantID, tagID)
+	now := time.Now()
+
+	if config.RelatedTagsCacheTTL > 0 {
+		relatedTagsCache.Lock()
+		entry, ok := relatedTagsCache.entries[key]
+		if ok && now.Sub(entry.CachedAt) >= config.RelatedTagsCacheTTL {
+			delete(relatedTagsCache.entries, key)
+			ok = false
+		}
+		relatedTagsCache.Unlock()
+
+		if ok {
+			return entry, nil
+		}
+	}
+
+	// 只采样最近关联的 relatedTagsSampleSize 个实体，避免热门标签扫描上百万行
+	var sampled int
+	queryErr := dbGet(
+		ctx, &sampled,
+		"select count(*) from (select id from entity_tag_tbl where tenant_id = ? and tag_id = ? and deleted_at is null limit ?) s",
+		tenantID, tagID, relatedTagsSampleSize+1,
+	)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	tags := []*RelatedTag{}
+	queryErr = dbSelect(
+		ctx, &tags,
+		`select et.tag_id, t.name, t.display_name, count(*) as count
+		from (
+			select entity_type, entity_id from entity_tag_tbl
+			where tenant_id = ? and tag_id = ? and deleted_at is null order by id desc limit ?
+		) s
+		join entity_tag_tbl et on et.tenant_id = ? and et.entity_type = s.entity_type and et.entity_id = s.entity_id and et.deleted_at is null
+		join tag_tbl t on t.id = et.tag_id and t.tenant_id = ? and t.deleted_at is null
+		where et.tag_id <> ?
+		group by et.tag_id, t.name, t.display_name
+		order by count desc, et.tag_id
+		limit ?`,
+		tenantID, tagID, relatedTagsSampleSize,
+		tenantID, tenantID, tagID, maxRelatedTagsLimit,
+	)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	entry := &relatedTagsCacheEntry{Tags: tags, Sampled: sampled > relatedTagsSampleSize, CachedAt: now}
+	if config.RelatedTagsCacheTTL > 0 {
+		relatedTagsCache.Lock()
+		if len(relatedTagsCache.entries) >= relatedTagsCacheSweepSize {
+			for k, e := range relatedTagsCache.entries {
+				if now.Sub(e.CachedAt) >= config.RelatedTagsCacheTTL {
+					delete(relatedTagsCache.entries, k)
+				}
+			}
+		}
+		relatedTagsCache.entries[key] = entry
+		relatedTagsCache.Unlock()
+	}
+
+	return entry, nil
+}
+
+// OnRelatedTags 查询经常和该标签一起使用的标签，sampled 为 true 时结果只统计了最近关联的部分实体
+// @Summary 查询相关标签
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param limit query int false "返回的数量，默认 10，最大 50"
+// @Success 200 {object} APIResponse{data=object{tag_id=int,tags=[]RelatedTag,sampled=bool,cache_age_seconds=int}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/{id}/related [get]
+func OnRelatedTags(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	limit, ok := parseLimitQuery(c, 10, maxRelatedTagsLimit)
+	if !ok {
+		return
+	}
+
+	if _, queryErr := GetTagByID(c.Request.Context(), tagID); queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			respondError(c, http.StatusNotFound, "tag not found")
+			return
+		}
+		respondServerError(c, queryErr)
+		return
+	}
+
+	entry, err := RelatedTags(c.Request.Context(), tagID)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tags := entry.Tags
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	respondOK(c, gin.H{
+		"tag_id":            tagID,
+		"tags":              tags,
+		"sampled":           entry.Sampled,
+		"cache_age_seconds": int(time.Since(entry.CachedAt).Seconds()),
+	})
+}
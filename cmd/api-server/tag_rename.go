@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// TagNameHistory 标签改名记录
+type TagNameHistory struct {
+	HistoryID int       `db:"id" json:"history_id"`
+	TagID     int       `db:"tag_id" json:"tag_id"`
+	OldName   string    `db:"old_name" json:"old_name"`
+	NewName   string    `db:"new_name" json:"new_name"`
+	ChangedBy string    `db:"changed_by" json:"changed_by"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+}
+
+// RenameTagTx 在事务中修改标签名称并记录改名历史，返回修改后的标签。
+// 规范化后的名称没有变化时只修改展示名称，不记录历史
+func RenameTagTx(ctx context.Context, tx *sqlx.Tx, tagID int, newName, changedBy string) (*Tag, error) {
+	tenantID := TenantIDFromContext(ctx)
+
+	var tag Tag
+	queryErr := txGet(ctx, tx, &tag, "select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ? and deleted_at is null for update", tenantID, tagID)
+	if queryErr == sql.ErrNoRows {
+		return nil, newAPIError(http.StatusNotFound, "tag not found")
+	}
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	// 名称没有变化，不需要记录历史
+	if tag.DisplayName == newName {
+		return &tag, nil
+	}
+
+	// 只有大小写或组合方式不同
+	normalizedName := NormalizeTagName(newName)
+	if tag.Name == normalizedName {
+		if _, execErr := txExec(ctx, tx, "update tag_tbl set display_name = ? where id = ?", newName, tagID); execErr != nil {
+			return nil, execErr
+		}
+		tag.DisplayName = newName
+		return &tag, nil
+	}
+
+	// 新名称已经被同一租户的其它标签使用
+	var count int
+	queryErr = txGet(ctx, tx, &count, "select count(*) from tag_tbl where tenant_id = ? and name = ? and id <> ?", tenantID, normalizedName, tagID)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	if count > 0 {
+		return nil, newAPIError(http.StatusConflict, "tag name already exists")
+	}
+
+	_, execErr := txExec(ctx, tx, "update tag_tbl set name = ?, display_name = ? where id = ?", normalizedName, newName, tagID)
+	if isDuplicateKeyErr(execErr) {
+		return nil, newAPIError(http.StatusConflict, "tag name already exists")
+	}
+	if execErr != nil {
+		return nil, execErr
+	}
+
+	_, execErr = txExec(
+		ctx, tx,
+		"insert into tag_name_history_tbl (tag_id, old_name, old_normalized_name, new_name, changed_by) values (?, ?, ?, ?, ?)",
+		tagID, tag.DisplayName, tag.Name, newName, changedBy,
+	)
+	if execErr != nil {
+		return nil, execErr
+	}
+
+	// 每个标签只保留最近的 TagHistoryLimit 条改名记录
+	if config.TagHistoryLimit > 0 {
+		var keepFromID int
+		queryErr = txGet(
+			ctx, tx, &keepFromID,
+			"select id from tag_name_history_tbl where tag_id = ? order by id desc limit 1 offset ?",
+			tagID, config.TagHistoryLimit-1,
+		)
+		if queryErr != nil && queryErr != sql.ErrNoRows {
+			return nil, queryErr
+		}
+		if queryErr == nil {
+			_, execErr = txExec(ctx, tx, "delete from tag_name_history_tbl where tag_id = ? and id < ?", tagID, keepFromID)
+			if execErr != nil {
+				return nil, execErr
+			}
+		}
+	}
+
+	var renamed Tag
+	queryErr = txGet(ctx, tx, &renamed, "select "+tagColumns+" from tag_tbl where id = ?", tagID)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	return &renamed, nil
+}
+
+// RenameTagReqBody 标签改名的请求体
+type RenameTagReqBody struct {
+	Name string `json:"name"`
+	// Version 客户端读取到的版本号，也可以通过 If-Match 请求头传入
+	Version   *int   `json:"version"`
+	ChangedBy string `json:"changed_by"`
+}
+
+// OnRenameTag 标签改名，和部分更新一样通过 version 字段实现乐观锁，版本不一致时返回 409 和最新的标签
+// @Summary 标签改名
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param If-Match header string false "标签的版本号，也可以通过请求体中的 version 传入"
+// @Param body body RenameTagReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{tag=Tag}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 409 {object} APIResponse{error=APIError{detail=object{tag=Tag}}}
+// @Failure 413 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/{id}/rename [post]
+func OnRenameTag(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody RenameTagReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	version, ok := parseVersion(c, reqBody.Version)
+	if !ok {
+		return
+	}
+
+	newName, err := validateTagName(reqBody.Name)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	var tag *Tag
+	ctx := c.Request.Context()
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var before Tag
+		queryErr := txGet(ctx, tx, &before, "select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ? and deleted_at is null for update", TenantIDFromContext(ctx), tagID)
+		if queryErr == sql.ErrNoRows {
+			return newAPIError(http.StatusNotFound, "tag not found")
+		}
+		if queryErr != nil {
+			return queryErr
+		}
+
+		if before.Version != version {
+			apiErr := newAPIError(http.StatusConflict, "version conflict")
+			apiErr.Detail = gin.H{"tag": before}
+			return apiErr
+		}
+
+		result, execErr := txExec(ctx, tx, "update tag_tbl set version = version + 1 where id = ? and version = ?", tagID, version)
+		if execErr != nil {
+			return execErr
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return newAPIError(http.StatusConflict, "version conflict")
+		}
+
+		// RenameTagTx 返回的标签在版本号更新之后读取，包含新的版本号
+		var renameErr error
+		tag, renameErr = RenameTagTx(ctx, tx, tagID, newName, reqBody.ChangedBy)
+		if renameErr != nil {
+			return renameErr
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagRename,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   tagID,
+			Metadata:   gin.H{"before": before, "after": tag},
+		})
+	})
+	if txErr != nil {
+		respondServerError(c, txErr)
+		return
+	}
+
+	InvalidateCachedTags(ctx, tagID)
+	// 更新 ES 索引
+	go ReportTagToES(ctx, config.ESIndex, tag)
+
+	respondOK(c, gin.H{
+		"tag": tag,
+	})
+}
+
+// OnTagHistory 查询标签的改名记录
+// @Summary 查询标签改名记录
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Success 200 {object} APIResponse{data=object{history=[]TagNameHistory}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/{id}/history [get]
+func OnTagHistory(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if _, queryErr := GetTagByID(c.Request.Context(), tagID); queryErr != nil {
+		if queryErr == sql.ErrNoRows {
+			respondError(c, http.StatusNotFound, "tag not found")
+			return
+		}
+		respondServerError(c, queryErr)
+		return
+	}
+
+	history := []*TagNameHistory{}
+	selectErr := dbSelect(
+		c.Request.Context(),
+		&history,
+		"select id, tag_id, old_name, new_name, changed_by, changed_at from tag_name_history_tbl where tag_id = ? order by id desc",
+		tagID,
+	)
+	if selectErr != nil {
+		respondServerError(c, selectErr)
+		return
+	}
+
+	respondOK(c, gin.H{
+		"history": history,
+	})
+}
+
+// OnGetTagByName 根据名称查询标签，follow_history=true 时会通过改名记录查找旧名称对应的标签
+// @Summary 根据名称查询标签
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param name query string true "标签名称"
+// @Param follow_history query bool false "名称不存在时是否通过改名记录查找"
+// @Success 200 {object} APIResponse{data=object{tag=Tag,renamed_from=string}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/tag/by_name [get]
+func OnGetTagByName(c *gin.Context) {
+	tagName, err := validateTagName(c.Query("name"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tag, queryErr := GetTagByName(c.Request.Context(), tagName)
+	if queryErr == nil {
+		respondOK(c, gin.H{
+			"tag": tag,
+		})
+		return
+	}
+
+	if queryErr != sql.ErrNoRows {
+		respondServerError(c, queryErr)
+		return
+	}
+
+	if c.Query("follow_history") != "true" {
+		respondError(c, http.StatusNotFound, "tag not found")
+		return
+	}
+
+	// 查找当前租户最近一次使用该名称的标签，与按名称查询一样比较规范化后的名称
+	var tagID int
+	queryErr = dbGet(
+		c.Request.Context(),
+		&tagID,
+		"select h.tag_id from tag_name_history_tbl h join tag_tbl t on t.id = h.tag_id where t.tenant_id = ? and h.old_normalized_name = ? order by h.id desc limit 1",
+		TenantIDFromContext(c.Request.Context()), NormalizeTagName(tagName),
+	)
+	if queryErr == nil {
+		var currentTag *Tag
+		currentTag, queryErr = GetTagByID(c.Request.Context(), tagID)
+		if queryErr == nil {
+			respondOK(c, gin.H{
+				"tag":          currentTag,
+				"renamed_from": tagName,
+			})
+			return
+		}
+	}
+
+	if queryErr != sql.ErrNoRows {
+		respondServerError(c, queryErr)
+		return
+	}
+
+	respondError(c, http.StatusNotFound, "tag not found")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetTagByNameFollowHistoryNormalizesName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := setupMockDB(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// 当前没有叫 old café 的标签，改名记录中保存的是规范化后的旧名称
+	mock.ExpectQuery(regexp.QuoteMeta("select "+tagColumns+" from tag_tbl where tenant_id = ? and name = ?")).
+		WithArgs("t1", "old caf\u00e9").
+		WillReturnRows(sqlmock.NewRows(strings.Split(tagColumns, ", ")))
+	mock.ExpectQuery(regexp.QuoteMeta("select h.tag_id from tag_name_history_tbl h join tag_tbl t on t.id = h.tag_id where t.tenant_id = ? and h.old_normalized_name = ?")).
+		WithArgs("t1", "old caf\u00e9").
+		WillReturnRows(sqlmock.NewRows([]string{"tag_id"}).AddRow(9))
+	mock.ExpectQuery(regexp.QuoteMeta("select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ?")).
+		WithArgs("t1", 9).
+		WillReturnRows(sqlmock.NewRows(strings.Split(tagColumns, ", ")).
+			AddRow(9, "t1", "new name", "New Name", "", "", "", 2, now, now, nil, nil))
+
+	r := gin.New()
+	r.GET("/api/tag/by_name", TenantMiddleware(), OnGetTagByName)
+
+	// 大小写不同，é 使用分解形式
+	query := url.Values{"name": {"Old Cafe\u0301"}, "follow_history": {"true"}}
+	req := httptest.NewRequest(http.MethodGet, "/api/tag/by_name?"+query.Encode(), nil)
+	req.Header.Set(TenantIDHeader, "t1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	var resp struct {
+		Data struct {
+			Tag         Tag    `json:"tag"`
+			RenamedFrom string `json:"renamed_from"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal response: %s", err)
+	}
+	if resp.Data.Tag.TagID != 9 {
+		t.Errorf("tag_id = %d, want 9", resp.Data.Tag.TagID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %s", err)
+	}
+}
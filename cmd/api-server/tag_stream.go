@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxTagStreamConns 同时保持的 WebSocket 连接数上限，超过时返回 503
+	maxTagStreamConns = 1000
+	// tagStreamBroadcastBuffer 待广播的标签队列长度，队列满时丢弃新的通知
+	tagStreamBroadcastBuffer = 256
+	// tagStreamSendBuffer 每个连接待发送的消息数量，客户端读取太慢导致队列满时断开该连接
+	tagStreamSendBuffer = 64
+	// tagStreamWriteWait 写入一条消息的超时时间
+	tagStreamWriteWait = 10 * time.Second
+	// tagStreamPongWait 等待客户端 pong 的时间，超时后认为连接已经断开
+	tagStreamPongWait = 60 * time.Second
+	// tagStreamPingPeriod 发送 ping 的间隔，需要小于 tagStreamPongWait
+	tagStreamPingPeriod = tagStreamPongWait * 9 / 10
+)
+
+// tagStreamUpgrader 把 HTTP 请求升级为 WebSocket 连接，使用默认的同源检查
+var tagStreamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// tagStreamSem 限制同时保持的连接数
+var tagStreamSem = make(chan struct{}, maxTagStreamConns)
+
+// tagStreamBroadcast 新创建的标签，由 runTagStreamHub 分发给同一租户的所有连接
+var tagStreamBroadcast = make(chan *Tag, tagStreamBroadcastBuffer)
+
+// tagStreamClient 一个 WebSocket 连接
+type tagStreamClient struct {
+	tenantID string
+	send     chan []byte
+	// closeCode send 被关闭时发给客户端的关闭码，在关闭 send 之前设置
+	closeCode int
+}
+
+// tagStreamHub 当前所有连接，从 clients 中删除连接的一方负责关闭它的 send
+var tagStreamHub = struct {
+	sync.Mutex
+	clients map[*tagStreamClient]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}{clients: make(map[*tagStreamClient]struct{})}
+
+// addTagStreamClient 登记连接，服务正在关闭时返回 false
+func addTagStreamClient(client *tagStreamClient) bool {
+	tagStreamHub.Lock()
+	defer tagStreamHub.Unlock()
+
+	if tagStreamHub.closed {
+		return false
+	}
+	tagStreamHub.clients[client] = struct{}{}
+	tagStreamHub.wg.Add(1)
+	return true
+}
+
+// removeTagStreamClientLocked 删除连接并关闭它的 send，调用方需要持有 tagStreamHub 的锁
+func removeTagStreamClientLocked(client *tagStreamClient, closeCode int) {
+	if _, ok := tagStreamHub.clients[client]; !ok {
+		return
+	}
+	delete(tagStreamHub.clients, client)
+	client.closeCode = closeCode
+	close(client.send)
+}
+
+// PublishTagCreated 通知所有连接有新的标签创建，不会阻塞调用方，广播队列满时丢弃这条通知
+func PublishTagCreated(tag *Tag) {
+	select {
+	case tagStreamBroadcast <- tag:
+	default:
+		log.Printf("[WARN] TagStreamBroadcastFull: tag_id=%d", tag.TagID)
+	}
+}
+
+// StartTagStreamHub 在后台把 tagStreamBroadcast 中的标签分发给同一租户的连接
+func StartTagStreamHub() {
+	go runTagStreamHub()
+}
+
+// runTagStreamHub 分发广播的标签，连接的发送队列已满时断开该连接，避免一个慢客户端拖住所有客户端
+func runTagStreamHub() {
+	for tag := range tagStreamBroadcast {
+		message, err := json.Marshal(tag)
+		if err != nil {
+			log.Printf("TagStreamMarshalErr: %s", err)
+			continue
+		}
+
+		tagStreamHub.Lock()
+		for client := range tagStreamHub.clients {
+			if client.tenantID != tag.TenantID {
+				continue
+			}
+			select {
+			case client.send <- message:
+			default:
+				removeTagStreamClientLocked(client, websocket.ClosePolicyViolation)
+			}
+		}
+		tagStreamHub.Unlock()
+	}
+}
+
+// CloseTagStreams 服务关闭时向所有连接发送关闭帧，并等待连接退出或 ctx 结束。
+// http.Server.Shutdown 不会处理已经升级的连接，需要在它之后单独调用
+func CloseTagStreams(ctx context.Context) {
+	tagStreamHub.Lock()
+	tagStreamHub.closed = true
+	for client := range tagStreamHub.clients {
+		removeTagStreamClientLocked(client, websocket.CloseGoingAway)
+	}
+	tagStreamHub.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		tagStreamHub.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("[WARN] CloseTagStreamsTimeout: %s", ctx.Err())
+	}
+}
+
+// OnTagStream 通过 WebSocket 推送当前租户新创建的标签，每条消息是一个标签的 JSON。
+// 连接数达到 maxTagStreamConns 时返回 503
+// @Summary 订阅新创建的标签
+// @Tags tag
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Success 101 {string} string "升级为 WebSocket，每条消息为一个 Tag 的 JSON"
+// @Failure 400 {object} APIResponse
+// @Failure 503 {object} APIResponse
+// @Router /api/tag/stream [get]
+func OnTagStream(c *gin.Context) {
+	select {
+	case tagStreamSem <- struct{}{}:
+		defer func() { <-tagStreamSem }()
+	default:
+		respondError(c, http.StatusServiceUnavailable, "too many stream connections")
+		return
+	}
+
+	client := &tagStreamClient{
+		tenantID: TenantIDFromContext(c.Request.Context()),
+		send:     make(chan []byte, tagStreamSendBuffer),
+	}
+	if !addTagStreamClient(client) {
+		respondError(c, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	defer tagStreamHub.wg.Done()
+
+	// 升级失败时 Upgrader 已经写出了错误响应
+	conn, err := tagStreamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		tagStreamHub.Lock()
+		removeTagStreamClientLocked(client, websocket.CloseNormalClosure)
+		tagStreamHub.Unlock()
+		log.Printf("TagStreamUpgradeErr: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	// 客户端不会发送业务消息，读取只用于处理 pong 和关闭帧，读取失败说明连接已经断开
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(tagStreamPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(tagStreamPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(tagStreamPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message, ok := <-client.send:
+			conn.SetWriteDeadline(time.Now().Add(tagStreamWriteWait))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(client.closeCode, ""))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("TagStreamWriteErr: %s", err)
+				tagStreamHub.Lock()
+				removeTagStreamClientLocked(client, websocket.CloseAbnormalClosure)
+				tagStreamHub.Unlock()
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(tagStreamWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				tagStreamHub.Lock()
+				removeTagStreamClientLocked(client, websocket.CloseAbnormalClosure)
+				tagStreamHub.Unlock()
+				return
+			}
+		case <-disconnected:
+			tagStreamHub.Lock()
+			removeTagStreamClientLocked(client, websocket.CloseNormalClosure)
+			tagStreamHub.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxSuggestConns 同时保持的搜索建议连接数上限，超过时返回 503
+	maxSuggestConns = 500
+	// suggestHeartbeatInterval 发送心跳注释的间隔，避免代理因为长时间没有数据而断开连接
+	suggestHeartbeatInterval = 15 * time.Second
+	// suggestLimit 每次返回的搜索建议数量
+	suggestLimit = 10
+)
+
+// suggestSem 限制同时保持的搜索建议连接数
+var suggestSem = make(chan struct{}, maxSuggestConns)
+
+// suggestShutdown 服务关闭时被关闭，通知所有搜索建议连接退出
+var suggestShutdown = make(chan struct{})
+
+// closeSuggestOnce 保证 suggestShutdown 只关闭一次
+var closeSuggestOnce sync.Once
+
+// CloseSuggestStreams 结束所有搜索建议连接，注册为 http.Server 的 OnShutdown 回调，
+// 否则 Shutdown 会一直等待这些不会主动结束的请求
+func CloseSuggestStreams() {
+	closeSuggestOnce.Do(func() { close(suggestShutdown) })
+}
+
+// writeSSEEvent 写出一条 SSE 事件并立即刷新，event 为空时使用默认的 message 事件
+func writeSSEEvent(c *gin.Context, flusher http.Flusher, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if event != "" {
+		if _, err := fmt.Fprintf(c.Writer, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", payload); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// OnSuggestTags 通过 Server-Sent Events 返回以 q 开头的标签，用于输入框的搜索建议。
+// 连接建立后推送一次 {"matches": [...]}，之后每 15 秒发送一次心跳注释，直到客户端断开连接。
+// 关键字少于 SEARCH_MIN_KEYWORD_LENGTH 个字符时不查询 ES，推送空的 matches 和说明原因的 message。
+// SSE 只能由服务端向客户端推送，输入变化时客户端关闭旧的 EventSource 并使用新的 q 重新连接
+// @Summary 标签搜索建议
+// @Tags tag
+// @Produce text/event-stream
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param q query string true "输入的关键字"
+// @Success 200 {string} string "SSE 事件流，每个 data 为 {\"matches\": [Tag], \"message\": string}，message 只在关键字太短时返回"
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 503 {object} APIResponse
+// @Router /api/tag/suggest [get]
+func OnSuggestTags(c *gin.Context) {
+	keyword, err := validateSearchKeyword(c.Query("q"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid q")
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		respondError(c, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	select {
+	case suggestSem <- struct{}{}:
+		defer func() { <-suggestSem }()
+	default:
+		respondError(c, http.StatusServiceUnavailable, "too many suggest connections")
+		return
+	}
+
+	ctx := c.Request.Context()
+	// 关键字太短时不查询 ES，推送空的 matches
+	result := &SearchTagsResult{Tags: []*Tag{}}
+	message, tooShort := searchKeywordTooShort(keyword)
+	if !tooShort {
+		// 输入框中的多个词需要同时匹配，与按短语前缀匹配时的结果接近
+		opts := SearchTagsOptions{Size: suggestLimit, Match: ESSearchMatchPrefix, Mode: SearchModeAnd}
+		result, err = SearchTagsFromES(ctx, keyword, opts)
+		if errors.Is(err, ErrESUnavailable) {
+			log.Printf("[WARN] SuggestTagsFallbackToMySQL: %s", err)
+			result, err = SearchTagsFromMySQL(ctx, keyword, opts)
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// 关闭 nginx 的响应缓冲，否则事件会被攒到一起才发给客户端
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// 响应头已经写出，搜索失败时通过 error 事件通知客户端后关闭连接
+	if err != nil {
+		log.Printf("SuggestTagsErr: %s", err)
+		writeSSEEvent(c, flusher, "error", gin.H{"message": "search failed"})
+		return
+	}
+	matches := gin.H{"matches": result.Tags}
+	if tooShort {
+		matches["message"] = message
+	}
+	if err := writeSSEEvent(c, flusher, "", matches); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(suggestHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-suggestShutdown:
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// tagSuggestionsSampleRows 查找相似实体时最多读取的关联数量，平均分配给实体的每个标签，每个标签只读取最近的关联
+const tagSuggestionsSampleRows = 300
+
+// tagSuggestionsMinRowsPerTag 查找相似实体时每个标签至少读取的关联数量
+const tagSuggestionsMinRowsPerTag = 10
+
+// tagSuggestionsNeighbors 参与统计的相似实体数量，按共同的标签数量从多到少选取
+const tagSuggestionsNeighbors = 20
+
+// popularTagsSampleRows 实体没有标签时统计热门标签读取的最近关联数量
+const popularTagsSampleRows = 1000
+
+// maxTagSuggestionsLimit 推荐标签最多返回的数量，缓存中保存的也是前 maxTagSuggestionsLimit 个
+const maxTagSuggestionsLimit = 50
+
+// tagSuggestionsCacheSweepSize 缓存条目超过该数量时，写入前先清理过期的条目
+const tagSuggestionsCacheSweepSize = 10000
+
+// TagSuggestion 推荐给实体的标签
+type TagSuggestion struct {
+	TagID       int    `json:"tag_id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	// Score 根据相似实体推荐时为关联了该标签的相似实体与当前实体共同的标签数量之和，
+	// 返回热门标签时为采样的关联中该标签出现的次数
+	Score int `json:"score"`
+}
+
+// tagSuggestionsCacheEntry 缓存的推荐标签
+type tagSuggestionsCacheEntry struct {
+	Tags []*TagSuggestion
+	// Popular 实体没有关联标签，返回的是热门标签
+	Popular  bool
+	CachedAt time.Time
+}
+
+// tagSuggestionsCache 推荐标签的进程内缓存，有效期内同一个实体不重复计算
+var tagSuggestionsCache = struct {
+	sync.Mutex
+	entries map[string]*tagSuggestionsCacheEntry
+}{entries: make(map[string]*tagSuggestionsCacheEntry)}
+
+// SuggestEntityTags 根据和实体有共同标签的其它实体推荐实体还没有关联的标签，按得分从高到低返回前 maxTagSuggestionsLimit 个。
+// 只读取实体每个标签最近的部分关联和最相似的 tagSuggestionsNeighbors 个实体的关联，读取的行数有上限。
+// 实体没有关联标签时返回最近使用最多的热门标签。config.TagSuggestionsCacheTTL 内会返回缓存的结果
+func SuggestEntityTags(ctx context.Context, entityType string, entityID int) (*tagSuggestionsCacheEntry, error) {
+	key := fmt.Sprintf("%s:%s:%d", TenantIDFromContext(ctx), entityType, entityID)
+	now := time.Now()
+
+	if config.TagSuggestionsCacheTTL > 0 {
+		tagSuggestionsCache.Lock()
+		entry, ok := tagSuggestionsCache.entries[key]
+		if ok && now.Sub(entry.CachedAt) >= config.TagSuggestionsCacheTTL {
+			delete(tagSuggestionsCache.entries, key)
+			ok = false
+		}
+		tagSuggestionsCache.Unlock()
+
+		if ok {
+			return entry, nil
+		}
+	}
+
+	tenantID := TenantIDFromContext(ctx)
+	tagIDs := []int{}
+	queryErr := dbSelect(
+		ctx, &tagIDs,
+		"select tag_id from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id = ? and deleted_at is null",
+		tenantID, entityType, entityID,
+	)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	var scores map[int]int
+	var err error
+	if len(tagIDs) == 0 {
+		scores, err = popularTagScores(ctx, entityType)
+	} else {
+		scores, err = neighborTagScores(ctx, entityType, entityID, tagIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := rankTagSuggestions(ctx, scores)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &tagSuggestionsCacheEntry{Tags: tags, Popular: len(tagIDs) == 0, CachedAt: now}
+	if config.TagSuggestionsCacheTTL > 0 {
+		tagSuggestionsCache.Lock()
+		if len(tagSuggestionsCache.entries) >= tagSuggestionsCacheSweepSize {
+			for k, e := range tagSuggestionsCache.entries {
+				if now.Sub(e.CachedAt) >= config.TagSuggestionsCacheTTL {
+					delete(tagSuggestionsCache.entries, k)
+				}
+			}
+		}
+		tagSuggestionsCache.entries[key] = entry
+		tagSuggestionsCache.Unlock()
+	}
+
+	return entry, nil
+}
+
+// neighborTagScores 找出和实体共同标签最多的 tagSuggestionsNeighbors 个实体，统计这些实体关联的、当前实体还没有的标签。
+// 每个相似实体按共同的标签数量计分，标签的得分为关联了它的相似实体的分数之和
+func neighborTagScores(ctx context.Context, entityType string, entityID int, tagIDs []int) (map[int]int, error) {
+	tenantID := TenantIDFromContext(ctx)
+
+	// 每个标签按 (tenant_id, tag_id, created_at, id) 索引倒序读取最近的关联，热门标签不会扫描全部关联。
+	// 标签太多时只用其中一部分查找相似实体，保证每个标签至少读取 tagSuggestionsMinRowsPerTag 个关联
+	sampleTagIDs := tagIDs
+	if maxSampleTags := tagSuggestionsSampleRows / tagSuggestionsMinRowsPerTag; len(sampleTagIDs) > maxSampleTags {
+		sampleTagIDs = sampleTagIDs[:maxSampleTags]
+	}
+	rowsPerTag := tagSuggestionsSampleRows / len(sampleTagIDs)
+	query := "select entity_id, count(*) as shared from ("
+	args := []interface{}{}
+	for index, tagID := range sampleTagIDs {
+		if index > 0 {
+			query += " union all "
+		}
+		query += "(select entity_id from entity_tag_tbl where tenant_id = ? and tag_id = ? and entity_type = ? and entity_id <> ? and deleted_at is null" +
+			" order by created_at desc, id desc limit ?)"
+		args = append(args, tenantID, tagID, entityType, entityID, rowsPerTag)
+	}
+	query += ") s group by entity_id order by shared desc, entity_id limit ?"
+	args = append(args, tagSuggestionsNeighbors)
+
+	neighbors := []struct {
+		EntityID int `db:"entity_id"`
+		Shared   int `db:"shared"`
+	}{}
+	if err := dbSelect(ctx, &neighbors, query, args...); err != nil {
+		return nil, err
+	}
+
+	scores := make(map[int]int)
+	if len(neighbors) == 0 {
+		return scores, nil
+	}
+
+	shared := make(map[int]int, len(neighbors))
+	neighborIDs := make([]int, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		shared[neighbor.EntityID] = neighbor.Shared
+		neighborIDs = append(neighborIDs, neighbor.EntityID)
+	}
+
+	// 相似实体的关联数量受 MAX_TAGS_PER_ENTITY 限制，按唯一键读取
+	query, inArgs, err := sqlx.In(
+		"select entity_id, tag_id from entity_tag_tbl where tenant_id = ? and entity_type = ? and entity_id in (?) and tag_id not in (?) and deleted_at is null",
+		tenantID, entityType, neighborIDs, tagIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	links := []struct {
+		EntityID int `db:"entity_id"`
+		TagID    int `db:"tag_id"`
+	}{}
+	if err := dbSelect(ctx, &links, query, inArgs...); err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		scores[link.TagID] += shared[link.EntityID]
+	}
+	return scores, nil
+}
+
+// popularTagScores 统计该类型实体最近 popularTagsSampleRows 个关联中各个标签出现的次数
+func popularTagScores(ctx context.Context, entityType string) (map[int]int, error) {
+	rows := []struct {
+		TagID int `db:"tag_id"`
+		Count int `db:"count"`
+	}{}
+	queryErr := dbSelect(
+		ctx, &rows,
+		`select tag_id, count(*) as count from (
+			select tag_id from entity_tag_tbl where tenant_id = ? and entity_type = ? and deleted_at is null order by id desc limit ?
+		) s group by tag_id`,
+		TenantIDFromContext(ctx), entityType, popularTagsSampleRows,
+	)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	scores := make(map[int]int, len(rows))
+	for _, row := range rows {
+		scores[row.TagID] = row.Count
+	}
+	return scores, nil
+}
+
+// rankTagSuggestions 按得分从高到低、tag_id 从小到大排列，查询名称后返回前 maxTagSuggestionsLimit 个未删除的标签
+func rankTagSuggestions(ctx context.Context, scores map[int]int) ([]*TagSuggestion, error) {
+	tagIDs := make([]int, 0, len(scores))
+	for tagID := range scores {
+		tagIDs = append(tagIDs, tagID)
+	}
+	sort.Slice(tagIDs, func(i, j int) bool {
+		if scores[tagIDs[i]] != scores[tagIDs[j]] {
+			return scores[tagIDs[i]] > scores[tagIDs[j]]
+		}
+		return tagIDs[i] < tagIDs[j]
+	})
+
+	// 已删除的标签会被过滤掉，多查询一些候选
+	if len(tagIDs) > maxBatchGetTags {
+		tagIDs = tagIDs[:maxBatchGetTags]
+	}
+	tags, _, err := GetTagsByIDs(ctx, tagIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []*TagSuggestion{}
+	for index, tag := range tags {
+		if tag == nil {
+			continue
+		}
+		suggestions = append(suggestions, &TagSuggestion{
+			TagID:       tag.TagID,
+			Name:        tag.Name,
+			DisplayName: tag.DisplayName,
+			Score:       scores[tagIDs[index]],
+		})
+		if len(suggestions) == maxTagSuggestionsLimit {
+			break
+		}
+	}
+	return suggestions, nil
+}
+
+// OnEntityTagSuggestions 根据和实体有共同标签的其它实体推荐实体还没有关联的标签，
+// 实体没有关联标签时返回热门标签，此时 popular 为 true
+// @Summary 推荐实体的标签
+// @Tags entity
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "实体 ID"
+// @Param entity_type query string false "实体类型，不传时使用 DEFAULT_ENTITY_TYPE"
+// @Param limit query int false "返回的数量，默认 10，最大 50"
+// @Success 200 {object} APIResponse{data=object{entity_type=string,entity_id=int,tags=[]TagSuggestion,popular=bool,cache_age_seconds=int}}
+// @Failure 400 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Router /api/entity/{id}/tag_suggestions [get]
+func OnEntityTagSuggestions(c *gin.Context) {
+	entityID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	entityType, err := validateEntityType(c.Query("entity_type"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	limit, ok := parseLimitQuery(c, 10, maxTagSuggestionsLimit)
+	if !ok {
+		return
+	}
+
+	entry, err := SuggestEntityTags(c.Request.Context(), entityType, entityID)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	tags := entry.Tags
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	respondOK(c, gin.H{
+		"entity_type":       entityType,
+		"entity_id":         entityID,
+		"tags":              tags,
+		"popular":           entry.Popular,
+		"cache_age_seconds": int(time.Since(entry.CachedAt).Seconds()),
+	})
+}
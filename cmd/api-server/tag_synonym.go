@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxTagSynonyms 每个标签最多的同义词数量
+const maxTagSynonyms = 20
+
+// tagSynonymRow tag_synonym_tbl 中的一行，只包含加载同义词需要的字段
+type tagSynonymRow struct {
+	TagID   int    `db:"tag_id"`
+	Synonym string `db:"synonym"`
+}
+
+// LoadTagSynonyms 查询 tags 的同义词并填充到 Synonyms 中，同义词按字母顺序排列。
+// 标签 ID 全局唯一，不需要按租户过滤，可以在没有租户信息的后台任务中调用
+func LoadTagSynonyms(ctx context.Context, tags []*Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	tagsByID := make(map[int]*Tag, len(tags))
+	tagIDs := make([]int, 0, len(tags))
+	for _, tag := range tags {
+		tag.Synonyms = []string{}
+		tagsByID[tag.TagID] = tag
+		tagIDs = append(tagIDs, tag.TagID)
+	}
+
+	query, args, err := sqlx.In("select tag_id, synonym from tag_synonym_tbl where tag_id in (?) order by synonym", tagIDs)
+	if err != nil {
+		return err
+	}
+
+	var rows []*tagSynonymRow
+	if err := dbSelect(ctx, &rows, query, args...); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		tag := tagsByID[row.TagID]
+		tag.Synonyms = append(tag.Synonyms, row.Synonym)
+	}
+	return nil
+}
+
+// lockTagForSynonymTx 在事务中锁定当前租户未删除的标签，修改同一个标签的同义词或元数据时串行执行
+func lockTagForSynonymTx(ctx context.Context, tx *sqlx.Tx, tagID int) (*Tag, error) {
+	var tag Tag
+	queryErr := txGet(ctx, tx, &tag, "select "+tagColumns+" from tag_tbl where tenant_id = ? and id = ? and deleted_at is null for update", TenantIDFromContext(ctx), tagID)
+	if queryErr == sql.ErrNoRows {
+		return nil, newAPIError(http.StatusNotFound, "tag not found")
+	}
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	return &tag, nil
+}
+
+// AddTagSynonym 为标签添加同义词，返回带有全部同义词的标签。同一租户内一个同义词只能属于一个标签，
+// 已经属于其它标签时返回 409，detail 中的 tag_id 为该标签；已经属于当前标签时不做修改
+func AddTagSynonym(ctx context.Context, tagID int, synonym string) (*Tag, error) {
+	tenantID := TenantIDFromContext(ctx)
+
+	var tag *Tag
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		if tag, err = lockTagForSynonymTx(ctx, tx, tagID); err != nil {
+			return err
+		}
+		if NormalizeTagName(synonym) == tag.Name {
+			return newAPIError(http.StatusBadRequest, "synonym must be different from tag name")
+		}
+
+		var ownerTagID int
+		queryErr := txGet(ctx, tx, &ownerTagID, "select tag_id from tag_synonym_tbl where tenant_id = ? and synonym = ?", tenantID, synonym)
+		if queryErr != nil && queryErr != sql.ErrNoRows {
+			return queryErr
+		}
+		if queryErr == nil {
+			if ownerTagID == tagID {
+				return nil
+			}
+			apiErr := newAPIError(http.StatusConflict, "synonym already exists")
+			apiErr.Detail = gin.H{"tag_id": ownerTagID}
+			return apiErr
+		}
+
+		var count int
+		if queryErr := txGet(ctx, tx, &count, "select count(*) from tag_synonym_tbl where tag_id = ?", tagID); queryErr != nil {
+			return queryErr
+		}
+		if count >= maxTagSynonyms {
+			return newAPIError(http.StatusUnprocessableEntity, fmt.Sprintf("too many synonyms, max %d", maxTagSynonyms))
+		}
+
+		_, execErr := txExec(ctx, tx, "insert into tag_synonym_tbl (tenant_id, tag_id, synonym) values (?, ?, ?)", tenantID, tagID, synonym)
+		if isDuplicateKeyErr(execErr) {
+			return newAPIError(http.StatusConflict, "synonym already exists")
+		}
+		if execErr != nil {
+			return execErr
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagAddSynonym,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   tagID,
+			Metadata:   gin.H{"synonym": synonym},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	if err := LoadTagSynonyms(ctx, []*Tag{tag}); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// RemoveTagSynonym 删除标签的同义词，返回带有剩余同义词的标签，同义词不属于该标签时返回 404
+func RemoveTagSynonym(ctx context.Context, tagID int, synonym string) (*Tag, error) {
+	var tag *Tag
+	txErr := withTx(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		if tag, err = lockTagForSynonymTx(ctx, tx, tagID); err != nil {
+			return err
+		}
+
+		result, execErr := txExec(ctx, tx, "delete from tag_synonym_tbl where tag_id = ? and synonym = ?", tagID, synonym)
+		if execErr != nil {
+			return execErr
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return newAPIError(http.StatusNotFound, "synonym not found")
+		}
+
+		return InsertAuditLogTx(ctx, tx, &AuditLog{
+			Action:     AuditActionTagRemoveSynonym,
+			EntityType: AuditEntityTypeTag,
+			EntityID:   tagID,
+			Metadata:   gin.H{"synonym": synonym},
+		})
+	})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	if err := LoadTagSynonyms(ctx, []*Tag{tag}); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// AddTagSynonymReqBody 添加同义词的请求体
+type AddTagSynonymReqBody struct {
+	// Synonym 同义词，和标签名称的限制相同
+	Synonym string `json:"synonym"`
+}
+
+// OnAddTagSynonym 为标签添加同义词，搜索同义词时可以搜索到该标签
+// @Summary 添加标签同义词
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param body body AddTagSynonymReqBody true "请求体"
+// @Success 200 {object} APIResponse{data=object{tag=Tag}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 409 {object} APIResponse{error=APIError{detail=object{tag_id=int}}}
+// @Failure 413 {object} APIResponse
+// @Failure 422 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/{id}/synonyms [post]
+func OnAddTagSynonym(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var reqBody AddTagSynonymReqBody
+	if bindErr := c.ShouldBindJSON(&reqBody); bindErr != nil {
+		respondError(c, bindErrorStatus(bindErr), bindErr.Error())
+		return
+	}
+
+	synonym, err := validateTagName(reqBody.Synonym)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid synonym")
+		return
+	}
+
+	ctx := c.Request.Context()
+	tag, err := AddTagSynonym(ctx, tagID, synonym)
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	// 更新 ES 索引中的同义词
+	go ReportTagToES(ctx, config.ESIndex, tag)
+
+	respondOK(c, gin.H{
+		"tag": tag,
+	})
+}
+
+// OnDeleteTagSynonym 删除标签的同义词
+// @Summary 删除标签同义词
+// @Tags tag
+// @Produce json
+// @Param X-Tenant-Id header string true "租户 ID"
+// @Param id path int true "标签 ID"
+// @Param synonym path string true "同义词"
+// @Success 200 {object} APIResponse{data=object{tag=Tag}}
+// @Failure 400 {object} APIResponse
+// @Failure 404 {object} APIResponse
+// @Failure 500 {object} APIResponse
+// @Failure 504 {object} APIResponse
+// @Security BearerAuth
+// @Failure 401 {object} APIResponse
+// @Failure 403 {object} APIResponse
+// @Router /api/tag/{id}/synonyms/{synonym} [delete]
+func OnDeleteTagSynonym(c *gin.Context) {
+	tagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	tag, err := RemoveTagSynonym(ctx, tagID, c.Param("synonym"))
+	if err != nil {
+		respondServerError(c, err)
+		return
+	}
+
+	// 更新 ES 索引中的同义词
+	go ReportTagToES(ctx, config.ESIndex, tag)
+
+	respondOK(c, gin.H{
+		"tag": tag,
+	})
+}
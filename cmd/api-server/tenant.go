@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantIDHeader 客户端传入租户 ID 的请求头
+const TenantIDHeader = "X-Tenant-Id"
+
+// tenantIDPattern 租户 ID 只能包含字母、数字、下划线和中划线，长度与 tenant_id 字段一致
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// tenantContextKey 在 context 中保存租户 ID 的键
+type tenantContextKey struct{}
+
+// TenantMiddleware 校验 X-Tenant-Id 请求头，并把租户 ID 保存到请求的 context 中
+func TenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := c.GetHeader(TenantIDHeader)
+		if !tenantIDPattern.MatchString(tenantID) {
+			respondError(c, http.StatusBadRequest, "invalid "+TenantIDHeader)
+			return
+		}
+
+		c.Request = c.Request.WithContext(WithTenantID(c.Request.Context(), tenantID))
+		c.Next()
+	}
+}
+
+// WithTenantID 返回携带租户 ID 的 context
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantIDFromContext 从 context 中读取租户 ID，所有 MySQL 查询和 ES 搜索都需要用它限定范围
+func TenantIDFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenantID
+}
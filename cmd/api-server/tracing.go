@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingServiceName 上报链路时使用的服务名，可以通过 OTEL_SERVICE_NAME 覆盖
+const tracingServiceName = "tag-server"
+
+// tracer 创建 es.search、es.index 等手动埋点的 span，SetupTracing 之前是不上报任何数据的空实现
+var tracer = otel.Tracer("github.com/3vilive/tag-server")
+
+// SetupTracing 配置了 OTEL_EXPORTER_OTLP_ENDPOINT 时通过 OTLP/HTTP 上报链路数据，并使用 W3C Trace Context
+// 在服务之间传递链路信息。返回的函数在服务退出时调用，上报还在缓冲区中的 span。
+// 导出器的其它参数（例如 OTEL_EXPORTER_OTLP_HEADERS）同样从环境变量读取
+func SetupTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if config.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(
+		ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String(tracingServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	log.Printf("TracingEnabled: %s", config.OTLPEndpoint)
+
+	return provider.Shutdown, nil
+}
+
+// detachedContext 返回只保留 ctx 中链路信息的 context，用于请求结束后仍在后台执行的任务，
+// 任务的 span 仍然挂在请求的链路下，但不会因为请求结束而被取消
+func detachedContext(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+}
+
+// endSpan 结束 span，err 不为 nil 时记录错误并把 span 标记为失败
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,11 +1,29 @@
 module github.com/3vilive/tag-server
 
-go 1.14
+go 1.19
 
 require (
-	github.com/bitly/go-simplejson v0.5.0 // indirect
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
 	github.com/elastic/go-elasticsearch/v7 v7.7.0
-	github.com/gin-gonic/gin v1.6.3
-	github.com/go-sql-driver/mysql v1.4.0
+	github.com/gin-gonic/gin v1.8.1
+	github.com/go-redis/redis/v8 v8.11.4
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/golang-migrate/migrate/v4 v4.14.1
+	github.com/gorilla/websocket v1.5.0
+	github.com/graph-gophers/graphql-go v1.3.0
 	github.com/jmoiron/sqlx v1.2.0
+	github.com/prometheus/client_golang v1.7.1
+	github.com/swaggo/gin-swagger v1.3.0
+	github.com/swaggo/swag v1.7.0
+	go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin v0.32.0
+	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.32.0
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	golang.org/x/text v0.3.6
+	google.golang.org/grpc v1.46.0
+	google.golang.org/protobuf v1.28.0
 )
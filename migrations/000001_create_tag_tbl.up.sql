@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `tag_tbl` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `tenant_id` varchar(64) NOT NULL DEFAULT 'default',
+  `name` varchar(40) NOT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `color` varchar(16) NOT NULL DEFAULT '',
+  `category` varchar(40) NOT NULL DEFAULT '',
+  `version` int(10) unsigned NOT NULL DEFAULT 1,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  `deleted_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tenant_name` (`tenant_id`, `name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
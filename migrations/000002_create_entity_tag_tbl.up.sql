@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `entity_tag_tbl` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `tenant_id` varchar(64) NOT NULL DEFAULT 'default',
+  `entity_id` int(10) unsigned NOT NULL,
+  `tag_id` int(10) unsigned NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tenant_entity_tag` (`tenant_id`, `entity_id`, `tag_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
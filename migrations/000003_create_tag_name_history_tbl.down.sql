@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `tag_name_history_tbl`;
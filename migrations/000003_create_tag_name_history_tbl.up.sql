@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `tag_name_history_tbl` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `tag_id` int(11) NOT NULL,
+  `old_name` varchar(40) NOT NULL,
+  `new_name` varchar(40) NOT NULL,
+  `changed_by` varchar(64) NOT NULL DEFAULT '',
+  `changed_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `tag_id` (`tag_id`),
+  KEY `old_name` (`old_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
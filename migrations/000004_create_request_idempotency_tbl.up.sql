@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `request_idempotency_tbl` (
+  `tenant_id` varchar(64) NOT NULL DEFAULT 'default',
+  `key` varchar(64) NOT NULL,
+  `response_body` blob NOT NULL,
+  `status_code` smallint(5) unsigned NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`tenant_id`, `key`),
+  KEY `created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
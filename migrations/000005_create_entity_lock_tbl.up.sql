@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS `entity_lock_tbl` (
+  `tenant_id` varchar(64) NOT NULL,
+  `entity_id` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`tenant_id`, `entity_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
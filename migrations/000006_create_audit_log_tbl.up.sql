@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `audit_log_tbl` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `tenant_id` varchar(64) NOT NULL,
+  `actor_id` varchar(64) NOT NULL DEFAULT '',
+  `action` varchar(32) NOT NULL,
+  `entity_type` varchar(32) NOT NULL,
+  `entity_id` int(10) unsigned NOT NULL DEFAULT 0,
+  `metadata` json DEFAULT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `tenant_entity` (`tenant_id`, `entity_type`, `entity_id`),
+  KEY `actor_id` (`actor_id`),
+  KEY `created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
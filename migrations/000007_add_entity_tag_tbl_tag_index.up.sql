@@ -0,0 +1 @@
+ALTER TABLE `entity_tag_tbl` ADD KEY `tenant_tag` (`tenant_id`, `tag_id`);
@@ -0,0 +1,13 @@
+DELETE FROM `entity_lock_tbl` WHERE `entity_type` <> 'default';
+
+ALTER TABLE `entity_lock_tbl`
+  DROP PRIMARY KEY,
+  DROP COLUMN `entity_type`,
+  ADD PRIMARY KEY (`tenant_id`, `entity_id`);
+
+DELETE FROM `entity_tag_tbl` WHERE `entity_type` <> 'default';
+
+ALTER TABLE `entity_tag_tbl`
+  DROP INDEX `tenant_entity_tag`,
+  DROP COLUMN `entity_type`,
+  ADD UNIQUE KEY `tenant_entity_tag` (`tenant_id`, `entity_id`, `tag_id`);
@@ -0,0 +1,9 @@
+ALTER TABLE `entity_tag_tbl`
+  ADD COLUMN `entity_type` varchar(32) NOT NULL DEFAULT 'default' AFTER `tenant_id`,
+  DROP INDEX `tenant_entity_tag`,
+  ADD UNIQUE KEY `tenant_entity_tag` (`tenant_id`, `entity_type`, `entity_id`, `tag_id`);
+
+ALTER TABLE `entity_lock_tbl`
+  ADD COLUMN `entity_type` varchar(32) NOT NULL DEFAULT 'default' AFTER `tenant_id`,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `entity_type`, `entity_id`);
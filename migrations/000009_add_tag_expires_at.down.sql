@@ -0,0 +1,3 @@
+ALTER TABLE `tag_tbl`
+  DROP INDEX `expires_at`,
+  DROP COLUMN `expires_at`;
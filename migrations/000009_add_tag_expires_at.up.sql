@@ -0,0 +1,3 @@
+ALTER TABLE `tag_tbl`
+  ADD COLUMN `expires_at` datetime DEFAULT NULL AFTER `deleted_at`,
+  ADD KEY `expires_at` (`expires_at`);
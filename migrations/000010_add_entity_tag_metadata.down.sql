@@ -0,0 +1 @@
+ALTER TABLE `entity_tag_tbl` DROP COLUMN `metadata`;
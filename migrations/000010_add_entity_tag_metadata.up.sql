@@ -0,0 +1 @@
+ALTER TABLE `entity_tag_tbl` ADD COLUMN `metadata` json DEFAULT NULL AFTER `tag_id`;
@@ -0,0 +1 @@
+ALTER TABLE `entity_tag_tbl` ADD COLUMN `position` int(10) unsigned NOT NULL DEFAULT 0 AFTER `metadata`;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `tag_synonym_tbl` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `tenant_id` varchar(64) NOT NULL,
+  `tag_id` int(11) NOT NULL,
+  `synonym` varchar(40) NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tenant_synonym` (`tenant_id`, `synonym`),
+  KEY `tag_id` (`tag_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE `entity_tag_tbl`
+  DROP COLUMN `added_by`,
+  DROP COLUMN `source`;
@@ -0,0 +1,3 @@
+ALTER TABLE `entity_tag_tbl`
+  ADD COLUMN `source` varchar(32) NOT NULL DEFAULT 'api' AFTER `position`,
+  ADD COLUMN `added_by` varchar(64) NOT NULL DEFAULT '' AFTER `source`;
@@ -0,0 +1,3 @@
+ALTER TABLE `entity_tag_tbl`
+  DROP COLUMN `updated_at`,
+  DROP COLUMN `weight`;
@@ -0,0 +1,5 @@
+ALTER TABLE `entity_tag_tbl`
+  ADD COLUMN `weight` double NOT NULL DEFAULT 1 AFTER `added_by`,
+  ADD COLUMN `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER `created_at`;
+
+UPDATE `entity_tag_tbl` SET `updated_at` = `created_at`;
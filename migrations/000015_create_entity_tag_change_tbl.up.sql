@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `entity_tag_change_tbl` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `tenant_id` varchar(64) NOT NULL,
+  `entity_type` varchar(32) NOT NULL,
+  `entity_id` int(10) unsigned NOT NULL,
+  `tag_id` int(10) unsigned NOT NULL,
+  `change_type` varchar(16) NOT NULL,
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  KEY `tenant_id` (`tenant_id`, `id`),
+  KEY `tenant_created_at` (`tenant_id`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `es_outbox_tbl`;
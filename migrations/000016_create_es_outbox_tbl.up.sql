@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `es_outbox_tbl` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `tenant_id` varchar(64) NOT NULL,
+  `tag_id` int(10) unsigned NOT NULL,
+  `index_name` varchar(255) NOT NULL,
+  `attempts` int(10) unsigned NOT NULL DEFAULT 0,
+  `last_error` varchar(255) NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `index_tag` (`index_name`, `tag_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
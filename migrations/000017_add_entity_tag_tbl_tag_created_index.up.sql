@@ -0,0 +1 @@
+ALTER TABLE `entity_tag_tbl` ADD KEY `tenant_tag_created` (`tenant_id`, `tag_id`, `created_at`, `id`);
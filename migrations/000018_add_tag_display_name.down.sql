@@ -0,0 +1,3 @@
+UPDATE `tag_tbl` SET `name` = `display_name`;
+
+ALTER TABLE `tag_tbl` DROP COLUMN `display_name`;
@@ -0,0 +1,3 @@
+ALTER TABLE `tag_tbl` ADD COLUMN `display_name` varchar(40) NOT NULL DEFAULT '' AFTER `name`;
+
+UPDATE `tag_tbl` SET `display_name` = `name`, `name` = LOWER(`name`);
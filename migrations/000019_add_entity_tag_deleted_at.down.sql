@@ -0,0 +1,5 @@
+DELETE FROM `entity_tag_tbl` WHERE `deleted_at` IS NOT NULL;
+
+ALTER TABLE `entity_tag_tbl`
+  DROP KEY `deleted_at`,
+  DROP COLUMN `deleted_at`;
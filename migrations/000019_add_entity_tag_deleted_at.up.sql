@@ -0,0 +1,3 @@
+ALTER TABLE `entity_tag_tbl`
+  ADD COLUMN `deleted_at` datetime DEFAULT NULL AFTER `updated_at`,
+  ADD KEY `deleted_at` (`deleted_at`);
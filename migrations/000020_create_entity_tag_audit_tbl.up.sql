@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `entity_tag_audit_tbl` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `tenant_id` varchar(64) NOT NULL,
+  `entity_type` varchar(32) NOT NULL,
+  `entity_id` int(10) unsigned NOT NULL,
+  `tag_id` int(10) unsigned NOT NULL,
+  `action` varchar(16) NOT NULL,
+  `actor_id` varchar(64) NOT NULL DEFAULT '',
+  `source` varchar(32) NOT NULL DEFAULT '',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  KEY `tenant_entity` (`tenant_id`, `entity_type`, `entity_id`, `id`),
+  KEY `tenant_tag` (`tenant_id`, `tag_id`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `tag_metadata_tbl` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `tag_id` int(11) NOT NULL,
+  `key` varchar(64) NOT NULL,
+  `value` text NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `tag_key` (`tag_id`, `key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE `tag_tbl` DROP KEY `tenant_created`;
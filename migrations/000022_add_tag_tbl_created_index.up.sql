@@ -0,0 +1 @@
+ALTER TABLE `tag_tbl` ADD KEY `tenant_created` (`tenant_id`, `created_at`, `id`);
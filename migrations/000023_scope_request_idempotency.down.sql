@@ -0,0 +1,7 @@
+DELETE FROM `request_idempotency_tbl` WHERE `scope` <> '' OR `actor_id` <> '';
+ALTER TABLE `request_idempotency_tbl`
+  DROP PRIMARY KEY,
+  DROP COLUMN `request_hash`,
+  DROP COLUMN `scope`,
+  DROP COLUMN `actor_id`,
+  ADD PRIMARY KEY (`tenant_id`, `key`);
@@ -0,0 +1,6 @@
+ALTER TABLE `request_idempotency_tbl`
+  ADD COLUMN `actor_id` varchar(64) NOT NULL DEFAULT '' AFTER `tenant_id`,
+  ADD COLUMN `scope` varchar(255) NOT NULL DEFAULT '' AFTER `actor_id`,
+  ADD COLUMN `request_hash` char(64) NOT NULL DEFAULT '' AFTER `key`,
+  DROP PRIMARY KEY,
+  ADD PRIMARY KEY (`tenant_id`, `actor_id`, `scope`, `key`);
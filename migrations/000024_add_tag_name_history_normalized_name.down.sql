@@ -0,0 +1,3 @@
+ALTER TABLE `tag_name_history_tbl`
+  DROP KEY `old_normalized_name`,
+  DROP COLUMN `old_normalized_name`;
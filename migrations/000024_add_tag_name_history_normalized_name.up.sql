@@ -0,0 +1,5 @@
+ALTER TABLE `tag_name_history_tbl`
+  ADD COLUMN `old_normalized_name` varchar(40) NOT NULL DEFAULT '' AFTER `old_name`,
+  ADD KEY `old_normalized_name` (`old_normalized_name`);
+
+UPDATE `tag_name_history_tbl` SET `old_normalized_name` = LOWER(`old_name`);
@@ -0,0 +1 @@
+ALTER TABLE `entity_tag_change_tbl` DROP KEY `tenant_entity`;
@@ -0,0 +1 @@
+ALTER TABLE `entity_tag_change_tbl` ADD KEY `tenant_entity` (`tenant_id`, `entity_type`, `entity_id`, `id`);
@@ -0,0 +1,4 @@
+// Package tagpb 标签服务的 gRPC 接口定义，tag.pb.go 和 tag_grpc.pb.go 由 tag.proto 生成，不要手动修改
+package tagpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative tag.proto